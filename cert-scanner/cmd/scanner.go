@@ -7,10 +7,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/sgargan/cert-scanner-darkly/compare"
 	"github.com/sgargan/cert-scanner-darkly/config"
 	"github.com/sgargan/cert-scanner-darkly/metrics"
 	"github.com/sgargan/cert-scanner-darkly/scanner"
 	"github.com/sgargan/cert-scanner-darkly/utils"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/exp/slog"
@@ -62,6 +65,15 @@ func Run(cmd *cobra.Command, args []string) {
 func repeatedly() {
 	ctx, _ := signal.NotifyContext(context.Background(), syscall.SIGKILL)
 	interval := viper.GetDuration(config.Interval)
+	discoveries, err := scanner.CreateDiscoveries()
+	if err != nil {
+		LogExit("error configuring discovery mechanisms", "err", err)
+	}
+	comparators, err := compare.CreateComparators()
+	if err != nil {
+		LogExit("error configuring scan comparators", "err", err)
+	}
+	changes := watch(ctx, discoveries)
 	ticker := time.NewTicker(interval)
 	running := false
 	scans := 0
@@ -72,43 +84,95 @@ func repeatedly() {
 			running = true
 			slog.Info("running scan", "scans", scans)
 
-			current, err := scan(ctx)
+			current, err := scan(ctx, discoveries)
 			if err != nil {
 				slog.Debug("Error running scan", "scans", scans, "err", err)
 			}
 			slog.Debug("scan complete, waiting for interval before next scan", "scans", scans, "interval", interval.String())
 			running = false
 
-			compareScans(previous, current)
+			compareScans(comparators, previous, current)
+			if current != nil {
+				previous = current
+			}
 		} else {
 			slog.Info("previous scan is still running", "scans", scans)
 		}
 
-		select {
-		case <-ticker.C:
-			slog.Debug("waited for interval after scan", "scans", scans, "interval", interval.String())
-		case <-ctx.Done():
-			slog.Info("context was cancelled, exiting", "scans", scans)
-			return
+	waiting:
+		for {
+			select {
+			case <-ticker.C:
+				slog.Debug("waited for interval after scan", "scans", scans, "interval", interval.String())
+				break waiting
+			case change := <-changes:
+				scanChanges(ctx, comparators, change)
+			case <-ctx.Done():
+				slog.Info("context was cancelled, exiting", "scans", scans)
+				return
+			}
+		}
+	}
+}
+
+// watch starts watching each discovery that supports it, returning a channel of the changes they find
+func watch(ctx context.Context, discoveries Discoveries) chan *TargetChanges {
+	changes := make(chan *TargetChanges)
+	for _, discovery := range discoveries {
+		if watching, ok := discovery.(WatchingDiscovery); ok {
+			go func() {
+				if err := watching.Watch(ctx, changes); err != nil {
+					slog.Error("error watching for discovery changes", "err", err)
+				}
+			}()
+		}
+	}
+	return changes
+}
+
+// scanChanges clears the metrics of targets removed between scans and scans those added right away. Removals
+// are cleared first, as a changed target is removed and added again with the same source and address.
+func scanChanges(ctx context.Context, comparators []compare.ScanComparator, change *TargetChanges) {
+	slog.Info("discovered targets changed between scans", "added", len(change.Added), "removed", len(change.Removed))
+	if len(change.Removed) > 0 {
+		for _, comparator := range comparators {
+			comparator.Remove(change.Removed)
+		}
+	}
+
+	if len(change.Added) > 0 {
+		if _, err := scanner.ScanTargets(ctx, change.Added); err != nil {
+			slog.Debug("Error scanning added targets", "err", err)
 		}
 	}
 }
 
 func once() {
 	slog.Info("running standalone scan")
-	scan(context.Background())
+	discoveries, err := scanner.CreateDiscoveries()
+	if err != nil {
+		return
+	}
+	scan(context.Background(), discoveries)
 }
 
-func scan(ctx context.Context) (*scanner.Scan, error) {
+func scan(ctx context.Context, discoveries Discoveries) (*scanner.Scan, error) {
 	timeout := viper.GetDuration(config.Timeout)
 	if timeout != 0 {
 		ctx, _ = utils.CreateSignalledContextWithContext(ctx, timeout, syscall.SIGKILL)
 	}
-	return scanner.PerformScan(ctx)
+	return scanner.PerformScanWithDiscoveries(ctx, discoveries)
 }
 
-func compareScans(previous, current *scanner.Scan) {
-
+// compareScans runs the comparators over the previous and current scans, so they can act on targets that
+// are no longer present
+func compareScans(comparators []compare.ScanComparator, previous, current *scanner.Scan) {
+	if previous == nil || current == nil {
+		return
+	}
+	for _, comparator := range comparators {
+		comparator.Compare(previous, current)
+	}
 }
 
 func configureDefaultFlags(cmd *cobra.Command) {
@@ -12,6 +12,9 @@ var factories = map[string]Factory[ScanComparator]{}
 
 type ScanComparator interface {
 	Compare(previous, current CompletedScan)
+
+	// Remove acts on the targets a discovery reported removed between scans
+	Remove(targets []*Target)
 }
 
 func CreateComparators() ([]ScanComparator, error) {
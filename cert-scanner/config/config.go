@@ -20,10 +20,75 @@ const (
 	DiscoveryK8sIgnoreContainers     = "discovery.kubernetes.ignore_containers"
 	DiscoveryK8sKeys                 = "discovery.kubernetes.keys"
 	DiscoveryK8sMatchCIDR            = "discovery.kubernetes.match_cidr"
+	DiscoveryK8sClusters             = "discovery.kubernetes.clusters"
+	DiscoveryK8sLabelSelector        = "discovery.kubernetes.label_selector"
+	DiscoveryK8sFieldSelector        = "discovery.kubernetes.field_selector"
+	DiscoveryK8sNamespaceSelector    = "discovery.kubernetes.namespace_selector"
+	DiscoveryK8sPageSize             = "discovery.kubernetes.page_size"
+	DiscoveryK8sInformer             = "discovery.kubernetes.informer"
+	DiscoveryK8sResyncPeriod         = "discovery.kubernetes.resync_period"
+	DiscoveryK8sWorkloads            = "discovery.kubernetes.workloads"
+	DiscoveryK8sWorkloadAnnotations  = "discovery.kubernetes.workload_annotations"
+	DiscoveryK8sServicesSource       = "discovery.kubernetes_services.source"
+	DiscoveryK8sServicesNamespace    = "discovery.kubernetes_services.namespace"
+	DiscoveryK8sServicesIgnore       = "discovery.kubernetes_services.ignore_services"
+	DiscoveryK8sServicesKeys         = "discovery.kubernetes_services.keys"
+	DiscoveryK8sServicesMatchCIDR    = "discovery.kubernetes_services.match_cidr"
+	DiscoveryK8sServicesPageSize     = "discovery.kubernetes_services.page_size"
+	DiscoveryK8sIngressSource        = "discovery.kubernetes_ingress.source"
+	DiscoveryK8sIngressNamespace     = "discovery.kubernetes_ingress.namespace"
+	DiscoveryK8sIngressGatewayAPI    = "discovery.kubernetes_ingress.gateway_api"
+	DiscoveryK8sSecretsSource        = "discovery.kubernetes_secrets.source"
+	DiscoveryK8sSecretsNamespace     = "discovery.kubernetes_secrets.namespace"
+	DiscoveryK8sSecretsKeys          = "discovery.kubernetes_secrets.keys"
+	DiscoveryCertManagerSource       = "discovery.cert_manager.source"
+	DiscoveryCertManagerNamespace    = "discovery.cert_manager.namespace"
+	DiscoveryCertManagerKeys         = "discovery.cert_manager.keys"
 	DiscoveryFilePaths               = "discovery.files.paths"
+	DiscoveryFileInclude             = "discovery.files.include"
+	DiscoveryFileExclude             = "discovery.files.exclude"
+	DiscoveryCIDRSource              = "discovery.cidr.source"
+	DiscoveryCIDRRanges              = "discovery.cidr.ranges"
+	DiscoveryCIDRRate                = "discovery.cidr.rate"
+	DiscoveryCIDRTimeout             = "discovery.cidr.timeout"
+	DiscoveryCIDRMaxAddresses        = "discovery.cidr.max_addresses"
+	DiscoveryHTTPSDEndpoints         = "discovery.http_sd.endpoints"
+	DiscoveryHTTPSDTimeout           = "discovery.http_sd.timeout"
+	DiscoveryConsulSource            = "discovery.consul.source"
+	DiscoveryConsulAddress           = "discovery.consul.address"
+	DiscoveryConsulDatacenters       = "discovery.consul.datacenters"
+	DiscoveryConsulServices          = "discovery.consul.services"
+	DiscoveryConsulTags              = "discovery.consul.tags"
+	DiscoveryConsulHealthStatus      = "discovery.consul.health_status"
+	DiscoveryConsulToken             = "discovery.consul.token"
+	DiscoveryConsulTokenFile         = "discovery.consul.token_file"
+	DiscoveryConsulCAFile            = "discovery.consul.ca_file"
+	DiscoveryConsulTimeout           = "discovery.consul.timeout"
+	DiscoveryDockerSource            = "discovery.docker.source"
+	DiscoveryDockerSocket            = "discovery.docker.socket"
+	DiscoveryDockerPorts             = "discovery.docker.ports"
+	DiscoveryDockerHostAddress       = "discovery.docker.host_address"
+	DiscoveryDockerIgnoreContainers  = "discovery.docker.ignore_containers"
+	DiscoveryPortScansSource         = "discovery.port_scans.source"
+	DiscoveryPortScansPaths          = "discovery.port_scans.paths"
+	DiscoveryPortScansTLSOnly        = "discovery.port_scans.tls_only"
+	DiscoveryDNSSource               = "discovery.dns.source"
+	DiscoveryDNSServer               = "discovery.dns.server"
+	DiscoveryDNSSRV                  = "discovery.dns.srv"
+	DiscoveryDNSZoneFiles            = "discovery.dns.zone_files"
+	DiscoveryDNSInclude              = "discovery.dns.include"
+	DiscoveryDNSExclude              = "discovery.dns.exclude"
+	DiscoveryDNSPorts                = "discovery.dns.ports"
+	DiscoveryDNSTimeout              = "discovery.dns.timeout"
 	ProcessorsTlsEnabled             = "processors.tls-state.enabled"
+	ProcessorsTlsStartTLSPorts       = "processors.tls-state.starttls_ports"
+	ProcessorsTlsClientCerts         = "processors.tls-state.client_certs"
+	ProcessorsTlsProxies             = "processors.tls-state.proxies"
+	ProcessorsTlsMaxConnections      = "processors.tls-state.max_connections"
+	ProcessorsStaticCertsEnabled     = "processors.static-certs.enabled"
 	ValidationsCipherSuite           = "validations.cipher_suite.allowed_ciphers"
 	ValidationsExpiryWindow          = "validations.expiry.warning_window"
+	ValidationsRenewalGracePeriod    = "validations.renewal.grace_period"
 	ValidationsTrustChainCACertPaths = "validations.trust_chain.ca_paths"
 	ValidationsTrustChainSystemRoots = "validations.trust_chain.use_system_roots"
 	ValidationsNotYetValidEnabled    = "validations.not_yet_valid.enabled"
@@ -38,12 +103,14 @@ const (
 	Interval                         = "scan.interval"
 	Timeout                          = "scan.timeout"
 	Repeated                         = "scan.repeated"
+	Duplicates                       = "scan.duplicates"
+	BatchProcessors                  = "batch.processors"
 )
 
 // LoadConfiguration loads and verifies configuration into viper.
 func LoadConfiguration() error {
 	configFile := determineConfigFilename()
-	setDefaults()
+	SetDefaults()
 	viper.AddConfigPath(".")
 	viper.SetEnvPrefix("CERT_SCAN")
 	viper.AutomaticEnv()
@@ -62,6 +129,10 @@ func LoadConfiguration() error {
 		return err
 	}
 
+	if duplicates := viper.GetString(Duplicates); duplicates != "merge" && duplicates != "keep" {
+		return fmt.Errorf("%s of %s is invalid, expected merge or keep", Duplicates, duplicates)
+	}
+
 	if viper.GetDuration("reporters.metrics.expiry") == 0 {
 		viper.Set("reporters.metrics.expiry", viper.GetDuration(Interval)*2)
 	}
@@ -69,16 +140,46 @@ func LoadConfiguration() error {
 	return nil
 }
 
-func setDefaults() {
+// SetDefaults gives the configuration its default values. Discovery defaults are given with setDefault, so
+// they do not enable their discovery, see CreateConfigured.
+func SetDefaults() {
 	viper.Set(ProcessorsTlsEnabled, true)
+	viper.SetDefault(ProcessorsStaticCertsEnabled, true)
 	viper.SetDefault(ValidationsExpiryWindow, "168h")
 	viper.SetDefault(ValidationsTrustChainCACertPaths, []string{})
 	viper.SetDefault(ValidationsTLSMinVersion, "1.2")
 	viper.SetDefault(ValidationsTrustChainCACertPaths, "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt")
 	viper.SetDefault(ValidationsNotYetValidEnabled, true)
 
+	viper.SetDefault(Duplicates, "merge")
+
 	viper.SetDefault(ReportersLoggingEnabled, true)
 	viper.SetDefault(ReportersMetricsEnabled, true)
+
+	setDefault(DiscoveryK8sPageSize, 500)
+	setDefault(DiscoveryK8sResyncPeriod, "1h")
+	setDefault(DiscoveryK8sServicesPageSize, 500)
+	setDefault(DiscoveryCIDRRate, 1000)
+	setDefault(DiscoveryCIDRTimeout, "1s")
+	setDefault(DiscoveryCIDRMaxAddresses, 65536)
+	setDefault(DiscoveryHTTPSDTimeout, "10s")
+	setDefault(DiscoveryConsulAddress, "http://127.0.0.1:8500")
+	setDefault(DiscoveryConsulHealthStatus, "passing")
+	setDefault(DiscoveryConsulTimeout, "10s")
+	setDefault(DiscoveryDockerSocket, "/var/run/docker.sock")
+	setDefault(DiscoveryDockerPorts, "all")
+	setDefault(DiscoveryDockerHostAddress, "127.0.0.1")
+	setDefault(DiscoveryDNSPorts, []int{443})
+	setDefault(DiscoveryDNSTimeout, "5s")
+}
+
+// defaults holds the default value of each key given one with setDefault
+var defaults = map[string]any{}
+
+// setDefault gives the key a default that is ignored when deciding if its group is configured
+func setDefault(key string, value any) {
+	defaults[key] = value
+	viper.SetDefault(key, value)
 }
 
 func checkDuration(key string, duration time.Duration) error {
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/suite"
 )
@@ -13,6 +14,10 @@ type ConfigTests struct {
 	suite.Suite
 }
 
+func (t *ConfigTests) SetupTest() {
+	viper.Reset()
+}
+
 func (t *ConfigTests) TestLoadConfig() {
 	yaml := `---
 validations:
@@ -50,6 +55,26 @@ func (t *ConfigTests) TestLoadEmptyConfig() {
 	t.Equal(t.ParseDuration("168h"), viper.GetDuration("validations.expiry.warning_window"))
 }
 
+func (t *ConfigTests) TestDefaultsDoNotEnableDiscoveries() {
+	factories := map[string]Factory[string]{}
+	for _, name := range []string{"kubernetes", "kubernetes_services", "cidr", "http_sd", "consul", "docker", "dns"} {
+		factories[name] = (&MockFactory{}).Create
+	}
+
+	t.runTestCase("empty config", "")
+	created, err := CreateConfigured[string]("discovery", factories)
+	t.NoError(err)
+	t.Empty(created)
+
+	t.runTestCase("default in config", `discovery:
+  cidr:
+    rate: 1000
+`)
+	created, err = CreateConfigured[string]("discovery", factories)
+	t.NoError(err)
+	t.Len(created, 1)
+}
+
 func (t *ConfigTests) TestNoConfig() {
 	t.runTestCase("empty config", "")
 	t.True(viper.GetBool("metrics.enabled"))
@@ -67,6 +92,15 @@ func (t *ConfigTests) TestInvalidDurations() {
 `))
 }
 
+func (t *ConfigTests) TestDuplicates() {
+	t.runTestCase("empty config", "")
+	t.Equal("merge", viper.GetString(Duplicates))
+
+	t.ErrorContains(t.runTestCaseWithError("invalid duplicates", `scan:
+  duplicates: drop
+`), "scan.duplicates of drop is invalid, expected merge or keep")
+}
+
 func (t *ConfigTests) TestConfigFileDoesNotExist() {
 	viper.Set("config", "doesnotextist")
 	t.Error(LoadConfiguration())
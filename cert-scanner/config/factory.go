@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/spf13/viper"
@@ -13,7 +14,8 @@ import (
 // it iterates each of the given factories and checks if an entry 'processor.name.enabled' has been set to true. If it
 // has the factory will be invoked e.g. for a group 'processors' and a factories map containing a factorys named foo,
 // this will check that a config key 'processors.foo' is present an only invoke the factory function
-// is this is true. A config can be disabled by setting an 'enabled' config value to false e.g. processors.foo.enabled: false
+// is this is true. Defaults given with setDefault are ignored, so they do not enable 'processors.foo'.
+// A config can be disabled by setting an 'enabled' config value to false e.g. processors.foo.enabled: false
 // It returns a slice containing the enabled instances or an error if there was any issue during construction.
 func CreateConfigured[T comparable](group string, factories map[string]Factory[T]) ([]T, error) {
 	created := make([]T, 0)
@@ -22,7 +24,7 @@ func CreateConfigured[T comparable](group string, factories map[string]Factory[T
 		key := fmt.Sprintf("%s.%s", group, name)
 		enabled := fmt.Sprintf("%s.enabled", key)
 
-		keyset := viper.Get(key) != nil
+		keyset := configured(key)
 		noEnableConfigForKey := viper.GetString(enabled) == ""
 		boolEnabled := viper.GetBool(enabled)
 		if boolEnabled || (keyset && noEnableConfigForKey) {
@@ -40,3 +42,24 @@ func CreateConfigured[T comparable](group string, factories map[string]Factory[T
 	slog.Debug("created all instances of type", "group", group, "count", len(created))
 	return created, nil
 }
+
+// configured returns true if the given key, or any key beneath it, has been set other than by its default
+func configured(key string) bool {
+	if viper.InConfig(key) {
+		return true
+	}
+	if value := viper.Get(key); value != nil {
+		if _, isMap := value.(map[string]interface{}); !isMap {
+			return true
+		}
+	}
+	for _, k := range viper.AllKeys() {
+		if k != key && !strings.HasPrefix(k, key+".") {
+			continue
+		}
+		if value, isDefault := defaults[k]; !isDefault || viper.InConfig(k) || !reflect.DeepEqual(value, viper.Get(k)) {
+			return true
+		}
+	}
+	return false
+}
@@ -47,6 +47,18 @@ func (t *FactoryTests) TestCreateAppliedIfKeyAlonePresent() {
 	t.assertValidations(3)
 }
 
+func (t *FactoryTests) TestNotCreatedByDefaults() {
+	setDefault("somegroup.one.timeout", "10s")
+	t.assertValidations(0)
+	viper.Set("somegroup.one.timeout", "5s")
+	t.assertValidations(1)
+}
+
+func (t *FactoryTests) TestCreatedByEnabledDefault() {
+	setDefault("somegroup.one.enabled", true)
+	t.assertValidations(1)
+}
+
 func (t *FactoryTests) TestCreateRaisesError() {
 
 	viper.Set("somegroup.fails.enabled", true)
@@ -0,0 +1,329 @@
+package cidr
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	CIDR   = "cidr"
+	Subnet = "subnet"
+	Port   = "port"
+)
+
+// Range is a set of cidr blocks to sweep for open ports
+type Range struct {
+	CIDRs        []string `mapstructure:"cidrs"`
+	Ports        []string `mapstructure:"ports"`
+	Exclude      []string `mapstructure:"exclude"`
+	ExcludePorts []string `mapstructure:"exclude_ports"`
+}
+
+type CIDRDiscoveryConfig struct {
+	source       string
+	ranges       []Range
+	rate         int
+	timeout      time.Duration
+	maxAddresses int64
+	parallel     int
+}
+
+type subnet struct {
+	prefix  netip.Prefix
+	exclude []netip.Prefix
+	ports   []uint16
+}
+
+type probe struct {
+	address netip.AddrPort
+	subnet  netip.Prefix
+}
+
+type CIDRDiscovery struct {
+	subnets []subnet
+	CIDRDiscoveryConfig
+}
+
+// CreateDiscovery creates a Discovery instance that sweeps the configured cidr ranges for open ports
+func CreateDiscovery() (Discovery, error) {
+	var ranges []Range
+	if err := viper.UnmarshalKey(config.DiscoveryCIDRRanges, &ranges); err != nil {
+		return nil, fmt.Errorf("error parsing cidr ranges: %v", err)
+	}
+
+	cfg := CIDRDiscoveryConfig{
+		source:       viper.GetString(config.DiscoveryCIDRSource),
+		ranges:       ranges,
+		rate:         viper.GetInt(config.DiscoveryCIDRRate),
+		timeout:      viper.GetDuration(config.DiscoveryCIDRTimeout),
+		maxAddresses: viper.GetInt64(config.DiscoveryCIDRMaxAddresses),
+		parallel:     viper.GetInt(config.BatchProcessors),
+	}
+	if cfg.parallel == 0 {
+		cfg.parallel = runtime.NumCPU() + 1
+	}
+	return CreateCIDRDiscovery(cfg)
+}
+
+// CreateCIDRDiscovery creates a discovery that sweeps the given ranges with tcp connects. Returns an error
+// if the ranges cannot be parsed or together hold more addresses than the configured max.
+func CreateCIDRDiscovery(config CIDRDiscoveryConfig) (*CIDRDiscovery, error) {
+	slog.Info("creating cidr discovery", "source", config.source, "ranges", len(config.ranges), "rate", config.rate, "maxAddresses", config.maxAddresses)
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the cidr ranges is required")
+	}
+	if config.rate <= 0 || config.parallel <= 0 {
+		return nil, fmt.Errorf("rate and parallelism of the sweep must be positive")
+	}
+	// the sweep connects once per tick, so a rate above one a nanosecond cannot be kept
+	if config.rate > int(time.Second) {
+		return nil, fmt.Errorf("rate of %d connects a second is more than the max of %d", config.rate, int(time.Second))
+	}
+
+	subnets := make([]subnet, 0)
+	for _, r := range config.ranges {
+		parsed, err := parseRange(r)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, parsed...)
+	}
+	if len(subnets) == 0 {
+		return nil, fmt.Errorf("at least one cidr range is required")
+	}
+
+	total := big.NewInt(0)
+	for _, s := range subnets {
+		total.Add(total, addressCount(s.prefix))
+	}
+	if total.Cmp(big.NewInt(config.maxAddresses)) > 0 {
+		return nil, fmt.Errorf("cidr ranges hold %s addresses, more than the max of %d", total.String(), config.maxAddresses)
+	}
+
+	return &CIDRDiscovery{
+		CIDRDiscoveryConfig: config,
+		subnets:             subnets,
+	}, nil
+}
+
+// Discover sweeps each address and port in the configured ranges with a tcp connect, emitting a [Target]
+// for each open port. Connects are limited to the configured rate and parallelism. Returns an error if the
+// context is done before the sweep completes.
+func (d *CIDRDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	slog.Debug("starting cidr discovery", "source", d.source, "subnets", len(d.subnets))
+	probes := make(chan probe)
+	go func() {
+		defer close(probes)
+		d.enumerate(ctx, probes)
+	}()
+
+	limiter := time.NewTicker(time.Second / time.Duration(d.rate))
+	defer limiter.Stop()
+
+	var numProbes, numTargets int64
+	var lock sync.Mutex
+	wait := sync.WaitGroup{}
+	wait.Add(d.parallel)
+	for x := 0; x < d.parallel; x++ {
+		go func() {
+			defer wait.Done()
+			for p := range probes {
+				select {
+				case <-limiter.C:
+				case <-ctx.Done():
+					return
+				}
+
+				open := d.isOpen(ctx, p.address)
+				lock.Lock()
+				numProbes++
+				if open {
+					numTargets++
+				}
+				lock.Unlock()
+				if !open {
+					continue
+				}
+
+				slog.Debug("found open port", "source", d.source, "address", p.address.String())
+				select {
+				case targets <- d.createTarget(p):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wait.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("error sweeping cidr ranges: %v", err)
+	}
+	slog.Info("finished cidr discovery", "source", d.source, "probes", numProbes, "targets", numTargets)
+	return nil
+}
+
+// enumerate emits a probe for each port of each address in the subnets, skipping excluded addresses
+func (d *CIDRDiscovery) enumerate(ctx context.Context, probes chan probe) {
+	for _, s := range d.subnets {
+		for addr := s.prefix.Masked().Addr(); s.prefix.Contains(addr); addr = addr.Next() {
+			if excluded(addr, s.exclude) {
+				continue
+			}
+			for _, port := range s.ports {
+				select {
+				case probes <- probe{address: netip.AddrPortFrom(addr, port), subnet: s.prefix}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *CIDRDiscovery) isOpen(ctx context.Context, address netip.AddrPort) bool {
+	dialer := &net.Dialer{Timeout: d.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address.String())
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func (d *CIDRDiscovery) createTarget(p probe) *Target {
+	return &Target{
+		Address: CreateNetIPAddress(p.address),
+		Metadata: Metadata{
+			Name:       p.address.String(),
+			Source:     d.source,
+			SourceType: CIDR,
+			Labels: Labels{
+				Subnet: p.subnet.String(),
+				Port:   strconv.Itoa(int(p.address.Port())),
+			},
+		},
+	}
+}
+
+func parseRange(r Range) ([]subnet, error) {
+	exclude := make([]netip.Prefix, 0, len(r.Exclude))
+	for _, e := range r.Exclude {
+		prefix, err := parsePrefix(e)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing excluded address %s: %v", e, err)
+		}
+		exclude = append(exclude, prefix)
+	}
+
+	ports, err := parsePorts(r.Ports)
+	if err != nil {
+		return nil, err
+	}
+	excludePorts, err := parsePorts(r.ExcludePorts)
+	if err != nil {
+		return nil, err
+	}
+	ports = removePorts(ports, excludePorts)
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports configured to sweep for cidrs %s", strings.Join(r.CIDRs, ","))
+	}
+
+	subnets := make([]subnet, 0, len(r.CIDRs))
+	for _, c := range r.CIDRs {
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing cidr %s: %v", c, err)
+		}
+		subnets = append(subnets, subnet{prefix: prefix.Masked(), exclude: exclude, ports: ports})
+	}
+	return subnets, nil
+}
+
+// parsePrefix parses a cidr or a single address
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		return netip.ParsePrefix(value)
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// parsePorts parses a list of ports and port ranges e.g. 443 or 8000-8010
+func parsePorts(values []string) ([]uint16, error) {
+	ports := make([]uint16, 0)
+	for _, value := range values {
+		from, to, isRange := strings.Cut(value, "-")
+		first, err := parsePort(from)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = parsePort(to); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, fmt.Errorf("error parsing port range %s: end is before start", value)
+			}
+		}
+		for port := int(first); port <= int(last); port++ {
+			ports = append(ports, uint16(port))
+		}
+	}
+	return ports, nil
+}
+
+func parsePort(value string) (uint16, error) {
+	port, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("error parsing port %s: not a valid port", value)
+	}
+	return uint16(port), nil
+}
+
+func removePorts(ports, excluded []uint16) []uint16 {
+	remaining := make([]uint16, 0, len(ports))
+	for _, port := range ports {
+		keep := true
+		for _, e := range excluded {
+			if port == e {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			remaining = append(remaining, port)
+		}
+	}
+	return remaining
+}
+
+func excluded(addr netip.Addr, exclude []netip.Prefix) bool {
+	for _, prefix := range exclude {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func addressCount(prefix netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits()))
+}
@@ -0,0 +1,179 @@
+package cidr
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type CIDRDiscoveryTests struct {
+	listeners []net.Listener
+	config    CIDRDiscoveryConfig
+	suite.Suite
+}
+
+func (t *CIDRDiscoveryTests) SetupTest() {
+	t.config = CIDRDiscoveryConfig{
+		source:       "legacy-vms",
+		rate:         1000,
+		timeout:      100 * time.Millisecond,
+		maxAddresses: 256,
+		parallel:     4,
+	}
+}
+
+func (t *CIDRDiscoveryTests) TearDownTest() {
+	for _, listener := range t.listeners {
+		listener.Close()
+	}
+	t.listeners = nil
+	viper.Reset()
+}
+
+func (t *CIDRDiscoveryTests) TestDiscoveryCreationErrors() {
+	config := t.config
+	config.source = ""
+	_, err := CreateCIDRDiscovery(config)
+	t.ErrorContains(err, "a valid source label for the cidr ranges is required")
+
+	_, err = CreateCIDRDiscovery(t.config)
+	t.ErrorContains(err, "at least one cidr range is required")
+
+	config = t.config
+	config.rate = int(time.Second) + 1
+	_, err = CreateCIDRDiscovery(config)
+	t.ErrorContains(err, "rate of 1000000001 connects a second is more than the max of 1000000000")
+
+	t.assertRangeError(Range{CIDRs: []string{"10.0.0.0/33"}, Ports: []string{"443"}}, "error parsing cidr 10.0.0.0/33")
+	t.assertRangeError(Range{CIDRs: []string{"10.0.0.0/24"}, Ports: []string{"https"}}, "error parsing port https")
+	t.assertRangeError(Range{CIDRs: []string{"10.0.0.0/24"}, Ports: []string{"9000-8000"}}, "end is before start")
+	t.assertRangeError(Range{CIDRs: []string{"10.0.0.0/24"}, Ports: []string{"443"}, ExcludePorts: []string{"443"}}, "no ports configured to sweep")
+	t.assertRangeError(Range{CIDRs: []string{"10.0.0.0/24"}, Ports: []string{"443"}, Exclude: []string{"10.0.0"}}, "error parsing excluded address 10.0.0")
+}
+
+func (t *CIDRDiscoveryTests) TestCapsAddressesPerScan() {
+	t.assertRangeError(Range{CIDRs: []string{"10.0.0.0/8"}, Ports: []string{"443"}}, "cidr ranges hold 16777216 addresses, more than the max of 256")
+	t.assertRangeError(Range{CIDRs: []string{"10.0.0.0/24", "10.0.1.0/31"}, Ports: []string{"443"}}, "cidr ranges hold 258 addresses")
+	t.assertRangeError(Range{CIDRs: []string{"fd00::/8"}, Ports: []string{"443"}}, "more than the max of 256")
+}
+
+func (t *CIDRDiscoveryTests) TestDiscoversOpenPorts() {
+	open := t.listen()
+	another := t.listen()
+	closed := t.closedPort()
+
+	t.config.ranges = []Range{{
+		CIDRs: []string{"127.0.0.1/32"},
+		Ports: []string{strconv.Itoa(open), strconv.Itoa(another), strconv.Itoa(closed)},
+	}}
+	targets := t.discover()
+
+	t.Len(targets, 2)
+	for _, target := range targets {
+		t.Equal("legacy-vms", target.Source)
+		t.Equal(CIDR, target.SourceType)
+		t.Equal("127.0.0.1/32", target.Metadata.Labels[Subnet])
+		t.Contains([]string{strconv.Itoa(open), strconv.Itoa(another)}, target.Metadata.Labels[Port])
+		t.IsType(&NetIPAddress{}, target.Address)
+	}
+}
+
+func (t *CIDRDiscoveryTests) TestExcludesAddressesAndPorts() {
+	open := t.listen()
+	another := t.listen()
+
+	t.config.ranges = []Range{
+		{CIDRs: []string{"127.0.0.0/30"}, Ports: []string{strconv.Itoa(open)}, Exclude: []string{"127.0.0.0/31", "127.0.0.2", "127.0.0.3"}},
+		{CIDRs: []string{"127.0.0.1/32"}, Ports: []string{strconv.Itoa(open), strconv.Itoa(another)}, ExcludePorts: []string{strconv.Itoa(open)}},
+	}
+	targets := t.discover()
+
+	t.Len(targets, 1)
+	t.Equal(netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), uint16(another)).String(), targets[0].Address.String())
+}
+
+func (t *CIDRDiscoveryTests) TestExpandsPortRanges() {
+	ports, err := parsePorts([]string{"443", "8000-8002"})
+	t.NoError(err)
+	t.Equal([]uint16{443, 8000, 8001, 8002}, ports)
+}
+
+func (t *CIDRDiscoveryTests) TestRespectsContext() {
+	t.config.ranges = []Range{{CIDRs: []string{"127.0.0.0/24"}, Ports: []string{"1-100"}}}
+	t.config.maxAddresses = 256
+	t.config.rate = 10
+	discovery, err := CreateCIDRDiscovery(t.config)
+	t.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	t.ErrorContains(discovery.Discover(ctx, make(chan *Target, 10)), "error sweeping cidr ranges")
+	t.Less(time.Since(started), time.Second)
+}
+
+func (t *CIDRDiscoveryTests) TestCreatesDiscoveryFromConfig() {
+	config.SetDefaults()
+	viper.Set(config.DiscoveryCIDRSource, "legacy-vms")
+	viper.Set(config.DiscoveryCIDRRanges, []map[string]interface{}{
+		{"cidrs": []string{"10.0.0.0/24"}, "ports": []interface{}{443, "8000-8001"}, "exclude": []string{"10.0.0.1"}},
+	})
+
+	discovery, err := CreateDiscovery()
+	t.NoError(err)
+	d := discovery.(*CIDRDiscovery)
+	t.Equal(1000, d.rate)
+	t.Equal(time.Second, d.timeout)
+	t.Equal([]uint16{443, 8000, 8001}, d.subnets[0].ports)
+	t.Equal("10.0.0.1/32", d.subnets[0].exclude[0].String())
+}
+
+func (t *CIDRDiscoveryTests) assertRangeError(r Range, expected string) {
+	config := t.config
+	config.ranges = []Range{r}
+	_, err := CreateCIDRDiscovery(config)
+	t.ErrorContains(err, expected)
+}
+
+func (t *CIDRDiscoveryTests) discover() []*Target {
+	discovery, err := CreateCIDRDiscovery(t.config)
+	t.NoError(err)
+
+	targets := make(chan *Target, 10)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	close(targets)
+
+	discovered := make([]*Target, 0)
+	for target := range targets {
+		discovered = append(discovered, target)
+	}
+	return discovered
+}
+
+func (t *CIDRDiscoveryTests) listen() int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	t.NoError(err)
+	t.listeners = append(t.listeners, listener)
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort finds a port with nothing listening on it
+func (t *CIDRDiscoveryTests) closedPort() int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	t.NoError(err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+func TestCIDRDiscovery(t *testing.T) {
+	suite.Run(t, &CIDRDiscoveryTests{})
+}
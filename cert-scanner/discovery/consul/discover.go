@@ -0,0 +1,314 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/sgargan/cert-scanner-darkly/utils"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	Consul     = "consul"
+	Service    = "service"
+	Node       = "node"
+	Datacenter = "datacenter"
+	Tags       = "tags"
+
+	Passing  = "passing"
+	Warning  = "warning"
+	Critical = "critical"
+)
+
+type ConsulDiscoveryConfig struct {
+	source       string
+	address      string
+	datacenters  []string
+	services     []string
+	tags         []string
+	healthStatus string
+	token        string
+	tokenFile    string
+	caFile       string
+	timeout      time.Duration
+}
+
+type ConsulDiscovery struct {
+	address *url.URL
+	client  *http.Client
+	ConsulDiscoveryConfig
+}
+
+// serviceEntry is an instance of a service returned by the consul health api
+type serviceEntry struct {
+	Node struct {
+		Node       string
+		Address    string
+		Datacenter string
+	}
+	Service struct {
+		ID      string
+		Service string
+		Tags    []string
+		Address string
+		Port    int
+	}
+	Checks []struct {
+		Status string
+	}
+}
+
+// CreateDiscovery creates a Discovery instance that finds targets from the services registered in the
+// consul catalog
+func CreateDiscovery() (Discovery, error) {
+	cfg := ConsulDiscoveryConfig{
+		source:       viper.GetString(config.DiscoveryConsulSource),
+		address:      viper.GetString(config.DiscoveryConsulAddress),
+		datacenters:  viper.GetStringSlice(config.DiscoveryConsulDatacenters),
+		services:     viper.GetStringSlice(config.DiscoveryConsulServices),
+		tags:         viper.GetStringSlice(config.DiscoveryConsulTags),
+		healthStatus: viper.GetString(config.DiscoveryConsulHealthStatus),
+		token:        viper.GetString(config.DiscoveryConsulToken),
+		tokenFile:    viper.GetString(config.DiscoveryConsulTokenFile),
+		caFile:       viper.GetString(config.DiscoveryConsulCAFile),
+		timeout:      viper.GetDuration(config.DiscoveryConsulTimeout),
+	}
+	return CreateConsulDiscovery(cfg)
+}
+
+// CreateConsulDiscovery creates a discovery that queries the consul api at the configured address. Returns
+// an error if the address, health status or ca are invalid.
+func CreateConsulDiscovery(config ConsulDiscoveryConfig) (*ConsulDiscovery, error) {
+	slog.Info("creating consul discovery", "source", config.source, "address", config.address, "datacenters", config.datacenters, "services", config.services, "tags", config.tags)
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the consul catalog is required")
+	}
+	address, err := url.Parse(config.address)
+	if err != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+		return nil, fmt.Errorf("consul address %s is not a valid http(s) url", config.address)
+	}
+	if config.healthStatus != Passing && config.healthStatus != Warning {
+		return nil, fmt.Errorf("invalid health status %s, expected %s or %s", config.healthStatus, Passing, Warning)
+	}
+
+	transport, err := utils.CreateTransport(config.caFile, false)
+	if err != nil {
+		return nil, fmt.Errorf("error reading consul ca: %v", err)
+	}
+
+	return &ConsulDiscovery{
+		ConsulDiscoveryConfig: config,
+		address:               address,
+		client:                &http.Client{Timeout: config.timeout, Transport: transport},
+	}, nil
+}
+
+// Discover queries the catalog of each configured datacenter for the services with the configured tags,
+// emitting a [Target] for each of their healthy instances. Instances are labelled with their service,
+// node, datacenter and tags. Instances in every datacenter and service that could be listed are discovered,
+// then a [SourceError] for each that could not be is returned.
+func (d *ConsulDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	datacenters := d.datacenters
+	if len(datacenters) == 0 {
+		// the datacenter of the agent
+		datacenters = []string{""}
+	}
+
+	var errs error
+	numTargets := 0
+	for _, dc := range datacenters {
+		services, err := d.listServices(ctx, dc)
+		if err != nil {
+			errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error listing consul services in datacenter %s: %v", dc, err), d.source))
+			continue
+		}
+
+		for _, service := range services {
+			entries, err := d.listInstances(ctx, dc, service)
+			if err != nil {
+				errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error listing instances of consul service %s: %v", service, err), d.source))
+				continue
+			}
+			for _, entry := range entries {
+				if !d.isHealthy(entry) || !hasTags(entry.Service.Tags, d.tags) {
+					continue
+				}
+				target, err := d.createTarget(entry)
+				if err != nil {
+					errs = errors.Join(errs, CreateSourceError(err, d.source))
+					continue
+				}
+				numTargets++
+				targets <- target
+			}
+		}
+	}
+	slog.Info("finished consul discovery", "source", d.source, "targets", numTargets)
+	return errs
+}
+
+// listServices lists the configured services, or the services in the catalog of the datacenter with the
+// configured tags if none are
+func (d *ConsulDiscovery) listServices(ctx context.Context, dc string) ([]string, error) {
+	if len(d.services) > 0 {
+		return d.services, nil
+	}
+
+	catalog := make(map[string][]string)
+	if err := d.get(ctx, "/v1/catalog/services", query(dc), &catalog); err != nil {
+		return nil, err
+	}
+	services := make([]string, 0, len(catalog))
+	for service, tags := range catalog {
+		if hasTags(tags, d.tags) {
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// listInstances lists the instances of the service with the configured tags, along with their health
+func (d *ConsulDiscovery) listInstances(ctx context.Context, dc, service string) ([]serviceEntry, error) {
+	values := query(dc)
+	for _, tag := range d.tags {
+		values.Add("tag", tag)
+	}
+	if d.healthStatus == Passing {
+		values.Set("passing", "true")
+	}
+
+	entries := make([]serviceEntry, 0)
+	if err := d.get(ctx, "/v1/health/service/"+url.PathEscape(service), values, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (d *ConsulDiscovery) get(ctx context.Context, path string, values url.Values, result interface{}) error {
+	u := d.address.JoinPath(path)
+	u.RawQuery = values.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	token, err := d.getToken()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set("X-Consul-Token", token)
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
+
+// getToken returns the acl token, reading it from its file on each request so it can be rotated
+func (d *ConsulDiscovery) getToken() (string, error) {
+	if d.tokenFile == "" {
+		return d.token, nil
+	}
+	token, err := os.ReadFile(d.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading consul token: %v", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// isHealthy returns true if the worst status of the instance's checks is allowed by the configured status
+func (d *ConsulDiscovery) isHealthy(entry serviceEntry) bool {
+	status := Passing
+	for _, check := range entry.Checks {
+		switch check.Status {
+		case Passing:
+		case Warning:
+			if status == Passing {
+				status = Warning
+			}
+		default:
+			// critical and maintenance checks
+			status = Critical
+		}
+	}
+	return status == Passing || (status == Warning && d.healthStatus == Warning)
+}
+
+// createTarget creates a target for the service instance, at the address of the service or of its node
+// if the service has none
+func (d *ConsulDiscovery) createTarget(entry serviceEntry) (*Target, error) {
+	host := entry.Service.Address
+	if host == "" {
+		host = entry.Node.Address
+	}
+	if host == "" || entry.Service.Port <= 0 {
+		return nil, fmt.Errorf("consul service instance %s on node %s has no address and port", entry.Service.ID, entry.Node.Node)
+	}
+
+	var address Address
+	if ip, err := netip.ParseAddr(host); err == nil {
+		address = CreateNetIPAddress(netip.AddrPortFrom(ip, uint16(entry.Service.Port)))
+	} else {
+		address = CreateUrlAddress(&url.URL{Scheme: "tls", Host: net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))})
+	}
+
+	tags := slices.Clone(entry.Service.Tags)
+	sort.Strings(tags)
+	return &Target{
+		Address: address,
+		Metadata: Metadata{
+			Name:       entry.Service.ID,
+			Source:     d.source,
+			SourceType: Consul,
+			Labels: Labels{
+				Service:    entry.Service.Service,
+				Node:       entry.Node.Node,
+				Datacenter: entry.Node.Datacenter,
+				Tags:       strings.Join(tags, ","),
+			},
+		},
+	}, nil
+}
+
+func query(dc string) url.Values {
+	values := url.Values{}
+	if dc != "" {
+		values.Set("dc", dc)
+	}
+	return values
+}
+
+// hasTags returns true if all the required tags are present
+func hasTags(tags, required []string) bool {
+	for _, tag := range required {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
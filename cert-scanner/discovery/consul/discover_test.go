@@ -0,0 +1,228 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+// instance is a service instance registered with the stand in consul api
+type instance struct {
+	dc, node, nodeAddress, id, service, address string
+	port                                        int
+	tags                                        []string
+	status                                      string
+}
+
+type ConsulDiscoveryTests struct {
+	server    *httptest.Server
+	instances []instance
+	tokens    []string
+	config    ConsulDiscoveryConfig
+	suite.Suite
+}
+
+func (t *ConsulDiscoveryTests) SetupTest() {
+	t.instances = []instance{
+		{dc: "dc1", node: "node-1", nodeAddress: "10.0.0.1", id: "web-1", service: "web", port: 443, tags: []string{"tls", "public"}, status: Passing},
+		{dc: "dc1", node: "node-2", nodeAddress: "10.0.0.2", id: "web-2", service: "web", address: "web-2.internal", port: 8443, tags: []string{"public", "tls"}, status: Passing},
+		{dc: "dc1", node: "node-3", nodeAddress: "10.0.0.3", id: "web-3", service: "web", port: 443, tags: []string{"tls"}, status: Warning},
+		{dc: "dc1", node: "node-4", nodeAddress: "10.0.0.4", id: "web-4", service: "web", port: 443, tags: []string{"tls"}, status: Critical},
+		{dc: "dc1", node: "node-1", nodeAddress: "10.0.0.1", id: "db-1", service: "db", port: 5432, status: Passing},
+		{dc: "dc2", node: "node-5", nodeAddress: "10.1.0.1", id: "web-5", service: "web", port: 443, tags: []string{"tls"}, status: Passing},
+	}
+	t.tokens = nil
+	t.server = httptest.NewServer(http.HandlerFunc(t.serve))
+	t.config = ConsulDiscoveryConfig{
+		source:       "consul",
+		address:      t.server.URL,
+		healthStatus: Passing,
+		timeout:      time.Second,
+	}
+}
+
+func (t *ConsulDiscoveryTests) TearDownTest() {
+	t.server.Close()
+	viper.Reset()
+}
+
+func (t *ConsulDiscoveryTests) TestDiscoveryCreationErrors() {
+	t.assertCreationError(func(c *ConsulDiscoveryConfig) { c.source = "" }, "a valid source label for the consul catalog is required")
+	t.assertCreationError(func(c *ConsulDiscoveryConfig) { c.address = "consul:8500" }, "consul address consul:8500 is not a valid http(s) url")
+	t.assertCreationError(func(c *ConsulDiscoveryConfig) { c.healthStatus = "critical" }, "invalid health status critical")
+	t.assertCreationError(func(c *ConsulDiscoveryConfig) { c.caFile = "missing.pem" }, "error reading consul ca")
+}
+
+func (t *ConsulDiscoveryTests) TestDiscoversHealthyInstances() {
+	targets := t.discover()
+	t.Equal([]string{"db-1", "web-1", "web-2"}, targetNames(targets))
+
+	t.Equal(&Target{
+		Address: CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.1:443")),
+		Metadata: Metadata{
+			Name:       "web-1",
+			Source:     "consul",
+			SourceType: Consul,
+			Labels:     Labels{Service: "web", Node: "node-1", Datacenter: "dc1", Tags: "public,tls"},
+		},
+	}, targets[1])
+
+	address, _ := ParseUrlAddress("tls://web-2.internal:8443")
+	t.Equal(address, targets[2].Address)
+}
+
+func (t *ConsulDiscoveryTests) TestDiscoversInstancesWithWarnings() {
+	t.config.healthStatus = Warning
+	t.Equal([]string{"db-1", "web-1", "web-2", "web-3"}, targetNames(t.discover()))
+}
+
+func (t *ConsulDiscoveryTests) TestFiltersByTags() {
+	t.config.tags = []string{"tls", "public"}
+	t.Equal([]string{"web-1", "web-2"}, targetNames(t.discover()))
+}
+
+func (t *ConsulDiscoveryTests) TestFiltersByService() {
+	t.config.services = []string{"db"}
+	t.Equal([]string{"db-1"}, targetNames(t.discover()))
+}
+
+func (t *ConsulDiscoveryTests) TestDiscoversEachDatacenter() {
+	t.config.datacenters = []string{"dc1", "dc2"}
+	t.config.services = []string{"web"}
+	targets := t.discover()
+	t.Equal([]string{"web-1", "web-2", "web-5"}, targetNames(targets))
+	t.Equal("dc2", targets[2].Metadata.Labels[Datacenter])
+}
+
+func (t *ConsulDiscoveryTests) TestSendsToken() {
+	tokenFile := filepath.Join(t.T().TempDir(), "token")
+	t.NoError(os.WriteFile(tokenFile, []byte("some-token\n"), os.ModePerm))
+	t.config.tokenFile = tokenFile
+	t.config.services = []string{"db"}
+
+	t.discover()
+	t.Equal([]string{"some-token"}, t.tokens)
+}
+
+func (t *ConsulDiscoveryTests) TestApiErrorsRaiseError() {
+	t.config.datacenters = []string{"missing"}
+	discovery, err := CreateConsulDiscovery(t.config)
+	t.NoError(err)
+	t.ErrorContains(discovery.Discover(context.Background(), make(chan *Target, 10)), "error listing consul services in datacenter missing: unexpected status 500")
+}
+
+func (t *ConsulDiscoveryTests) TestDiscoversDatacentersThatCanBeListed() {
+	t.config.datacenters = []string{"missing", "dc2"}
+	discovery, err := CreateConsulDiscovery(t.config)
+	t.NoError(err)
+
+	targets := make(chan *Target, 10)
+	err = discovery.Discover(context.Background(), targets)
+	close(targets)
+	t.ErrorContains(err, "error listing consul services in datacenter missing: unexpected status 500")
+	t.Equal([]string{"consul"}, FailedSources(err))
+	t.Len(targets, 1)
+	t.Equal("web-5", (<-targets).Name)
+}
+
+func (t *ConsulDiscoveryTests) TestCreatesDiscoveryFromConfig() {
+	config.SetDefaults()
+	viper.Set(config.DiscoveryConsulSource, "consul")
+	viper.Set(config.DiscoveryConsulTags, []string{"tls"})
+
+	discovery, err := CreateDiscovery()
+	t.NoError(err)
+	d := discovery.(*ConsulDiscovery)
+	t.Equal("http://127.0.0.1:8500", d.address.String())
+	t.Equal(Passing, d.healthStatus)
+	t.Equal(10*time.Second, d.client.Timeout)
+	t.Equal([]string{"tls"}, d.tags)
+}
+
+func (t *ConsulDiscoveryTests) assertCreationError(configure func(c *ConsulDiscoveryConfig), expected string) {
+	config := t.config
+	configure(&config)
+	_, err := CreateConsulDiscovery(config)
+	t.ErrorContains(err, expected)
+}
+
+func (t *ConsulDiscoveryTests) discover() []*Target {
+	discovery, err := CreateConsulDiscovery(t.config)
+	t.NoError(err)
+
+	targets := make(chan *Target, 10)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	close(targets)
+
+	discovered := make([]*Target, 0)
+	for target := range targets {
+		discovered = append(discovered, target)
+	}
+	return discovered
+}
+
+// serve stands in for the consul catalog and health apis
+func (t *ConsulDiscoveryTests) serve(w http.ResponseWriter, r *http.Request) {
+	t.tokens = append(t.tokens, r.Header.Get("X-Consul-Token"))
+	dc := r.URL.Query().Get("dc")
+	if dc == "" {
+		dc = "dc1"
+	}
+	if dc != "dc1" && dc != "dc2" {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Path == "/v1/catalog/services" {
+		services := map[string][]string{}
+		for _, i := range t.instances {
+			if i.dc == dc {
+				services[i.service] = append(services[i.service], i.tags...)
+			}
+		}
+		json.NewEncoder(w).Encode(services)
+		return
+	}
+
+	service := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+	entries := make([]serviceEntry, 0)
+	for _, i := range t.instances {
+		if i.dc != dc || i.service != service || !hasTags(i.tags, r.URL.Query()["tag"]) {
+			continue
+		}
+		if r.URL.Query().Get("passing") == "true" && i.status != Passing {
+			continue
+		}
+		entry := serviceEntry{}
+		entry.Node.Node, entry.Node.Address, entry.Node.Datacenter = i.node, i.nodeAddress, i.dc
+		entry.Service.ID, entry.Service.Service, entry.Service.Address = i.id, i.service, i.address
+		entry.Service.Port, entry.Service.Tags = i.port, i.tags
+		entry.Checks = append(entry.Checks, struct{ Status string }{Passing}, struct{ Status string }{i.status})
+		entries = append(entries, entry)
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+func targetNames(targets []*Target) []string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	return names
+}
+
+func TestConsulDiscovery(t *testing.T) {
+	suite.Run(t, &ConsulDiscoveryTests{})
+}
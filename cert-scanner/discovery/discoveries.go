@@ -2,15 +2,31 @@ package discovery
 
 import (
 	"github.com/sgargan/cert-scanner-darkly/config"
+	"github.com/sgargan/cert-scanner-darkly/discovery/cidr"
+	"github.com/sgargan/cert-scanner-darkly/discovery/consul"
+	"github.com/sgargan/cert-scanner-darkly/discovery/dns"
+	"github.com/sgargan/cert-scanner-darkly/discovery/docker"
 	"github.com/sgargan/cert-scanner-darkly/discovery/file"
+	"github.com/sgargan/cert-scanner-darkly/discovery/httpsd"
 	"github.com/sgargan/cert-scanner-darkly/discovery/kubernetes"
+	"github.com/sgargan/cert-scanner-darkly/discovery/portscan"
 
 	. "github.com/sgargan/cert-scanner-darkly/types"
 )
 
 var factories = map[string]Factory[Discovery]{
-	"kubernetes": kubernetes.CreateDiscovery,
-	"files":      file.CreateDiscovery,
+	"kubernetes":          kubernetes.CreateDiscovery,
+	"kubernetes_services": kubernetes.CreateServiceDiscoveryFromConfig,
+	"kubernetes_ingress":  kubernetes.CreateIngressDiscoveryFromConfig,
+	"kubernetes_secrets":  kubernetes.CreateSecretDiscoveryFromConfig,
+	"cert_manager":        kubernetes.CreateCertManagerDiscoveryFromConfig,
+	"files":               file.CreateDiscovery,
+	"cidr":                cidr.CreateDiscovery,
+	"http_sd":             httpsd.CreateDiscovery,
+	"consul":              consul.CreateDiscovery,
+	"docker":              docker.CreateDiscovery,
+	"port_scans":          portscan.CreateDiscovery,
+	"dns":                 dns.CreateDiscovery,
 }
 
 func CreateDiscoveries() (Discoveries, error) {
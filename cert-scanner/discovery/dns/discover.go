@@ -0,0 +1,233 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/sgargan/cert-scanner-darkly/utils"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	DNS        = "dns"
+	RecordType = "record_type"
+	Zone       = "zone"
+	SRVName    = "srv_name"
+)
+
+// ZoneFile is a BIND format zone file, with the origin of its relative names if the file sets none
+type ZoneFile struct {
+	Path   string `mapstructure:"path"`
+	Origin string `mapstructure:"origin"`
+}
+
+type DNSDiscoveryConfig struct {
+	source    string
+	server    string
+	srv       []string
+	zoneFiles []ZoneFile
+	include   []string
+	exclude   []string
+	ports     []int
+	timeout   time.Duration
+}
+
+type DNSDiscovery struct {
+	resolver *net.Resolver
+	filter   *utils.Filter
+	DNSDiscoveryConfig
+}
+
+// CreateDiscovery creates a Discovery instance that finds targets from dns srv records and zone files
+func CreateDiscovery() (Discovery, error) {
+	var zoneFiles []ZoneFile
+	if err := viper.UnmarshalKey(config.DiscoveryDNSZoneFiles, &zoneFiles); err != nil {
+		return nil, fmt.Errorf("error parsing zone files: %v", err)
+	}
+	var ports []int
+	if err := viper.UnmarshalKey(config.DiscoveryDNSPorts, &ports); err != nil {
+		return nil, fmt.Errorf("error parsing ports: %v", err)
+	}
+
+	cfg := DNSDiscoveryConfig{
+		source:    viper.GetString(config.DiscoveryDNSSource),
+		server:    viper.GetString(config.DiscoveryDNSServer),
+		srv:       viper.GetStringSlice(config.DiscoveryDNSSRV),
+		zoneFiles: zoneFiles,
+		include:   viper.GetStringSlice(config.DiscoveryDNSInclude),
+		exclude:   viper.GetStringSlice(config.DiscoveryDNSExclude),
+		ports:     ports,
+		timeout:   viper.GetDuration(config.DiscoveryDNSTimeout),
+	}
+	return CreateDNSDiscovery(cfg)
+}
+
+// CreateDNSDiscovery creates a discovery that looks up the configured srv records and parses the configured
+// zone files. Srv records are looked up with the given dns server, or the system resolver if none is given.
+func CreateDNSDiscovery(config DNSDiscoveryConfig) (*DNSDiscovery, error) {
+	slog.Info("creating dns discovery", "source", config.source, "server", config.server, "srv", config.srv, "zoneFiles", len(config.zoneFiles))
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the dns records is required")
+	}
+	if len(config.srv) == 0 && len(config.zoneFiles) == 0 {
+		return nil, fmt.Errorf("at least one srv record or zone file is required")
+	}
+	for _, port := range config.ports {
+		if port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+	}
+	filter, err := utils.CreateFilter(config.include, config.exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := net.DefaultResolver
+	if config.server != "" {
+		if _, _, err := net.SplitHostPort(config.server); err != nil {
+			return nil, fmt.Errorf("dns server %s is not a valid host:port", config.server)
+		}
+		server := config.server
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	return &DNSDiscovery{
+		DNSDiscoveryConfig: config,
+		resolver:           resolver,
+		filter:             filter,
+	}, nil
+}
+
+// Discover emits a [Target] for each target of the configured srv records, on the port of the record, and
+// for each configured port of the A, AAAA and CNAME names in the zone files that match the include and
+// exclude patterns. Targets are tls urls, so their dns name is presented as SNI and validated. Targets from
+// every record and file that could be read are discovered, then a [SourceError] for each that could not be
+// is returned.
+func (d *DNSDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	var errs error
+	numTargets := 0
+	for _, name := range d.srv {
+		found, err := d.lookupSRV(ctx, name)
+		if err != nil {
+			errs = errors.Join(errs, CreateSourceError(err, d.source))
+			continue
+		}
+		for _, target := range found {
+			numTargets++
+			targets <- target
+		}
+	}
+
+	for _, zoneFile := range d.zoneFiles {
+		found, err := d.parseZoneFile(zoneFile)
+		if err != nil {
+			errs = errors.Join(errs, CreateSourceError(err, d.source))
+		}
+		for _, target := range found {
+			numTargets++
+			targets <- target
+		}
+	}
+	slog.Info("finished dns discovery", "source", d.source, "targets", numTargets)
+	return errs
+}
+
+// lookupSRV creates a target for each target of the srv record, ordered by priority and weight
+func (d *DNSDiscovery) lookupSRV(ctx context.Context, name string) ([]*Target, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up srv record %s: %v", name, err)
+	}
+
+	targets := make([]*Target, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		if host == "" {
+			// a target of . means the service is not available
+			continue
+		}
+		targets = append(targets, d.createTarget(host, int(srv.Port), Labels{RecordType: "SRV", SRVName: name}))
+	}
+	return targets, nil
+}
+
+// parseZoneFile creates a target for each configured port of each name in the zone file that matches the
+// patterns. Names with several records have a target per port, labelled with each record type.
+func (d *DNSDiscovery) parseZoneFile(zoneFile ZoneFile) ([]*Target, error) {
+	file, err := os.Open(zoneFile.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading zone file %s: %v", zoneFile.Path, err)
+	}
+	defer file.Close()
+
+	records, err := parseZone(file, zoneFile.Origin)
+	if err != nil {
+		err = fmt.Errorf("error parsing zone file %s: %v", zoneFile.Path, err)
+		if records == nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0)
+	recordTypes := make(map[string][]string)
+	for _, r := range records {
+		if strings.HasPrefix(r.name, "*") || !d.filter.Matches(r.name) {
+			continue
+		}
+		if _, seen := recordTypes[r.name]; !seen {
+			names = append(names, r.name)
+		}
+		if !contains(recordTypes[r.name], r.recordType) {
+			recordTypes[r.name] = append(recordTypes[r.name], r.recordType)
+		}
+	}
+
+	targets := make([]*Target, 0, len(names)*len(d.ports))
+	for _, name := range names {
+		types := recordTypes[name]
+		sort.Strings(types)
+		for _, port := range d.ports {
+			targets = append(targets, d.createTarget(name, port, Labels{RecordType: strings.Join(types, ","), Zone: zoneFile.Path}))
+		}
+	}
+	return targets, err
+}
+
+func (d *DNSDiscovery) createTarget(host string, port int, labels Labels) *Target {
+	return &Target{
+		Address: CreateUrlAddress(&url.URL{Scheme: "tls", Host: net.JoinHostPort(host, strconv.Itoa(port))}),
+		Metadata: Metadata{
+			Name:       host,
+			Source:     d.source,
+			SourceType: DNS,
+			Labels:     labels,
+		},
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const testZone = `$TTL 3600
+$ORIGIN example.com.
+@       IN  SOA ns1.example.com. admin.example.com. (
+                2024010101 ; serial
+                7200       ; refresh
+                3600 )     ; retry
+        IN  NS  ns1
+        IN  A   192.0.2.1
+ns1     IN  A   192.0.2.2
+www     300 IN  A     192.0.2.3
+www         IN  AAAA  2001:db8::3
+api         CNAME www.example.com.
+mail        IN  MX  10 mail.example.com.
+*.apps      IN  A   192.0.2.4
+admin.internal.example.com. IN A 192.0.2.5
+$ORIGIN dev.example.com.
+test        IN  A   192.0.2.6 ; dev test host
+`
+
+type DNSDiscoveryTests struct {
+	dir    string
+	config DNSDiscoveryConfig
+	suite.Suite
+}
+
+func (t *DNSDiscoveryTests) SetupTest() {
+	t.dir = t.T().TempDir()
+	t.config = DNSDiscoveryConfig{source: "corp-dns", ports: []int{443}, timeout: 5 * time.Second}
+}
+
+func (t *DNSDiscoveryTests) TearDownTest() {
+	viper.Reset()
+}
+
+func (t *DNSDiscoveryTests) TestDiscoveryCreationErrors() {
+	_, err := CreateDNSDiscovery(DNSDiscoveryConfig{srv: []string{"_https._tcp.example.com"}})
+	t.ErrorContains(err, "a valid source label for the dns records is required")
+
+	_, err = CreateDNSDiscovery(DNSDiscoveryConfig{source: "corp-dns"})
+	t.ErrorContains(err, "at least one srv record or zone file is required")
+
+	config := t.config
+	config.srv = []string{"_https._tcp.example.com"}
+	config.ports = []int{0}
+	_, err = CreateDNSDiscovery(config)
+	t.ErrorContains(err, "invalid port 0")
+
+	config.ports = []int{443}
+	config.include = []string{"("}
+	_, err = CreateDNSDiscovery(config)
+	t.ErrorContains(err, "error parsing include patterns")
+
+	config.include = nil
+	config.server = "10.0.0.53"
+	_, err = CreateDNSDiscovery(config)
+	t.ErrorContains(err, "dns server 10.0.0.53 is not a valid host:port")
+}
+
+func (t *DNSDiscoveryTests) TestParsesZoneRecords() {
+	records, err := parseZone(strings.NewReader(testZone), "")
+	t.NoError(err)
+	t.Equal([]record{
+		{name: "example.com", recordType: "A", line: 8},
+		{name: "ns1.example.com", recordType: "A", line: 9},
+		{name: "www.example.com", recordType: "A", line: 10},
+		{name: "www.example.com", recordType: "AAAA", line: 11},
+		{name: "api.example.com", recordType: "CNAME", line: 12},
+		{name: "*.apps.example.com", recordType: "A", line: 14},
+		{name: "admin.internal.example.com", recordType: "A", line: 15},
+		{name: "test.dev.example.com", recordType: "A", line: 17},
+	}, records)
+}
+
+func (t *DNSDiscoveryTests) TestRelativeNamesRequireOrigin() {
+	records, err := parseZone(strings.NewReader("www IN A 192.0.2.3\nhost.example.org. IN A 192.0.2.4\n"), "")
+	t.ErrorContains(err, "line 1: relative name www with no $ORIGIN")
+	t.Equal([]record{{name: "host.example.org", recordType: "A", line: 2}}, records)
+
+	records, err = parseZone(strings.NewReader("www IN A 192.0.2.3\n"), "example.org")
+	t.NoError(err)
+	t.Equal([]record{{name: "www.example.org", recordType: "A", line: 1}}, records)
+}
+
+func (t *DNSDiscoveryTests) TestDiscoversZoneNames() {
+	t.config.zoneFiles = []ZoneFile{{Path: t.writeZone("example.com.zone", testZone)}}
+	targets, err := t.discover()
+	t.NoError(err)
+	t.Equal(tlsAddresses(
+		"example.com:443",
+		"ns1.example.com:443",
+		"www.example.com:443",
+		"api.example.com:443",
+		"admin.internal.example.com:443",
+		"test.dev.example.com:443",
+	), addresses(targets))
+
+	t.Equal(&Target{
+		Address: CreateUrlAddress(&url.URL{Scheme: "tls", Host: "www.example.com:443"}),
+		Metadata: Metadata{
+			Name:       "www.example.com",
+			Source:     "corp-dns",
+			SourceType: DNS,
+			Labels:     Labels{RecordType: "A,AAAA", Zone: t.config.zoneFiles[0].Path},
+		},
+	}, targets[2])
+	t.Equal("www.example.com", targets[2].Address.ServerName())
+}
+
+func (t *DNSDiscoveryTests) TestFiltersZoneNamesWithPatterns() {
+	t.config.zoneFiles = []ZoneFile{{Path: t.writeZone("example.com.zone", testZone)}}
+	t.config.include = []string{`^(www|api|admin)\.`}
+	t.config.exclude = []string{`\.internal\.`}
+	t.config.ports = []int{443, 8443}
+	targets, err := t.discover()
+	t.NoError(err)
+	t.Equal(tlsAddresses(
+		"www.example.com:443",
+		"www.example.com:8443",
+		"api.example.com:443",
+		"api.example.com:8443",
+	), addresses(targets))
+}
+
+func (t *DNSDiscoveryTests) TestDiscoversValidZoneFiles() {
+	t.config.zoneFiles = []ZoneFile{
+		{Path: t.writeZone("partial.zone", "www IN A 192.0.2.3\nhost.example.org. IN A 192.0.2.4\n")},
+		{Path: filepath.Join(t.dir, "missing.zone")},
+		{Path: t.writeZone("example.com.zone", testZone)},
+	}
+	targets, err := t.discover()
+	t.Len(targets, 7)
+	t.ErrorContains(err, "error parsing zone file "+t.config.zoneFiles[0].Path+": line 1: relative name www with no $ORIGIN")
+	t.ErrorContains(err, "error reading zone file "+t.config.zoneFiles[1].Path)
+	t.Equal([]string{t.config.source}, FailedSources(err))
+}
+
+func (t *DNSDiscoveryTests) TestDiscoversSRVTargets() {
+	t.config.server = t.startServer(map[string][]dnsmessage.SRVResource{
+		"_https._tcp.example.com.": {
+			{Priority: 10, Weight: 5, Port: 8443, Target: dnsmessage.MustNewName("web1.example.com.")},
+			{Priority: 20, Weight: 5, Port: 9443, Target: dnsmessage.MustNewName("web2.example.com.")},
+		},
+		"_ldaps._tcp.example.com.": {
+			{Priority: 0, Weight: 0, Port: 0, Target: dnsmessage.MustNewName(".")},
+		},
+	})
+	t.config.srv = []string{"_https._tcp.example.com", "_ldaps._tcp.example.com"}
+	targets, err := t.discover()
+	t.NoError(err)
+	t.Equal(tlsAddresses("web1.example.com:8443", "web2.example.com:9443"), addresses(targets))
+	t.Equal(Labels{RecordType: "SRV", SRVName: "_https._tcp.example.com"}, targets[0].Metadata.Labels)
+	t.Equal("web1.example.com", targets[0].Name)
+
+	t.config.srv = []string{"_imaps._tcp.example.com"}
+	targets, err = t.discover()
+	t.Empty(targets)
+	t.ErrorContains(err, "error looking up srv record _imaps._tcp.example.com")
+}
+
+func (t *DNSDiscoveryTests) TestCreatesDiscoveryFromConfig() {
+	config.SetDefaults()
+	viper.Set(config.DiscoveryDNSSource, "corp-dns")
+	viper.Set(config.DiscoveryDNSServer, "10.0.0.53:53")
+	viper.Set(config.DiscoveryDNSZoneFiles, []map[string]interface{}{{"path": "/zones/example.com.zone", "origin": "example.com"}})
+
+	discovery, err := CreateDiscovery()
+	t.NoError(err)
+	d := discovery.(*DNSDiscovery)
+	t.Equal([]ZoneFile{{Path: "/zones/example.com.zone", Origin: "example.com"}}, d.zoneFiles)
+	t.Equal([]int{443}, d.ports)
+	t.Equal(5*time.Second, d.timeout)
+	t.NotEqual(net.DefaultResolver, d.resolver)
+}
+
+func (t *DNSDiscoveryTests) writeZone(name, content string) string {
+	path := filepath.Join(t.dir, name)
+	t.NoError(os.WriteFile(path, []byte(content), os.ModePerm))
+	return path
+}
+
+// startServer starts a udp dns server answering srv queries for the given records, returning its address
+func (t *DNSDiscoveryTests) startServer(records map[string][]dnsmessage.SRVResource) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	t.NoError(err)
+	t.T().Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var request dnsmessage.Message
+			if err := request.Unpack(buf[:n]); err != nil || len(request.Questions) == 0 {
+				continue
+			}
+			question := request.Questions[0]
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: request.ID, Response: true, Authoritative: true},
+				Questions: request.Questions,
+			}
+			srvs, found := records[question.Name.String()]
+			if !found {
+				response.RCode = dnsmessage.RCodeNameError
+			}
+			if question.Type == dnsmessage.TypeSRV {
+				for _, srv := range srvs {
+					srv := srv
+					response.Answers = append(response.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET, TTL: 60},
+						Body:   &srv,
+					})
+				}
+			}
+			packed, err := response.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func (t *DNSDiscoveryTests) discover() ([]*Target, error) {
+	discovery, err := CreateDNSDiscovery(t.config)
+	t.NoError(err)
+
+	targets := make(chan *Target, 20)
+	err = discovery.Discover(context.Background(), targets)
+	close(targets)
+
+	discovered := make([]*Target, 0)
+	for target := range targets {
+		discovered = append(discovered, target)
+	}
+	return discovered, err
+}
+
+// addresses returns the address of each target, compared in full as url addresses only report their hostname
+func addresses(targets []*Target) []Address {
+	addresses := make([]Address, 0, len(targets))
+	for _, target := range targets {
+		addresses = append(addresses, target.Address)
+	}
+	return addresses
+}
+
+func tlsAddresses(hosts ...string) []Address {
+	addresses := make([]Address, 0, len(hosts))
+	for _, host := range hosts {
+		addresses = append(addresses, CreateUrlAddress(&url.URL{Scheme: "tls", Host: host}))
+	}
+	return addresses
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	suite.Run(t, &DNSDiscoveryTests{})
+}
@@ -0,0 +1,159 @@
+package dns
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// record is an address or alias record parsed from a zone file
+type record struct {
+	name       string
+	recordType string
+	line       int
+}
+
+var addressTypes = map[string]bool{"A": true, "AAAA": true, "CNAME": true}
+
+var classes = map[string]bool{"IN": true, "CH": true, "HS": true, "CS": true}
+
+// parseZone parses the A, AAAA and CNAME records from a BIND format zone file, returning their fully
+// qualified names without the trailing dot. Relative names are qualified with the $ORIGIN of the file, or
+// the given origin until one is set. Other record types are skipped, as are $TTL and $INCLUDE directives.
+func parseZone(reader io.Reader, origin string) ([]record, error) {
+	origin = qualify(origin, "")
+	records := make([]record, 0)
+	owner := ""
+	var errs error
+
+	lines := bufio.NewScanner(reader)
+	number := 0
+	for entry, start, ok := nextEntry(lines, &number); ok; entry, start, ok = nextEntry(lines, &number) {
+		fields := strings.Fields(entry.text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(fields[0], "$") {
+			switch strings.ToUpper(fields[0]) {
+			case "$ORIGIN":
+				if len(fields) < 2 {
+					errs = errors.Join(errs, fmt.Errorf("line %d: $ORIGIN requires a name", start))
+					continue
+				}
+				origin = qualify(fields[1], origin)
+			}
+			continue
+		}
+
+		// entries starting with whitespace belong to the previous owner
+		if !entry.continued {
+			owner = fields[0]
+			fields = fields[1:]
+		}
+		if owner == "" {
+			errs = errors.Join(errs, fmt.Errorf("line %d: record has no owner name", start))
+			continue
+		}
+
+		recordType := ""
+		for _, field := range fields {
+			if isTTL(field) || classes[strings.ToUpper(field)] {
+				continue
+			}
+			recordType = strings.ToUpper(field)
+			break
+		}
+		if !addressTypes[recordType] {
+			continue
+		}
+
+		name, err := qualifyOwner(owner, origin)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("line %d: %v", start, err))
+			continue
+		}
+		records = append(records, record{name: name, recordType: recordType, line: start})
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return records, errs
+}
+
+type zoneEntry struct {
+	text      string
+	continued bool
+}
+
+// nextEntry reads the next entry from the zone, joining entries split over lines with parentheses and
+// removing comments. Returns the line the entry started on.
+func nextEntry(lines *bufio.Scanner, number *int) (zoneEntry, int, bool) {
+	entry := zoneEntry{}
+	start := 0
+	depth := 0
+	for lines.Scan() {
+		*number++
+		line := stripComment(lines.Text())
+		if start == 0 {
+			start = *number
+			entry.continued = len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
+		}
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		entry.text += " " + strings.NewReplacer("(", " ", ")", " ").Replace(line)
+		if depth <= 0 {
+			return entry, start, true
+		}
+	}
+	return entry, start, start != 0
+}
+
+// stripComment removes a ; comment from the line, ignoring any within quoted strings
+func stripComment(line string) string {
+	quoted := false
+	for x, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			return line[:x]
+		}
+	}
+	return line
+}
+
+func qualifyOwner(owner, origin string) (string, error) {
+	if owner != "@" && strings.HasSuffix(owner, ".") {
+		return strings.TrimSuffix(owner, "."), nil
+	}
+	if origin == "" {
+		return "", fmt.Errorf("relative name %s with no $ORIGIN", owner)
+	}
+	if owner == "@" {
+		return strings.TrimSuffix(origin, "."), nil
+	}
+	return strings.TrimSuffix(owner+"."+origin, "."), nil
+}
+
+// qualify returns the fully qualified form of name, with a trailing dot, relative to origin
+func qualify(name, origin string) string {
+	if name == "" || strings.HasSuffix(name, ".") {
+		return name
+	}
+	if origin == "" {
+		return name + "."
+	}
+	return name + "." + origin
+}
+
+// isTTL returns true for ttls in seconds or with units e.g. 1h30m
+func isTTL(field string) bool {
+	if _, err := strconv.ParseUint(field, 10, 32); err == nil {
+		return true
+	}
+	trimmed := strings.TrimRight(strings.ToLower(field), "smhdw0123456789")
+	return trimmed == "" && field[0] >= '0' && field[0] <= '9'
+}
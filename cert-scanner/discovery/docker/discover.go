@@ -0,0 +1,317 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	Docker         = "docker"
+	Container      = "container"
+	Image          = "image"
+	ComposeProject = "compose_project"
+	ComposeService = "compose_service"
+	PortType       = "port_type"
+
+	// port types to discover
+	Published = "published"
+	Internal  = "internal"
+	All       = "all"
+
+	// IgnoreLabel opts a container out of discovery when set to true
+	IgnoreLabel = "cert-scanner.io/ignore"
+
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+
+	requestTimeout = 10 * time.Second
+)
+
+// IgnorePattern ignores containers with the given label. If match values are given, only containers where
+// the value of the label matches one of them are ignored.
+type IgnorePattern struct {
+	Label string   `mapstructure:"label"`
+	Match []string `mapstructure:"match"`
+}
+
+type parsedIgnorePattern struct {
+	label   string
+	matches []*regexp.Regexp
+}
+
+type DockerDiscoveryConfig struct {
+	source           string
+	socket           string
+	ports            string
+	hostAddress      string
+	ignoreContainers []IgnorePattern
+}
+
+type DockerDiscovery struct {
+	client           *http.Client
+	hostAddress      netip.Addr
+	ignoreContainers []parsedIgnorePattern
+	DockerDiscoveryConfig
+}
+
+// container is a container returned by the engine's container list api
+type container struct {
+	ID     string `json:"Id"`
+	Names  []string
+	Image  string
+	Labels map[string]string
+	Ports  []struct {
+		IP          string
+		PrivatePort uint16
+		PublicPort  uint16
+		Type        string
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string
+			GlobalIPv6Address string
+		}
+	}
+}
+
+// CreateDiscovery creates a Discovery instance that finds targets from the containers running on the
+// local docker engine
+func CreateDiscovery() (Discovery, error) {
+	var ignoreContainers []IgnorePattern
+	if err := viper.UnmarshalKey(config.DiscoveryDockerIgnoreContainers, &ignoreContainers); err != nil {
+		return nil, fmt.Errorf("error parsing ignore containers: %v", err)
+	}
+
+	cfg := DockerDiscoveryConfig{
+		source:           viper.GetString(config.DiscoveryDockerSource),
+		socket:           viper.GetString(config.DiscoveryDockerSocket),
+		ports:            viper.GetString(config.DiscoveryDockerPorts),
+		hostAddress:      viper.GetString(config.DiscoveryDockerHostAddress),
+		ignoreContainers: ignoreContainers,
+	}
+	return CreateDockerDiscovery(cfg)
+}
+
+// CreateDockerDiscovery creates a discovery that lists containers from the docker engine api served on the
+// configured unix socket. Returns an error if the port type, host address or ignore patterns are invalid.
+func CreateDockerDiscovery(config DockerDiscoveryConfig) (*DockerDiscovery, error) {
+	slog.Info("creating docker discovery", "source", config.source, "socket", config.socket, "ports", config.ports)
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the docker engine is required")
+	}
+	if config.ports != Published && config.ports != Internal && config.ports != All {
+		return nil, fmt.Errorf("invalid ports %s, expected one of %s, %s or %s", config.ports, Published, Internal, All)
+	}
+	hostAddress, err := netip.ParseAddr(config.hostAddress)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing host address %s: %v", config.hostAddress, err)
+	}
+	ignoreContainers, err := parseIgnorePatterns(config.ignoreContainers)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ignore container patterns: %v", err)
+	}
+
+	socket := config.socket
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}
+	return &DockerDiscovery{
+		DockerDiscoveryConfig: config,
+		client:                &http.Client{Timeout: requestTimeout, Transport: transport},
+		hostAddress:           hostAddress,
+		ignoreContainers:      ignoreContainers,
+	}, nil
+}
+
+// Discover lists the running containers, emitting a [Target] for each of their published ports on the
+// host and each of their internal ports on the container's network addresses. Containers with the
+// ignore label or matching an ignore pattern are skipped.
+func (d *DockerDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	containers, err := d.listContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing docker containers: %v", err)
+	}
+
+	numTargets := 0
+	for _, c := range containers {
+		if ignore, err := d.ignoreContainer(c); err != nil || ignore {
+			if err != nil {
+				slog.Warn("skipping container with invalid labels", "container", containerName(c), "err", err)
+			}
+			continue
+		}
+		for _, target := range d.createTargets(c) {
+			numTargets++
+			targets <- target
+		}
+	}
+	slog.Info("finished docker discovery", "source", d.source, "containers", len(containers), "targets", numTargets)
+	return nil
+}
+
+func (d *DockerDiscovery) listContainers(ctx context.Context) ([]container, error) {
+	// the host is ignored as requests are dialled over the socket
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := d.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	containers := make([]container, 0)
+	if err := json.NewDecoder(response.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("error decoding containers: %v", err)
+	}
+	return containers, nil
+}
+
+// createTargets creates a target for each distinct published and internal tcp port of the container
+func (d *DockerDiscovery) createTargets(c container) []*Target {
+	targets := make([]*Target, 0)
+	seen := make(map[netip.AddrPort]bool)
+	add := func(address netip.AddrPort, portType string) {
+		if seen[address] {
+			return
+		}
+		seen[address] = true
+		targets = append(targets, d.createTarget(c, address, portType))
+	}
+
+	for _, port := range c.Ports {
+		if port.Type != "tcp" {
+			continue
+		}
+		if port.PublicPort != 0 && d.ports != Internal {
+			add(netip.AddrPortFrom(d.publishedAddress(port.IP), port.PublicPort), Published)
+		}
+		if d.ports != Published {
+			for _, ip := range containerIPs(c) {
+				add(netip.AddrPortFrom(ip, port.PrivatePort), Internal)
+			}
+		}
+	}
+	return targets
+}
+
+// publishedAddress returns the host address a port is published on, using the configured host address
+// for ports published on all interfaces
+func (d *DockerDiscovery) publishedAddress(ip string) netip.Addr {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.IsUnspecified() {
+		return d.hostAddress
+	}
+	return addr
+}
+
+func (d *DockerDiscovery) createTarget(c container, address netip.AddrPort, portType string) *Target {
+	return &Target{
+		Address: CreateNetIPAddress(address),
+		Metadata: Metadata{
+			Name:       containerName(c),
+			Source:     d.source,
+			SourceType: Docker,
+			Labels: Labels{
+				Container:      containerName(c),
+				Image:          c.Image,
+				ComposeProject: c.Labels[composeProjectLabel],
+				ComposeService: c.Labels[composeServiceLabel],
+				PortType:       portType,
+			},
+		},
+	}
+}
+
+// ignoreContainer returns true if the container has opted out with the ignore label, or matches one of
+// the ignore patterns
+func (d *DockerDiscovery) ignoreContainer(c container) (bool, error) {
+	if value, ok := c.Labels[IgnoreLabel]; ok {
+		ignore, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("error parsing %s label: %v", IgnoreLabel, err)
+		}
+		if ignore {
+			slog.Debug("ignoring container with ignore label", "container", containerName(c))
+			return true, nil
+		}
+	}
+
+	for _, pattern := range d.ignoreContainers {
+		value, ok := c.Labels[pattern.label]
+		if !ok {
+			continue
+		}
+		// If no match values specified, the presence of the label means ignore
+		if len(pattern.matches) == 0 {
+			return true, nil
+		}
+		for _, match := range pattern.matches {
+			if match.MatchString(value) {
+				slog.Debug("ignoring due to pattern match", "container", containerName(c), "label", pattern.label, "value", value)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func parseIgnorePatterns(ignorePatterns []IgnorePattern) ([]parsedIgnorePattern, error) {
+	parsedPatterns := make([]parsedIgnorePattern, 0, len(ignorePatterns))
+	for _, pattern := range ignorePatterns {
+		if pattern.Label == "" {
+			return nil, fmt.Errorf("ignore patterns require a label")
+		}
+		matches := make([]*regexp.Regexp, 0, len(pattern.Match))
+		for _, match := range pattern.Match {
+			m, err := regexp.Compile(match)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, m)
+		}
+		parsedPatterns = append(parsedPatterns, parsedIgnorePattern{label: pattern.Label, matches: matches})
+	}
+	return parsedPatterns, nil
+}
+
+// containerIPs returns the addresses of the container on each of its networks
+func containerIPs(c container) []netip.Addr {
+	ips := make([]netip.Addr, 0)
+	for _, network := range c.NetworkSettings.Networks {
+		for _, ip := range []string{network.IPAddress, network.GlobalIPv6Address} {
+			if addr, err := netip.ParseAddr(ip); err == nil {
+				ips = append(ips, addr)
+			}
+		}
+	}
+	slices.SortFunc(ips, func(a, b netip.Addr) int { return a.Compare(b) })
+	return ips
+}
+
+func containerName(c container) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID
+}
@@ -0,0 +1,196 @@
+package docker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+const testContainers = `[
+	{
+		"Id": "0123456789ab",
+		"Names": ["/shop-web-1"],
+		"Image": "nginx:1.27",
+		"Labels": {"com.docker.compose.project": "shop", "com.docker.compose.service": "web"},
+		"Ports": [
+			{"IP": "0.0.0.0", "PrivatePort": 443, "PublicPort": 8443, "Type": "tcp"},
+			{"IP": "::", "PrivatePort": 443, "PublicPort": 8443, "Type": "tcp"},
+			{"PrivatePort": 80, "Type": "tcp"},
+			{"PrivatePort": 53, "Type": "udp"}
+		],
+		"NetworkSettings": {"Networks": {"shop_default": {"IPAddress": "172.18.0.2"}}}
+	},
+	{
+		"Id": "123456789abc",
+		"Names": ["/shop-db-1"],
+		"Image": "postgres:16",
+		"Labels": {"com.docker.compose.project": "shop", "com.docker.compose.service": "db", "cert-scanner.io/ignore": "true"},
+		"Ports": [{"PrivatePort": 5432, "Type": "tcp"}],
+		"NetworkSettings": {"Networks": {"shop_default": {"IPAddress": "172.18.0.3"}}}
+	},
+	{
+		"Id": "23456789abcd",
+		"Names": ["/buildkit"],
+		"Image": "moby/buildkit:latest",
+		"Labels": {"role": "builder"},
+		"Ports": [{"IP": "127.0.0.1", "PrivatePort": 1234, "PublicPort": 1234, "Type": "tcp"}],
+		"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}
+	}
+]`
+
+type DockerDiscoveryTests struct {
+	socket     string
+	server     *http.Server
+	containers string
+	config     DockerDiscoveryConfig
+	suite.Suite
+}
+
+func (t *DockerDiscoveryTests) SetupTest() {
+	dir, err := os.MkdirTemp("", "docker")
+	t.NoError(err)
+	t.socket = filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", t.socket)
+	t.NoError(err)
+
+	t.containers = testContainers
+	t.server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(t.containers))
+	})}
+	go t.server.Serve(listener)
+
+	t.config = DockerDiscoveryConfig{
+		source:      "build-host",
+		socket:      t.socket,
+		ports:       All,
+		hostAddress: "127.0.0.1",
+	}
+}
+
+func (t *DockerDiscoveryTests) TearDownTest() {
+	t.server.Close()
+	os.RemoveAll(filepath.Dir(t.socket))
+	viper.Reset()
+}
+
+func (t *DockerDiscoveryTests) TestDiscoveryCreationErrors() {
+	t.assertCreationError(func(c *DockerDiscoveryConfig) { c.source = "" }, "a valid source label for the docker engine is required")
+	t.assertCreationError(func(c *DockerDiscoveryConfig) { c.ports = "some" }, "invalid ports some")
+	t.assertCreationError(func(c *DockerDiscoveryConfig) { c.hostAddress = "localhost" }, "error parsing host address localhost")
+	t.assertCreationError(func(c *DockerDiscoveryConfig) {
+		c.ignoreContainers = []IgnorePattern{{Label: "role", Match: []string{"("}}}
+	}, "error parsing ignore container patterns")
+}
+
+func (t *DockerDiscoveryTests) TestDiscoversContainerPorts() {
+	targets := t.discover()
+	t.Equal([]string{"127.0.0.1:8443", "172.18.0.2:443", "172.18.0.2:80", "127.0.0.1:1234", "172.17.0.2:1234"}, addresses(targets))
+
+	t.Equal(&Target{
+		Address: CreateNetIPAddress(netip.MustParseAddrPort("127.0.0.1:8443")),
+		Metadata: Metadata{
+			Name:       "shop-web-1",
+			Source:     "build-host",
+			SourceType: Docker,
+			Labels: Labels{
+				Container:      "shop-web-1",
+				Image:          "nginx:1.27",
+				ComposeProject: "shop",
+				ComposeService: "web",
+				PortType:       Published,
+			},
+		},
+	}, targets[0])
+	t.Equal(Internal, targets[1].Metadata.Labels[PortType])
+}
+
+func (t *DockerDiscoveryTests) TestDiscoversPortTypes() {
+	t.config.ports = Published
+	t.config.hostAddress = "10.0.0.1"
+	t.Equal([]string{"10.0.0.1:8443", "127.0.0.1:1234"}, addresses(t.discover()))
+
+	t.config.ports = Internal
+	t.Equal([]string{"172.18.0.2:443", "172.18.0.2:80", "172.17.0.2:1234"}, addresses(t.discover()))
+}
+
+func (t *DockerDiscoveryTests) TestIgnoresContainersMatchingPatterns() {
+	t.config.ignoreContainers = []IgnorePattern{{Label: "role", Match: []string{"^build"}}}
+	t.Equal([]string{"127.0.0.1:8443", "172.18.0.2:443", "172.18.0.2:80"}, addresses(t.discover()))
+
+	t.config.ignoreContainers = []IgnorePattern{{Label: "com.docker.compose.project"}}
+	t.Equal([]string{"127.0.0.1:1234", "172.17.0.2:1234"}, addresses(t.discover()))
+}
+
+func (t *DockerDiscoveryTests) TestSkipsContainersWithInvalidIgnoreLabel() {
+	t.containers = `[{"Names": ["/web"], "Labels": {"cert-scanner.io/ignore": "maybe"}, "Ports": [{"PrivatePort": 443, "Type": "tcp"}],
+		"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}}]`
+	t.Empty(t.discover())
+}
+
+func (t *DockerDiscoveryTests) TestEngineErrorsRaiseError() {
+	t.config.socket = filepath.Join(filepath.Dir(t.socket), "missing.sock")
+	discovery, err := CreateDockerDiscovery(t.config)
+	t.NoError(err)
+	t.ErrorContains(discovery.Discover(context.Background(), make(chan *Target, 10)), "error listing docker containers")
+}
+
+func (t *DockerDiscoveryTests) TestCreatesDiscoveryFromConfig() {
+	config.SetDefaults()
+	viper.Set(config.DiscoveryDockerSource, "build-host")
+	viper.Set(config.DiscoveryDockerIgnoreContainers, []map[string]interface{}{{"label": "role", "match": []string{"builder"}}})
+
+	discovery, err := CreateDiscovery()
+	t.NoError(err)
+	d := discovery.(*DockerDiscovery)
+	t.Equal("/var/run/docker.sock", d.socket)
+	t.Equal(All, d.ports)
+	t.Equal("127.0.0.1", d.hostAddress.String())
+	t.Equal("role", d.ignoreContainers[0].label)
+}
+
+func (t *DockerDiscoveryTests) assertCreationError(configure func(c *DockerDiscoveryConfig), expected string) {
+	config := t.config
+	configure(&config)
+	_, err := CreateDockerDiscovery(config)
+	t.ErrorContains(err, expected)
+}
+
+func (t *DockerDiscoveryTests) discover() []*Target {
+	discovery, err := CreateDockerDiscovery(t.config)
+	t.NoError(err)
+
+	targets := make(chan *Target, 10)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	close(targets)
+
+	discovered := make([]*Target, 0)
+	for target := range targets {
+		discovered = append(discovered, target)
+	}
+	return discovered
+}
+
+func addresses(targets []*Target) []string {
+	addresses := make([]string, 0, len(targets))
+	for _, target := range targets {
+		addresses = append(addresses, target.Address.String())
+	}
+	return addresses
+}
+
+func TestDockerDiscovery(t *testing.T) {
+	suite.Run(t, &DockerDiscoveryTests{})
+}
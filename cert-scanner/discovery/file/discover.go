@@ -2,101 +2,452 @@ package file
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/netip"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/sgargan/cert-scanner-darkly/config"
 	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/sgargan/cert-scanner-darkly/utils"
 	"github.com/spf13/viper"
 	"golang.org/x/exp/slog"
-	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
 type HostsFile struct {
 	Groups []HostsGroup `yaml:"groups"`
 }
 
+// sources returns the source of each group in the file
+func (f *HostsFile) sources() []string {
+	sources := make([]string, 0, len(f.Groups))
+	for _, group := range f.Groups {
+		sources = append(sources, group.Source)
+	}
+	return sources
+}
+
+// HostsGroup is a group of hosts from the same source. Its labels are added to each of its hosts, with
+// additional labels given as key=value pairs.
 type HostsGroup struct {
 	Source           string            `yaml:"source"`
 	AdditionalLabels []string          `yaml:"additional_labels"`
+	Labels           map[string]string `yaml:"labels"`
 	Hosts            []TargetHostEntry `yaml:"hosts"`
 }
 
-// TargetHostEntry contains the details of a target host
+// TargetHostEntry contains the details of a target host. A host with a list of ports creates a target for
+// each port, and a host with a server name presents it in the tls handshake and validates it. An ip host
+// with sni names is probed without SNI and with each of the names.
 type TargetHostEntry struct {
-	Host string `json,yaml:"host"`
+	Host       string            `yaml:"host"`
+	ServerName string            `yaml:"server_name"`
+	SNI        []string          `yaml:"sni"`
+	Ports      []int             `yaml:"ports"`
+	Labels     map[string]string `yaml:"labels"`
+	Line       int               `yaml:"-"`
 }
 
+const DefaultReloadDelay = time.Second
+
 type FileDiscovery struct {
+	filter      *utils.Filter
+	reloadDelay time.Duration
+	lock        sync.Mutex
+	known       map[string]*Target
+}
+
+// fileTarget is a target loaded from a host file, keyed by the host entry, port and labels it was created from, so
+// relabelled hosts are removed and added again
+type fileTarget struct {
+	key    string
+	target *Target
 }
 
 // CreateDiscovery creates Discovery instance that loads target hosts from a file
 // on the filesystem.
 func CreateDiscovery() (Discovery, error) {
 	slog.Info("creating file discovery")
-	if len(viper.GetStringSlice(config.DiscoveryFilePaths)) == 0 {
+	paths := viper.GetStringSlice(config.DiscoveryFilePaths)
+	if len(paths) == 0 {
 		return nil, fmt.Errorf("no host file paths configured in %s", config.DiscoveryFilePaths)
 	}
-	return &FileDiscovery{}, nil
+	for _, path := range paths {
+		if _, err := filepath.Match(path, ""); err != nil {
+			return nil, fmt.Errorf("error parsing host file path %s: %v", path, err)
+		}
+	}
+
+	filter, err := utils.CreateFilter(viper.GetStringSlice(config.DiscoveryFileInclude), viper.GetStringSlice(config.DiscoveryFileExclude))
+	if err != nil {
+		return nil, err
+	}
+	return &FileDiscovery{
+		filter:      filter,
+		reloadDelay: DefaultReloadDelay,
+		known:       make(map[string]*Target),
+	}, nil
 }
 
 // Discover iterates through each configured file and loads the targets they contain and
-// emits them to the supplied targest channel.
+// emits them to the supplied targest channel. Paths can be files, directories of files or
+// glob patterns. Hosts are filtered by the include and exclude patterns. Every valid host
+// is discovered, then a [SourceError] naming the file and line of each invalid host is
+// returned.
 func (d *FileDiscovery) Discover(ctx context.Context, targets chan *Target) error {
-	files := viper.GetStringSlice(config.DiscoveryFilePaths)
+	loaded, _, err := d.load()
+	d.lock.Lock()
+	d.known = make(map[string]*Target, len(loaded))
+	for _, t := range loaded {
+		d.known[t.key] = t.target
+	}
+	d.lock.Unlock()
+
+	for _, t := range loaded {
+		targets <- t.target
+	}
+	return err
+}
+
+// Watch watches the configured paths, reloading the host files when they change and emitting the targets
+// that were added or removed since they were last loaded. Changes are batched until the files have been
+// quiet for the reload delay, so a file is not read while it is being written.
+func (d *FileDiscovery) Watch(ctx context.Context, changes chan *TargetChanges) error {
+	paths := viper.GetStringSlice(config.DiscoveryFilePaths)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating host file watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	dirs := watchedDirs(paths)
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("error watching host file directory %s: %v", dir, err)
+		}
+	}
+	slog.Info("watching host files for changes", "dirs", dirs)
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod || !isWatched(paths, event.Name) {
+				continue
+			}
+			slog.Debug("host file changed", "file", event.Name, "op", event.Op.String())
+			reload = time.After(d.reloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("error watching host files", "err", err)
+		case <-reload:
+			reload = nil
+			change := d.reload()
+			if change == nil {
+				continue
+			}
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// reload loads the host files, returning the targets that were added or removed since they were last loaded
+// or nil if none were. Files that cannot be read or parsed are likely mid update, so the reload is skipped
+// until they next change.
+func (d *FileDiscovery) reload() *TargetChanges {
+	loaded, complete, err := d.load()
+	if err != nil {
+		slog.Warn("error reloading host files", "err", err)
+	}
+	if !complete {
+		return nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	changes := &TargetChanges{}
+	current := make(map[string]*Target, len(loaded))
+	for _, t := range loaded {
+		current[t.key] = t.target
+		if _, present := d.known[t.key]; !present {
+			changes.Added = append(changes.Added, t.target)
+		}
+	}
+	for key, target := range d.known {
+		if _, present := current[key]; !present {
+			changes.Removed = append(changes.Removed, target)
+		}
+	}
+	d.known = current
+
+	if len(changes.Added) == 0 && len(changes.Removed) == 0 {
+		return nil
+	}
+	slog.Info("host files changed", "added", len(changes.Added), "removed", len(changes.Removed))
+	return changes
+}
+
+// load loads the targets from each of the configured files. Returns whether every file could be read and
+// parsed, and a [SourceError] for every file and host that could not be.
+func (d *FileDiscovery) load() ([]fileTarget, bool, error) {
+	files, errs := utils.ResolveFiles(viper.GetStringSlice(config.DiscoveryFilePaths))
 	slog.Debug("Host file entries", "count", len(files))
-	numTargets := 0
+	complete := errs == nil
+	loaded := make([]fileTarget, 0)
 	for _, file := range files {
 		data, err := os.ReadFile(file)
 		if err != nil {
-			slog.Error("error reading host file contents", "file", file, "error", err)
+			errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error reading host file %s: %v", file, err), d.knownSources(file)...))
+			complete = false
 			continue
 		}
 
-		details := &HostsFile{}
-		err = yaml.Unmarshal(data, details)
+		details, err := parseHostsFile(file, data)
 		if err != nil {
-			slog.Error("error unmarshalling host file contents", "file", file, "error", err)
-			continue
+			if details == nil {
+				errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error parsing host file %s: %v", file, err), d.knownSources(file)...))
+				complete = false
+				continue
+			}
+			errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error parsing host file %s: %v", file, err), details.sources()...))
 		}
-		slog.Debug("loaded host entries from file", "source", file, "additionalLabels", "groups", len(details.Groups))
+		slog.Debug("loaded host entries from file", "source", file, "groups", len(details.Groups))
 
 		for _, group := range details.Groups {
+			groupLabels, err := parseLabels(group.AdditionalLabels)
+			if err != nil {
+				errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error parsing additional labels of group %s in %s: %v", group.Source, file, err), group.Source))
+				continue
+			}
+			for k, v := range group.Labels {
+				groupLabels[k] = v
+			}
+
 			for _, host := range group.Hosts {
-				address, err := getTargetAddress(host)
+				if !d.filter.Matches(host.Host) {
+					slog.Debug("host filtered by patterns", "source", file, "host", host.Host)
+					continue
+				}
+				addresses, err := getTargetAddresses(host)
 				if err != nil {
-					slog.Error("error parsing host from file source", "source", file, "error", err, "host", host.Host)
+					errs = errors.Join(errs, CreateSourceError(fmt.Errorf("%s:%d: %v", file, host.Line, err), group.Source))
 					continue
 				}
-				numTargets++
-				targets <- &Target{
-					Address: address,
-					Metadata: Metadata{
-						Name:       host.Host,
-						Source:     group.Source,
-						SourceType: "file",
-						Labels: Labels{
-							"file": file,
+				labels := hostLabels(file, groupLabels, host)
+				for x, address := range addresses {
+					loaded = append(loaded, fileTarget{
+						key: fmt.Sprintf("%s|%s|%s|%v|%v|%d|%v", group.Source, host.Host, host.ServerName, host.SNI, host.Ports, x, labels),
+						target: &Target{
+							Address: address,
+							Metadata: Metadata{
+								Name:        host.Host,
+								Source:      group.Source,
+								SourceType:  "file",
+								Labels:      hostLabels(file, groupLabels, host),
+								ServerNames: host.SNI,
+							},
 						},
-					},
+					})
 				}
 			}
 		}
 	}
-	slog.Info("finished file discovery", "files", len(files), "targets", numTargets)
-	return nil
+	slog.Info("finished file discovery", "files", len(files), "targets", len(loaded))
+	return loaded, complete, errs
+}
+
+// knownSources returns the sources of the targets last loaded from the file, as those are the sources left
+// partly discovered when it cannot be loaded. A file that has not been loaded has no targets to keep, so it is
+// given the source of a csv or list file of the same name.
+func (d *FileDiscovery) knownSources(file string) []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	sources := make([]string, 0)
+	for _, target := range d.known {
+		if target.Metadata.Labels["file"] == file && !slices.Contains(sources, target.Source) {
+			sources = append(sources, target.Source)
+		}
+	}
+	if len(sources) == 0 {
+		sources = append(sources, fileSource(file))
+	}
+	return sources
+}
+
+// watchedDirs returns the directories to watch for changes to the configured paths. Files are watched via
+// their directory so that files replaced by editors and config management are still watched.
+func watchedDirs(paths []string) []string {
+	dirs := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			add(path)
+			continue
+		}
+		dir := filepath.Dir(path)
+		if !utils.IsGlob(dir) {
+			add(dir)
+			continue
+		}
+		matches, _ := filepath.Glob(dir)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				add(match)
+			}
+		}
+	}
+	return dirs
+}
+
+// isWatched returns true if the file is one of the configured paths, matches one of its patterns or is
+// directly within one of its directories
+func isWatched(paths []string, file string) bool {
+	file = filepath.Clean(file)
+	if strings.HasPrefix(filepath.Base(file), ".") {
+		return false
+	}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if utils.IsGlob(path) {
+			if matched, _ := filepath.Match(path, file); matched {
+				return true
+			}
+		} else if path == file || path == filepath.Dir(file) {
+			return true
+		}
+	}
+	return false
 }
 
-func getTargetAddress(host TargetHostEntry) (Address, error) {
-	url, err := url.Parse(host.Host)
-	if err != nil || url.Host == "" {
-		ipAddr, err := netip.ParseAddrPort(host.Host)
+func hostLabels(file string, groupLabels Labels, host TargetHostEntry) Labels {
+	labels := Labels{}
+	for k, v := range groupLabels {
+		labels[k] = v
+	}
+	for k, v := range host.Labels {
+		labels[k] = v
+	}
+	labels["file"] = file
+	return labels
+}
+
+// getTargetAddresses creates an address for each port of the host, or a single address if no ports are
+// listed. Hosts can be urls, ip:port pairs or hostname:port pairs, the latter being scanned as tls urls.
+// Bare ips and hostnames require a list of ports.
+func getTargetAddresses(host TargetHostEntry) ([]Address, error) {
+	u, err := url.Parse(host.Host)
+	if err != nil || u.Host == "" {
+		u, err = parseHostPort(host)
 		if err != nil {
-			return nil, fmt.Errorf("could not parse address:port or url from %s", host.Host)
+			return nil, err
 		}
-		return CreateNetIPAddress(ipAddr), nil
 	}
-	return CreateUrlAddress(url), nil
+
+	if len(host.Ports) == 0 {
+		if u.Port() == "" && u.Scheme == "" {
+			return nil, fmt.Errorf("no port for %s, either include one or configure a list of ports", host.Host)
+		}
+		address, err := createAddress(u, host.ServerName)
+		if err != nil {
+			return nil, err
+		}
+		return []Address{address}, nil
+	}
+
+	addresses := make([]Address, 0, len(host.Ports))
+	for _, port := range host.Ports {
+		if port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d for %s", port, host.Host)
+		}
+		withPort := *u
+		withPort.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+		address, err := createAddress(&withPort, host.ServerName)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
 }
+
+// parseHostPort parses hosts that are not urls into a url without a scheme for ips, or a tls url for hostnames
+func parseHostPort(host TargetHostEntry) (*url.URL, error) {
+	hostname, port, err := net.SplitHostPort(host.Host)
+	if err != nil {
+		// bare ips and hostnames have no port
+		hostname, port = host.Host, ""
+	}
+	if _, err := netip.ParseAddr(hostname); err == nil {
+		return &url.URL{Host: joinHostPort(hostname, port)}, nil
+	}
+	if !hostnamePattern.MatchString(hostname) {
+		return nil, fmt.Errorf("could not parse address:port or url from %s", host.Host)
+	}
+	return &url.URL{Scheme: "tls", Host: joinHostPort(hostname, port)}, nil
+}
+
+// createAddress creates an ip address for urls without a scheme, or a url address otherwise. A server name
+// is presented in place of the hostname of a url, which is then only dialled.
+func createAddress(u *url.URL, serverName string) (Address, error) {
+	if u.Scheme == "" {
+		ip, err := netip.ParseAddrPort(u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse address:port from %s", u.Host)
+		}
+		if serverName != "" {
+			return CreateNetIPAddressWithServerName(ip, serverName), nil
+		}
+		return CreateNetIPAddress(ip), nil
+	}
+
+	if serverName == "" || serverName == u.Hostname() {
+		return CreateUrlAddress(u), nil
+	}
+	presented := *u
+	presented.Host = serverName
+	if u.Port() != "" {
+		presented.Host = net.JoinHostPort(serverName, u.Port())
+	}
+	return CreateUrlAddressWithDialAddress(&presented, u.Hostname()), nil
+}
+
+func joinHostPort(host, port string) string {
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// hostnames need a letter in their last label, so partial ips such as 10.3.23 are not mistaken for one
+var hostnamePattern = regexp.MustCompile(`^([a-zA-Z0-9-]+\.)*[a-zA-Z0-9-]*[a-zA-Z][a-zA-Z0-9-]*$`)
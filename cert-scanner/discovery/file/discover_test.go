@@ -2,8 +2,11 @@ package file
 
 import (
 	"context"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/sgargan/cert-scanner-darkly/config"
 	"github.com/spf13/viper"
@@ -17,6 +20,10 @@ type DiscoveryTests struct {
 	files []*os.File
 }
 
+func (t *DiscoveryTests) TearDownTest() {
+	viper.Reset()
+}
+
 func (t *DiscoveryTests) TearDownSuite() {
 	for _, file := range t.files {
 		file.Close()
@@ -43,16 +50,183 @@ groups:
 
 func (t *DiscoveryTests) TestSkipsInvalidEntriesConfig() {
 
-	filename, targets := t.configureTestFileDiscovery("someFile", `---
+	filename, targets, err := t.discover("someFile", `---
 groups:
 - source: some_source
   hosts:
    - host: 10.3.23
    - host: https://golang.org
+   - host: 10.2.3.4
 `)
 
 	t.Equal(1, len(targets))
 	t.validateFileTarget("https://golang.org", "some_source", filename, <-targets)
+	t.ErrorContains(err, filename+":5: could not parse address:port or url from 10.3.23")
+	t.ErrorContains(err, filename+":7: no port for 10.2.3.4")
+	t.Equal([]string{"some_source"}, FailedSources(err))
+}
+
+func (t *DiscoveryTests) TestLabelsHostsFromGroupsAndHosts() {
+	filename, targets, err := t.discover("someFile", `---
+groups:
+- source: some_source
+  additional_labels:
+   - env=prod
+  labels:
+    team: payments
+  hosts:
+   - host: https://golang.org
+     labels:
+       team: platform
+       tier: web
+`)
+	t.NoError(err)
+	t.Equal(Labels{"env": "prod", "team": "platform", "tier": "web", "file": filename}, (<-targets).Metadata.Labels)
+}
+
+func (t *DiscoveryTests) TestInvalidAdditionalLabelsRaiseError() {
+	_, targets, err := t.discover("someFile", `---
+groups:
+- source: some_source
+  additional_labels:
+   - prod
+  hosts:
+   - host: https://golang.org
+`)
+	t.Equal(0, len(targets))
+	t.ErrorContains(err, "invalid label prod, expected key=value")
+}
+
+func (t *DiscoveryTests) TestCreatesTargetForEachPort() {
+	_, targets, err := t.discover("someFile", `---
+groups:
+- source: some_source
+  hosts:
+   - host: 10.2.3.4
+     ports: [443, 8443]
+   - host: some.host.internal
+     ports: [9443]
+   - host: fd00::1
+     ports: [443]
+`)
+	t.NoError(err)
+	t.Equal(4, len(targets))
+	t.Equal("10.2.3.4:443", (<-targets).Address.String())
+	t.Equal("10.2.3.4:8443", (<-targets).Address.String())
+	address, _ := ParseUrlAddress("tls://some.host.internal:9443")
+	t.Equal(address, (<-targets).Address)
+	t.Equal("[fd00::1]:443", (<-targets).Address.String())
+}
+
+func (t *DiscoveryTests) TestPresentsServerNames() {
+	_, targets, err := t.discover("someFile", `---
+groups:
+- source: some_source
+  hosts:
+   - host: 10.2.3.4:8443
+     server_name: some.host.internal
+   - host: https://some.lb.internal:8443
+     server_name: some.host.internal
+`)
+	t.NoError(err)
+
+	ip := (<-targets).Address
+	t.Equal(CreateNetIPAddressWithServerName(netip.MustParseAddrPort("10.2.3.4:8443"), "some.host.internal"), ip)
+	t.True(ip.ValidateHostname())
+
+	u := (<-targets).Address
+	t.Equal("some.host.internal", u.ServerName())
+	t.IsType(&UrlAddress{}, u)
+}
+
+func (t *DiscoveryTests) TestProbesSNINames() {
+	_, targets, err := t.discover("someFile", `---
+groups:
+- source: some_source
+  hosts:
+   - host: 10.2.3.4:8443
+     sni: [www.somecompany.com, api.somecompany.com]
+`)
+	t.NoError(err)
+
+	target := <-targets
+	t.Equal(CreateNetIPAddress(netip.MustParseAddrPort("10.2.3.4:8443")), target.Address)
+	t.Equal([]string{"www.somecompany.com", "api.somecompany.com"}, target.ServerNames)
+}
+
+func (t *DiscoveryTests) TestFiltersHostsByPattern() {
+	viper.Set(config.DiscoveryFileInclude, []string{`\.internal`})
+	viper.Set(config.DiscoveryFileExclude, []string{`^https://legacy`})
+	_, targets, err := t.discover("someFile", `---
+groups:
+- source: some_source
+  hosts:
+   - host: https://golang.org
+   - host: https://legacy.internal
+   - host: https://some.internal
+`)
+	t.NoError(err)
+	t.Equal(1, len(targets))
+	t.Equal("https://some.internal", (<-targets).Name)
+}
+
+func (t *DiscoveryTests) TestInvalidPatternsRaiseError() {
+	viper.Set(config.DiscoveryFilePaths, []string{"hosts.yaml"})
+	viper.Set(config.DiscoveryFileExclude, []string{"("})
+	_, err := CreateDiscovery()
+	t.ErrorContains(err, "error parsing exclude patterns")
+}
+
+func (t *DiscoveryTests) TestLoadsCSVFiles() {
+	filename, targets, err := t.discover("hosts*.csv", `host,server_name,ports,labels
+# comments are skipped
+10.2.3.4,some.host.internal,443;8443,team=payments;env=prod
+https://golang.org,,,
+10.2.3.5,,https,
+`)
+	t.Equal(3, len(targets))
+	target := <-targets
+	t.Equal("10.2.3.4:443", target.Address.String())
+	t.Equal("some.host.internal", target.Address.ServerName())
+	t.Equal(Labels{"team": "payments", "env": "prod", "file": filename}, target.Metadata.Labels)
+	t.Equal(fileSource(filename), target.Source)
+	t.Equal("10.2.3.4:8443", (<-targets).Address.String())
+	t.validateFileTarget("https://golang.org", fileSource(filename), filename, <-targets)
+	t.ErrorContains(err, "line 5: invalid port https")
+}
+
+func (t *DiscoveryTests) TestCSVFilesRequireHostColumn() {
+	_, targets, err := t.discover("hosts*.csv", "address,ports\n10.2.3.4,443\n")
+	t.Equal(0, len(targets))
+	t.ErrorContains(err, "csv header has no host column")
+}
+
+func (t *DiscoveryTests) TestLoadsListFiles() {
+	filename, targets, err := t.discover("hosts*.txt", `# some hosts
+https://golang.org
+
+https://github.com
+10.3.23
+`)
+	t.Equal(2, len(targets))
+	t.validateFileTarget("https://golang.org", fileSource(filename), filename, <-targets)
+	t.validateFileTarget("https://github.com", fileSource(filename), filename, <-targets)
+	t.ErrorContains(err, filename+":5:")
+}
+
+func (t *DiscoveryTests) TestLoadsJSONFiles() {
+	filename, targets, err := t.discover("hosts*.json", `{
+	"groups": [{
+		"source": "some_source",
+		"hosts": [
+			{"host": "https://golang.org"},
+			{"host": "10.3.23"}
+		]
+	}]
+}`)
+	t.Equal(1, len(targets))
+	t.validateFileTarget("https://golang.org", "some_source", filename, <-targets)
+	t.ErrorContains(err, filename+":6:")
 }
 
 func (t *DiscoveryTests) TestMultipleGroupsConfig() {
@@ -72,18 +246,156 @@ groups:
 	t.validateFileTarget("https://github.org", "group_two", filename, <-targets)
 }
 
+func (t *DiscoveryTests) TestDiscoversReadableFiles() {
+	dir := t.T().TempDir()
+	t.writeFile(filepath.Join(dir, "hosts.txt"), "https://golang.org\n")
+	viper.Set(config.DiscoveryFilePaths, []string{filepath.Join(dir, "missing.txt"), filepath.Join(dir, "hosts.txt")})
+
+	targets := make(chan *Target, 10)
+	err := t.createDiscovery().Discover(context.Background(), targets)
+	t.Equal(1, len(targets))
+	t.ErrorContains(err, "error reading host file "+filepath.Join(dir, "missing.txt"))
+	t.Equal([]string{"missing"}, FailedSources(err))
+}
+
+func (t *DiscoveryTests) TestAttributesUnreadableFilesToTheirSources() {
+	filename := filepath.Join(t.T().TempDir(), "hosts.yaml")
+	t.writeFile(filename, `---
+groups:
+- source: some_source
+  hosts:
+   - host: https://golang.org
+`)
+	viper.Set(config.DiscoveryFilePaths, []string{filename})
+	discovery := t.createDiscovery()
+	t.NoError(discovery.Discover(context.Background(), make(chan *Target, 10)))
+
+	t.NoError(os.Remove(filename))
+	err := discovery.Discover(context.Background(), make(chan *Target, 10))
+	t.ErrorContains(err, "error reading host file "+filename)
+	t.Equal([]string{"some_source"}, FailedSources(err))
+}
+
+func (t *DiscoveryTests) TestInvalidGlobsRaiseError() {
+	viper.Set(config.DiscoveryFilePaths, []string{"hosts/[.yaml"})
+	_, err := CreateDiscovery()
+	t.ErrorContains(err, "error parsing host file path hosts/[.yaml")
+}
+
+func (t *DiscoveryTests) TestWatchEmitsChangedHosts() {
+	dir := t.T().TempDir()
+	t.writeFile(filepath.Join(dir, "hosts.txt"), "https://golang.org\nhttps://github.com\n")
+	viper.Set(config.DiscoveryFilePaths, []string{filepath.Join(dir, "*.txt")})
+
+	discovery := t.createDiscovery()
+	targets := make(chan *Target, 10)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	t.Equal(2, len(targets))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *TargetChanges)
+	watching := make(chan error)
+	go func() {
+		watching <- discovery.Watch(ctx, changes)
+	}()
+	// give the watcher time to start
+	time.Sleep(100 * time.Millisecond)
+
+	t.writeFile(filepath.Join(dir, "hosts.txt"), "https://golang.org\nhttps://google.com\n")
+	t.writeFile(filepath.Join(dir, "more.txt"), "10.2.3.4:443\n")
+	t.writeFile(filepath.Join(dir, "ignored.yaml"), "")
+
+	change := t.waitForChange(changes)
+	t.ElementsMatch([]string{"https://google.com", "10.2.3.4:443"}, targetNames(change.Added))
+	t.Equal([]string{"https://github.com"}, targetNames(change.Removed))
+
+	t.NoError(os.Remove(filepath.Join(dir, "more.txt")))
+	change = t.waitForChange(changes)
+	t.Empty(change.Added)
+	t.Equal([]string{"10.2.3.4:443"}, targetNames(change.Removed))
+
+	cancel()
+	t.NoError(<-watching)
+}
+
+func (t *DiscoveryTests) TestReloadReplacesRelabelledHosts() {
+	dir := t.T().TempDir()
+	t.writeFile(filepath.Join(dir, "hosts.yaml"), "groups:\n- source: some_source\n  hosts:\n  - host: https://golang.org\n    labels:\n      team: payments\n")
+	viper.Set(config.DiscoveryFilePaths, []string{dir})
+
+	discovery := t.createDiscovery()
+	t.NoError(discovery.Discover(context.Background(), make(chan *Target, 10)))
+
+	t.writeFile(filepath.Join(dir, "hosts.yaml"), "groups:\n- source: some_source\n  hosts:\n  - host: https://golang.org\n    labels:\n      team: platform\n")
+	change := discovery.reload()
+	t.Equal("payments", change.Removed[0].Metadata.Labels["team"])
+	t.Equal("platform", change.Added[0].Metadata.Labels["team"])
+}
+
+func (t *DiscoveryTests) TestWatchSkipsUnparseableFiles() {
+	dir := t.T().TempDir()
+	t.writeFile(filepath.Join(dir, "hosts.yaml"), "groups:\n- source: some_source\n  hosts:\n  - host: https://golang.org\n")
+	viper.Set(config.DiscoveryFilePaths, []string{dir})
+
+	discovery := t.createDiscovery()
+	t.NoError(discovery.Discover(context.Background(), make(chan *Target, 10)))
+
+	t.writeFile(filepath.Join(dir, "hosts.yaml"), "groups:\n- source: [")
+	t.Nil(discovery.reload())
+
+	t.writeFile(filepath.Join(dir, "hosts.yaml"), "groups:\n- source: some_source\n  hosts:\n  - host: https://golang.org\n")
+	t.Nil(discovery.reload())
+}
+
+func (t *DiscoveryTests) createDiscovery() *FileDiscovery {
+	d, err := CreateDiscovery()
+	t.NoError(err)
+	discovery := d.(*FileDiscovery)
+	discovery.reloadDelay = 50 * time.Millisecond
+	return discovery
+}
+
+func (t *DiscoveryTests) waitForChange(changes chan *TargetChanges) *TargetChanges {
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(5 * time.Second):
+		t.FailNow("timed out waiting for host file changes")
+		return nil
+	}
+}
+
+func (t *DiscoveryTests) writeFile(name, content string) {
+	t.NoError(os.WriteFile(name, []byte(content), os.ModePerm))
+}
+
+func targetNames(targets []*Target) []string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	return names
+}
+
 func (t *DiscoveryTests) configureTestFileDiscovery(file, content string) (string, chan *Target) {
+	filename, targets, err := t.discover(file, content)
+	t.NoError(err)
+	return filename, targets
+}
+
+func (t *DiscoveryTests) discover(file, content string) (string, chan *Target, error) {
 	filename := t.createTestFile(file, content)
 	viper.Set(config.DiscoveryFilePaths, []string{filename})
 
 	d, err := CreateDiscovery()
 	t.NoError(err)
 
-	targets := make(chan *Target, 3)
+	targets := make(chan *Target, 10)
 	discovery := d.(*FileDiscovery)
-	discovery.Discover(context.Background(), targets)
+	err = discovery.Discover(context.Background(), targets)
 
-	return filename, targets
+	return filename, targets, err
 }
 
 func (t *DiscoveryTests) validateFileTarget(url, source, filename string, target *Target) {
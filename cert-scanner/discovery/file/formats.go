@@ -0,0 +1,166 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseHostsFile parses the groups of hosts in a file, detecting the format from its extension. YAML and JSON
+// files hold groups of hosts, while CSV files and plain .txt or .list files of hosts form a single group
+// sourced from the name of the file. Files with any other extension are parsed as YAML.
+func parseHostsFile(file string, data []byte) (*HostsFile, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return parseCSV(fileSource(file), data)
+	case ".txt", ".list":
+		return parseList(fileSource(file), data)
+	case ".json":
+		// json is parsed as yaml to find the line of each host, raw tabs cannot appear in json strings so
+		// any are whitespace that yaml would reject
+		return parseGroups(bytes.ReplaceAll(data, []byte("\t"), []byte(" ")))
+	default:
+		return parseGroups(data)
+	}
+}
+
+// UnmarshalYAML decodes a host entry, recording the line it was found on
+func (e *TargetHostEntry) UnmarshalYAML(node *yaml.Node) error {
+	type entry TargetHostEntry
+	if err := node.Decode((*entry)(e)); err != nil {
+		return err
+	}
+	e.Line = node.Line
+	return nil
+}
+
+// parseGroups parses groups of hosts from YAML, or from JSON which is also valid YAML
+func parseGroups(data []byte) (*HostsFile, error) {
+	details := &HostsFile{}
+	if err := yaml.Unmarshal(data, details); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// parseCSV parses hosts from a CSV file with a header row naming its columns. The host column is required
+// and the optional server_name, ports and labels columns configure each host. Multiple ports and labels
+// are separated by semicolons e.g. 443;8443 and team=payments;env=prod
+func parseCSV(source string, data []byte) (*HostsFile, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for x, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = x
+	}
+	if _, ok := columns["host"]; !ok {
+		return nil, fmt.Errorf("csv header has no host column")
+	}
+
+	group := HostsGroup{Source: source}
+	var errs error
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// parse errors name the line they occur on
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		column := func(name string) string {
+			if x, ok := columns[name]; ok && x < len(record) {
+				return strings.TrimSpace(record[x])
+			}
+			return ""
+		}
+
+		entry := TargetHostEntry{Host: column("host"), ServerName: column("server_name"), Line: line}
+		if sni := column("sni"); sni != "" {
+			entry.SNI = splitList(sni, ";")
+		}
+		if entry.Ports, err = parsePorts(column("ports")); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+		if entry.Labels, err = parseLabels(splitList(column("labels"), ";")); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+		group.Hosts = append(group.Hosts, entry)
+	}
+	return &HostsFile{Groups: []HostsGroup{group}}, errs
+}
+
+// parseList parses a plain list of hosts, one per line. Blank lines and lines starting with # are skipped.
+func parseList(source string, data []byte) (*HostsFile, error) {
+	group := HostsGroup{Source: source}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		host := strings.TrimSpace(scanner.Text())
+		if host == "" || strings.HasPrefix(host, "#") {
+			continue
+		}
+		group.Hosts = append(group.Hosts, TargetHostEntry{Host: host, Line: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &HostsFile{Groups: []HostsGroup{group}}, nil
+}
+
+func parsePorts(value string) ([]int, error) {
+	ports := make([]int, 0)
+	for _, p := range splitList(value, ";") {
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid port %s", p)
+		}
+		ports = append(ports, int(port))
+	}
+	return ports, nil
+}
+
+// parseLabels parses a list of key=value labels
+func parseLabels(values []string) (map[string]string, error) {
+	labels := make(map[string]string, len(values))
+	for _, value := range values {
+		key, label, found := strings.Cut(value, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid label %s, expected key=value", value)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(label)
+	}
+	return labels, nil
+}
+
+func splitList(value, separator string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, separator) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// fileSource names the source of hosts read from formats without groups after the file they came from
+func fileSource(file string) string {
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
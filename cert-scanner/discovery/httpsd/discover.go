@@ -0,0 +1,248 @@
+package httpsd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/sgargan/cert-scanner-darkly/utils"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	HTTPSD   = "http_sd"
+	Endpoint = "endpoint"
+
+	// limits the size of a response so a misbehaving endpoint cannot exhaust memory
+	maxResponseBytes = 32 << 20
+)
+
+// EndpointConfig is an http(s) url serving targets in the prometheus http_sd format, with the credentials and
+// ca used to retrieve them
+type EndpointConfig struct {
+	URL                string     `mapstructure:"url"`
+	Source             string     `mapstructure:"source"`
+	BearerToken        string     `mapstructure:"bearer_token"`
+	BearerTokenFile    string     `mapstructure:"bearer_token_file"`
+	BasicAuth          *BasicAuth `mapstructure:"basic_auth"`
+	CAFile             string     `mapstructure:"ca_file"`
+	InsecureSkipVerify bool       `mapstructure:"insecure_skip_verify"`
+}
+
+type BasicAuth struct {
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"`
+}
+
+// TargetGroup is a group of targets sharing a set of labels in the http_sd format
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+type HTTPSDDiscoveryConfig struct {
+	endpoints []EndpointConfig
+	timeout   time.Duration
+}
+
+type endpoint struct {
+	EndpointConfig
+	url    *url.URL
+	client *http.Client
+}
+
+type HTTPSDDiscovery struct {
+	endpoints []endpoint
+}
+
+// CreateDiscovery creates a Discovery instance that polls the configured http_sd endpoints for targets
+func CreateDiscovery() (Discovery, error) {
+	var endpoints []EndpointConfig
+	if err := viper.UnmarshalKey(config.DiscoveryHTTPSDEndpoints, &endpoints); err != nil {
+		return nil, fmt.Errorf("error parsing http_sd endpoints: %v", err)
+	}
+
+	return CreateHTTPSDDiscovery(HTTPSDDiscoveryConfig{endpoints: endpoints, timeout: viper.GetDuration(config.DiscoveryHTTPSDTimeout)})
+}
+
+// CreateHTTPSDDiscovery creates a discovery that polls each of the given endpoints. Returns an error if an
+// endpoint has no valid url or source, or its credentials or ca are misconfigured.
+func CreateHTTPSDDiscovery(config HTTPSDDiscoveryConfig) (*HTTPSDDiscovery, error) {
+	slog.Info("creating http_sd discovery", "endpoints", len(config.endpoints))
+	if len(config.endpoints) == 0 {
+		return nil, fmt.Errorf("at least one http_sd endpoint is required")
+	}
+
+	endpoints := make([]endpoint, 0, len(config.endpoints))
+	for _, e := range config.endpoints {
+		created, err := createEndpoint(e, config.timeout)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, created)
+	}
+	return &HTTPSDDiscovery{endpoints: endpoints}, nil
+}
+
+func createEndpoint(e EndpointConfig, timeout time.Duration) (endpoint, error) {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return endpoint{}, fmt.Errorf("http_sd endpoint %s is not a valid http(s) url", e.URL)
+	}
+	if e.Source == "" {
+		return endpoint{}, fmt.Errorf("a valid source label for http_sd endpoint %s is required", u.Redacted())
+	}
+	if (e.BearerToken != "" || e.BearerTokenFile != "") && e.BasicAuth != nil {
+		return endpoint{}, fmt.Errorf("http_sd endpoint %s can use either bearer token or basic auth, not both", u.Redacted())
+	}
+
+	transport, err := utils.CreateTransport(e.CAFile, e.InsecureSkipVerify)
+	if err != nil {
+		return endpoint{}, fmt.Errorf("error reading ca for http_sd endpoint %s: %v", u.Redacted(), err)
+	}
+	return endpoint{
+		EndpointConfig: e,
+		url:            u,
+		client:         &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// Discover polls each endpoint, emitting a [Target] for each of the targets it serves labelled with the
+// labels of its group. Targets from every endpoint that could be polled are discovered, then a [SourceError]
+// for each endpoint and target that could not be is returned.
+func (d *HTTPSDDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	var errs error
+	for _, e := range d.endpoints {
+		groups, err := e.poll(ctx)
+		if err != nil {
+			errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error polling http_sd endpoint %s: %v", e.url.Redacted(), err), e.Source))
+			continue
+		}
+
+		numTargets := 0
+		for _, group := range groups {
+			for _, t := range group.Targets {
+				address, err := createAddress(t)
+				if err != nil {
+					errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error parsing target from http_sd endpoint %s: %v", e.url.Redacted(), err), e.Source))
+					continue
+				}
+				numTargets++
+				targets <- &Target{
+					Address: address,
+					Metadata: Metadata{
+						Name:       t,
+						Source:     e.Source,
+						SourceType: HTTPSD,
+						Labels:     e.labels(group),
+					},
+				}
+			}
+		}
+		slog.Debug("polled http_sd endpoint", "endpoint", e.url.Redacted(), "groups", len(groups), "targets", numTargets)
+	}
+	return errs
+}
+
+// poll retrieves the target groups served by the endpoint
+func (e *endpoint) poll(ctx context.Context) ([]TargetGroup, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, e.url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if err := e.authorize(request); err != nil {
+		return nil, err
+	}
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	groups := make([]TargetGroup, 0)
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseBytes)).Decode(&groups); err != nil {
+		return nil, fmt.Errorf("error decoding target groups: %v", err)
+	}
+	return groups, nil
+}
+
+// authorize adds the credentials of the endpoint to the request. Credentials in files are read for each
+// request so they can be rotated.
+func (e *endpoint) authorize(request *http.Request) error {
+	if e.BasicAuth != nil {
+		password := e.BasicAuth.Password
+		if e.BasicAuth.PasswordFile != "" {
+			read, err := readSecret(e.BasicAuth.PasswordFile)
+			if err != nil {
+				return err
+			}
+			password = read
+		}
+		request.SetBasicAuth(e.BasicAuth.Username, password)
+		return nil
+	}
+
+	token := e.BearerToken
+	if e.BearerTokenFile != "" {
+		read, err := readSecret(e.BearerTokenFile)
+		if err != nil {
+			return err
+		}
+		token = read
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// labels returns the labels of the group, without the internal labels prefixed with __, and the endpoint
+// the group was served by
+func (e *endpoint) labels(group TargetGroup) Labels {
+	labels := Labels{}
+	for k, v := range group.Labels {
+		if !strings.HasPrefix(k, "__") {
+			labels[k] = v
+		}
+	}
+	labels[Endpoint] = e.url.Redacted()
+	return labels
+}
+
+func readSecret(file string) (string, error) {
+	secret, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading credentials: %v", err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// createAddress creates an ip address for ip:port targets, or a tls url for hostname:port targets
+func createAddress(target string) (Address, error) {
+	if ip, err := netip.ParseAddrPort(target); err == nil {
+		return CreateNetIPAddress(ip), nil
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || host == "" || port == "" {
+		return nil, fmt.Errorf("invalid target %s, expected host:port", target)
+	}
+	return CreateUrlAddress(&url.URL{Scheme: "tls", Host: net.JoinHostPort(host, port)}), nil
+}
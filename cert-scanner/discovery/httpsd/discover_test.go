@@ -0,0 +1,180 @@
+package httpsd
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+const testGroups = `[
+	{"targets": ["10.0.0.1:443", "some.host.internal:8443"], "labels": {"team": "payments", "__meta_internal": "dropped"}},
+	{"targets": ["10.0.0.2:443"], "labels": {"team": "platform"}}
+]`
+
+type HTTPSDDiscoveryTests struct {
+	server  *httptest.Server
+	handler http.HandlerFunc
+	suite.Suite
+}
+
+func (t *HTTPSDDiscoveryTests) SetupTest() {
+	t.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testGroups))
+	}
+	t.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.handler(w, r)
+	}))
+}
+
+func (t *HTTPSDDiscoveryTests) TearDownTest() {
+	t.server.Close()
+	viper.Reset()
+}
+
+func (t *HTTPSDDiscoveryTests) TestDiscoveryCreationErrors() {
+	_, err := CreateHTTPSDDiscovery(HTTPSDDiscoveryConfig{})
+	t.ErrorContains(err, "at least one http_sd endpoint is required")
+
+	t.assertEndpointError(EndpointConfig{URL: "ftp://some.host", Source: "cmdb"}, "http_sd endpoint ftp://some.host is not a valid http(s) url")
+	t.assertEndpointError(EndpointConfig{URL: "https://some.host"}, "a valid source label for http_sd endpoint https://some.host is required")
+	t.assertEndpointError(EndpointConfig{URL: "https://some.host", Source: "cmdb", BearerToken: "token", BasicAuth: &BasicAuth{}}, "either bearer token or basic auth")
+	t.assertEndpointError(EndpointConfig{URL: "https://some.host", Source: "cmdb", CAFile: "missing.pem"}, "error reading ca for http_sd endpoint")
+}
+
+func (t *HTTPSDDiscoveryTests) TestDiscoversTargetGroups() {
+	targets, err := t.discover(EndpointConfig{URL: t.server.URL, Source: "cmdb"})
+	t.NoError(err)
+	t.Len(targets, 3)
+
+	t.Equal(&Target{
+		Address: CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.1:443")),
+		Metadata: Metadata{
+			Name:       "10.0.0.1:443",
+			Source:     "cmdb",
+			SourceType: HTTPSD,
+			Labels:     Labels{"team": "payments", Endpoint: t.server.URL},
+		},
+	}, targets[0])
+
+	address, _ := ParseUrlAddress("tls://some.host.internal:8443")
+	t.Equal(address, targets[1].Address)
+	t.Equal("platform", targets[2].Metadata.Labels["team"])
+}
+
+func (t *HTTPSDDiscoveryTests) TestAuthenticatesWithBearerToken() {
+	tokenFile := filepath.Join(t.T().TempDir(), "token")
+	t.NoError(os.WriteFile(tokenFile, []byte("some-token\n"), os.ModePerm))
+	t.requireHeader("Authorization", "Bearer some-token")
+
+	targets, err := t.discover(EndpointConfig{URL: t.server.URL, Source: "cmdb", BearerTokenFile: tokenFile})
+	t.NoError(err)
+	t.Len(targets, 3)
+
+	_, err = t.discover(EndpointConfig{URL: t.server.URL, Source: "cmdb", BearerToken: "wrong-token"})
+	t.ErrorContains(err, "unexpected status 401 Unauthorized")
+}
+
+func (t *HTTPSDDiscoveryTests) TestAuthenticatesWithBasicAuth() {
+	t.handler = func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "someone" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(testGroups))
+	}
+
+	targets, err := t.discover(EndpointConfig{URL: t.server.URL, Source: "cmdb", BasicAuth: &BasicAuth{Username: "someone", Password: "secret"}})
+	t.NoError(err)
+	t.Len(targets, 3)
+}
+
+func (t *HTTPSDDiscoveryTests) TestVerifiesServerWithCustomCA() {
+	server := httptest.NewTLSServer(t.server.Config.Handler)
+	defer server.Close()
+
+	_, err := t.discover(EndpointConfig{URL: server.URL, Source: "cmdb"})
+	t.ErrorContains(err, "certificate signed by unknown authority")
+
+	caFile := filepath.Join(t.T().TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	t.NoError(os.WriteFile(caFile, ca, os.ModePerm))
+
+	targets, err := t.discover(EndpointConfig{URL: server.URL, Source: "cmdb", CAFile: caFile})
+	t.NoError(err)
+	t.Len(targets, 3)
+}
+
+func (t *HTTPSDDiscoveryTests) TestDiscoversValidTargetsAndEndpoints() {
+	t.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"targets": ["10.0.0.1:443", "10.0.0.3"]}]`))
+	}
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer broken.Close()
+
+	targets, err := t.discover(EndpointConfig{URL: broken.URL, Source: "broken"}, EndpointConfig{URL: t.server.URL, Source: "cmdb"})
+	t.Len(targets, 1)
+	t.ErrorContains(err, "error polling http_sd endpoint "+broken.URL+": error decoding target groups")
+	t.ErrorContains(err, "invalid target 10.0.0.3, expected host:port")
+	t.Equal([]string{"broken", "cmdb"}, FailedSources(err))
+}
+
+func (t *HTTPSDDiscoveryTests) TestCreatesDiscoveryFromConfig() {
+	config.SetDefaults()
+	viper.Set(config.DiscoveryHTTPSDEndpoints, []map[string]interface{}{
+		{"url": t.server.URL, "source": "cmdb", "basic_auth": map[string]interface{}{"username": "someone", "password_file": "/some/file"}},
+	})
+
+	discovery, err := CreateDiscovery()
+	t.NoError(err)
+	d := discovery.(*HTTPSDDiscovery)
+	t.Equal("cmdb", d.endpoints[0].Source)
+	t.Equal("/some/file", d.endpoints[0].BasicAuth.PasswordFile)
+	t.Equal(10*time.Second, d.endpoints[0].client.Timeout)
+}
+
+func (t *HTTPSDDiscoveryTests) requireHeader(header, value string) {
+	t.handler = func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(header) != value {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(testGroups))
+	}
+}
+
+func (t *HTTPSDDiscoveryTests) assertEndpointError(e EndpointConfig, expected string) {
+	_, err := CreateHTTPSDDiscovery(HTTPSDDiscoveryConfig{endpoints: []EndpointConfig{e}})
+	t.ErrorContains(err, expected)
+}
+
+func (t *HTTPSDDiscoveryTests) discover(endpoints ...EndpointConfig) ([]*Target, error) {
+	discovery, err := CreateHTTPSDDiscovery(HTTPSDDiscoveryConfig{endpoints: endpoints, timeout: time.Second})
+	t.NoError(err)
+
+	targets := make(chan *Target, 10)
+	err = discovery.Discover(context.Background(), targets)
+	close(targets)
+
+	discovered := make([]*Target, 0)
+	for target := range targets {
+		discovered = append(discovered, target)
+	}
+	return discovered, err
+}
+
+func TestHTTPSDDiscovery(t *testing.T) {
+	suite.Run(t, &HTTPSDDiscoveryTests{})
+}
@@ -0,0 +1,99 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Annotations that allow service owners to control how their pods are scanned
+const (
+	IgnoreAnnotation     = "cert-scanner.io/ignore"
+	PortsAnnotation      = "cert-scanner.io/ports"
+	ServerNameAnnotation = "cert-scanner.io/server-name"
+	SNIAnnotation        = "cert-scanner.io/sni"
+	LabelsAnnotation     = "cert-scanner.io/labels"
+)
+
+// MaxSNINames is the most names the sni annotation of a pod can list, as each adds a probe of every port of
+// the pod to the scan
+const MaxSNINames = 16
+
+type podAnnotations struct {
+	ignore     bool
+	ports      map[string]bool
+	serverName string
+	sni        []string
+	labels     Labels
+}
+
+// parseAnnotations reads the scan control annotations from the given pod. Returns an error if any
+// annotation is malformed. Labels the scanner reserves are dropped from the labels annotation, as are sni names
+// after the first MaxSNINames.
+func parseAnnotations(pod *v1.Pod) (*podAnnotations, error) {
+	annotations := &podAnnotations{
+		serverName: pod.Annotations[ServerNameAnnotation],
+		labels:     Labels{},
+	}
+
+	if value, ok := pod.Annotations[IgnoreAnnotation]; ok {
+		ignore, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s annotation: %v", IgnoreAnnotation, err)
+		}
+		annotations.ignore = ignore
+	}
+
+	if value, ok := pod.Annotations[PortsAnnotation]; ok {
+		annotations.ports = make(map[string]bool)
+		for _, port := range splitAnnotation(value) {
+			annotations.ports[port] = true
+		}
+	}
+
+	if value, ok := pod.Annotations[SNIAnnotation]; ok {
+		annotations.sni = splitAnnotation(value)
+		if len(annotations.sni) > MaxSNINames {
+			slog.Warn("ignoring sni names over the limit in annotation", "pod", pod.Name, "namespace", pod.Namespace, "annotation", SNIAnnotation, "names", len(annotations.sni), "limit", MaxSNINames, "dropped", annotations.sni[MaxSNINames:])
+			annotations.sni = annotations.sni[:MaxSNINames]
+		}
+	}
+
+	for _, label := range splitAnnotation(pod.Annotations[LabelsAnnotation]) {
+		key, value, found := strings.Cut(label, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("error parsing %s annotation: expected key=value but got '%s'", LabelsAnnotation, label)
+		}
+		key = strings.TrimSpace(key)
+		if slices.Contains(ReservedLabels, key) {
+			slog.Warn("ignoring reserved label in annotation", "pod", pod.Name, "namespace", pod.Namespace, "annotation", LabelsAnnotation, "label", key)
+			continue
+		}
+		annotations.labels[key] = strings.TrimSpace(value)
+	}
+	return annotations, nil
+}
+
+// scanPort returns true if the given port should be scanned. When the ports annotation is present only the
+// ports it lists, by name or number, are scanned.
+func (a *podAnnotations) scanPort(port v1.ContainerPort) bool {
+	if a.ports == nil {
+		return true
+	}
+	return a.ports[port.Name] || a.ports[strconv.Itoa(int(port.ContainerPort))]
+}
+
+func splitAnnotation(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,256 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	CertificatesResource = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+)
+
+// certificate holds the subset of the cert-manager Certificate resource used for discovery
+type certificate struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		SecretName string `json:"secretName"`
+	} `json:"spec"`
+	Status struct {
+		NotAfter    string `json:"notAfter"`
+		RenewalTime string `json:"renewalTime"`
+		Conditions  []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+type CertManagerDiscovery struct {
+	certificates dynamic.Interface
+	secrets      SecretsInterface
+	pods         PodsInterface
+	CertManagerDiscoveryConfig
+}
+
+type CertManagerDiscoveryConfig struct {
+	source    string
+	labelKeys []string
+	namespace string
+}
+
+// CreateCertManagerDiscovery creates a discovery that reads cert-manager Certificates via the dynamic client. Each
+// Certificate is linked to its secret and to the pods that mount the secret, so the certificates stored and served
+// for it can be compared with the renewal status reported by cert-manager.
+func CreateCertManagerDiscovery(config CertManagerDiscoveryConfig, certificates dynamic.Interface, secrets SecretsInterface, pods PodsInterface) (*CertManagerDiscovery, error) {
+	slog.Info("creating k8s cert-manager discovery", "source", config.source, "namespace", config.namespace, "keys", strings.Join(config.labelKeys, ","))
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the cluster is required")
+	}
+	if certificates == nil {
+		return nil, fmt.Errorf("no dynamic client has been provided for certificate discovery")
+	}
+	if secrets == nil {
+		return nil, fmt.Errorf("no secrets api has been provided")
+	}
+	if pods == nil {
+		return nil, fmt.Errorf("no pods api has been provided")
+	}
+
+	return &CertManagerDiscovery{
+		CertManagerDiscoveryConfig: config,
+		certificates:               certificates,
+		secrets:                    secrets,
+		pods:                       pods,
+	}, nil
+}
+
+// Discover lists the cert-manager Certificates in the cluster. For each Certificate a static [Target] is created from
+// the certificates stored in its secret, and a [Target] is created for each port of the ready pods that mount the
+// secret. All of the targets are labeled with the notAfter, renewalTime and Ready condition of the Certificate.
+// Clusters without cert-manager installed are treated as having no Certificates.
+func (d *CertManagerDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	slog.Debug("starting cert-manager discovery", "source", d.source)
+	list, err := d.certificates.Resource(CertificatesResource).Namespace(d.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		slog.Info("cert-manager certificates not present in cluster", "source", d.source)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error discovering certificates: %v", err)
+	}
+
+	certificates := make([]*certificate, 0)
+	if err := list.EachListItem(func(obj runtime.Object) error {
+		c := &certificate{}
+		certificates = append(certificates, c)
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(obj.(*unstructured.Unstructured).Object, c)
+	}); err != nil {
+		return fmt.Errorf("error parsing certificates: %v", err)
+	}
+
+	secrets, err := d.secrets.List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("type", string(v1.SecretTypeTLS)).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("error discovering secrets: %v", err)
+	}
+	secretsByName := make(map[string]*v1.Secret)
+	for x := range secrets.Items {
+		secret := &secrets.Items[x]
+		secretsByName[namespacedKey(secret.Namespace, secret.Name)] = secret
+	}
+
+	pods, err := d.pods.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error discovering pods: %v", err)
+	}
+	mounts := secretMounts(pods.Items)
+
+	numTargets := 0
+	for _, c := range certificates {
+		key := namespacedKey(c.Namespace, c.Spec.SecretName)
+		numTargets += d.discoverCertificate(c, secretsByName[key], mounts[key], targets)
+	}
+	slog.Info("finished cert-manager discovery", "certificates", len(certificates), "targets", numTargets)
+	return nil
+}
+
+func (d *CertManagerDiscovery) discoverCertificate(c *certificate, secret *v1.Secret, pods []*v1.Pod, targets chan *Target) int {
+	podNames := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		podNames = append(podNames, pod.Name)
+	}
+	sort.Strings(podNames)
+
+	labels := Labels{
+		CertManagerCertificate: c.Name,
+		CertManagerNotAfter:    c.Status.NotAfter,
+		CertManagerRenewalTime: c.Status.RenewalTime,
+		CertManagerReady:       c.ready(),
+		SecretName:             c.Spec.SecretName,
+		Namespace:              c.Namespace,
+	}
+	for _, key := range d.labelKeys {
+		if label, ok := c.Labels[key]; ok {
+			labels[key] = label
+		}
+	}
+
+	var certificates []*x509.Certificate
+	if secret == nil {
+		slog.Debug("certificate secret not found", "namespace", c.Namespace, "certificate", c.Name, "secret", c.Spec.SecretName)
+	} else if parsed, err := parseCertificates(secret.Data[v1.TLSCertKey]); err != nil {
+		slog.Error("error parsing certificate secret", "namespace", c.Namespace, "certificate", c.Name, "secret", c.Spec.SecretName, "error", err.Error())
+	} else {
+		certificates = parsed
+		labels[CertManagerSecretFingerprint] = CertificateFingerprint(certificates[0])
+	}
+
+	numTargets := 0
+	if certificates != nil {
+		numTargets++
+		targets <- &Target{
+			Address: CreateStaticAddress(fmt.Sprintf("secret/%s/%s", c.Namespace, c.Spec.SecretName), certificates),
+			Metadata: Metadata{
+				Name:       c.Name,
+				Source:     d.source,
+				SourceType: Kubernetes,
+				Labels:     labels,
+			},
+		}
+	}
+
+	for _, pod := range pods {
+		if !isPodReady(pod) {
+			continue
+		}
+		ip, err := netip.ParseAddr(pod.Status.PodIP)
+		if err != nil {
+			slog.Error("error parsing pod ip", "namespace", pod.Namespace, "pod", pod.Name, "ip", pod.Status.PodIP, "error", err.Error())
+			continue
+		}
+
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Protocol != v1.ProtocolTCP {
+					continue
+				}
+
+				podLabels := Labels{
+					PodName:   pod.Name,
+					Container: container.Name,
+					PortName:  port.Name,
+				}
+				for k, v := range labels {
+					podLabels[k] = v
+				}
+
+				numTargets++
+				targets <- &Target{
+					Address: CreateNetIPAddress(netip.AddrPortFrom(ip, uint16(port.ContainerPort))),
+					Metadata: Metadata{
+						Name:       pod.Name,
+						Source:     d.source,
+						SourceType: Kubernetes,
+						Labels:     podLabels,
+						Network:    d.source,
+					},
+				}
+			}
+		}
+	}
+	slog.Debug("created targets from certificate", "namespace", c.Namespace, "certificate", c.Name, "secret", c.Spec.SecretName, "mounted_by", strings.Join(podNames, ","), "targets", numTargets)
+	return numTargets
+}
+
+// ready returns the status of the Ready condition of the certificate, or Unknown if it has not been set
+func (c *certificate) ready() string {
+	for _, condition := range c.Status.Conditions {
+		if condition.Type == "Ready" {
+			return condition.Status
+		}
+	}
+	return string(metav1.ConditionUnknown)
+}
+
+// secretMounts indexes pods by the namespaced secrets they mount as volumes
+func secretMounts(pods []v1.Pod) map[string][]*v1.Pod {
+	mounts := make(map[string][]*v1.Pod)
+	for x := range pods {
+		pod := &pods[x]
+		mounted := map[string]bool{}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil {
+				mounted[volume.Secret.SecretName] = true
+			}
+			if volume.Projected != nil {
+				for _, source := range volume.Projected.Sources {
+					if source.Secret != nil {
+						mounted[source.Secret.Name] = true
+					}
+				}
+			}
+		}
+		for name := range mounted {
+			key := namespacedKey(pod.Namespace, name)
+			mounts[key] = append(mounts[key], pod)
+		}
+	}
+	return mounts
+}
@@ -0,0 +1,182 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/discovery/kubernetes/mocks"
+	"github.com/sgargan/cert-scanner-darkly/testutils"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type CertManagerTests struct {
+	config       CertManagerDiscoveryConfig
+	certificates []*unstructured.Unstructured
+	secrets      *mocks.SecretsInterface
+	secretList   *v1.SecretList
+	pods         MockPods
+	ca           *testutils.TestCA
+	suite.Suite
+}
+
+func (t *CertManagerTests) SetupTest() {
+	ca, err := testutils.CreateTestCA(2)
+	t.NoError(err)
+	t.ca = ca
+	t.certificates = make([]*unstructured.Unstructured, 0)
+	t.secrets = &mocks.SecretsInterface{}
+	t.secretList = &v1.SecretList{Items: make([]v1.Secret, 0)}
+	t.pods = NewMockPods()
+	t.config = CertManagerDiscoveryConfig{
+		source: "some-cluster",
+	}
+}
+
+func (t *CertManagerTests) TestDiscoveryCreationErrors() {
+	certificates := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	_, err := CreateCertManagerDiscovery(CertManagerDiscoveryConfig{}, certificates, t.secrets, t.pods.pods)
+	t.ErrorContains(err, "a valid source label for the cluster is required")
+
+	_, err = CreateCertManagerDiscovery(t.config, nil, t.secrets, t.pods.pods)
+	t.ErrorContains(err, "no dynamic client has been provided for certificate discovery")
+
+	_, err = CreateCertManagerDiscovery(t.config, certificates, nil, t.pods.pods)
+	t.ErrorContains(err, "no secrets api has been provided")
+
+	_, err = CreateCertManagerDiscovery(t.config, certificates, t.secrets, nil)
+	t.ErrorContains(err, "no pods api has been provided")
+}
+
+func (t *CertManagerTests) TestLinksCertificateToSecretAndMountingPods() {
+	cert, certPem, _, err := t.ca.CreateLeafCert("somehost")
+	t.NoError(err)
+	t.addCertificate("some-cert", "some-namespace", "some-secret", "True")
+	t.addSecret("some-secret", "some-namespace", certPem)
+	t.addPod("some-pod", "some-namespace", "10.0.0.1", "some-secret")
+	t.addPod("another-pod", "some-namespace", "10.0.0.2", "another-secret")
+
+	targets := t.discover(3)
+	t.Equal(2, len(targets))
+
+	labels := map[string]string{
+		"certificate":              "some-cert",
+		"certificate_not_after":    "2030-01-01T00:00:00Z",
+		"certificate_renewal_time": "2029-12-01T00:00:00Z",
+		"certificate_ready":        "True",
+		"secret":                   "some-secret",
+		"secret_fingerprint":       CertificateFingerprint(cert),
+		"target_namespace":         "some-namespace",
+	}
+	t.Equal(&Target{
+		Address: CreateStaticAddress("secret/some-namespace/some-secret", []*x509.Certificate{cert}),
+		Metadata: Metadata{
+			Name:       "some-cert",
+			Source:     "some-cluster",
+			SourceType: "kubernetes",
+			Labels:     labels,
+		},
+	}, <-targets)
+
+	labels["target_pod"] = "some-pod"
+	labels["container"] = "somecontainer"
+	labels["port_name"] = "some-port"
+	t.Equal(&Target{
+		Address: getAddress("10.0.0.1:8443"),
+		Metadata: Metadata{
+			Name:       "some-pod",
+			Source:     "some-cluster",
+			SourceType: "kubernetes",
+			Network:    "some-cluster",
+			Labels:     labels,
+		},
+	}, <-targets)
+}
+
+func (t *CertManagerTests) TestDiscoversPodsWhenSecretIsMissing() {
+	t.addCertificate("some-cert", "some-namespace", "some-secret", "False")
+	t.addPod("some-pod", "some-namespace", "10.0.0.1", "some-secret")
+
+	targets := t.discover(2)
+	t.Equal(1, len(targets))
+	target := <-targets
+	t.Equal("10.0.0.1:8443", target.Address.String())
+	t.Equal("False", target.Labels()["certificate_ready"])
+}
+
+func (t *CertManagerTests) TestMissingCertManagerIsIgnored() {
+	certificates := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		CertificatesResource: "CertificateList",
+	})
+	certificates.PrependReactor("list", "certificates", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(CertificatesResource.GroupResource(), "")
+	})
+	discovery, err := CreateCertManagerDiscovery(t.config, certificates, t.secrets, t.pods.Build())
+	t.NoError(err)
+	targets := make(chan *Target, 1)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	t.Equal(0, len(targets))
+}
+
+func (t *CertManagerTests) discover(buffer int) chan *Target {
+	certificates := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		CertificatesResource: "CertificateList",
+	})
+	for _, obj := range t.certificates {
+		_, err := certificates.Resource(CertificatesResource).Namespace(obj.GetNamespace()).Create(context.Background(), obj, metav1.CreateOptions{})
+		t.NoError(err)
+	}
+	t.secrets.On("List", mock.Anything, mock.AnythingOfType("v1.ListOptions")).Return(t.secretList, nil)
+
+	discovery, err := CreateCertManagerDiscovery(t.config, certificates, t.secrets, t.pods.Build())
+	t.NoError(err)
+	targets := make(chan *Target, buffer)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	return targets
+}
+
+func (t *CertManagerTests) addCertificate(name, namespace, secret, ready string) {
+	t.certificates = append(t.certificates, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec":       map[string]interface{}{"secretName": secret},
+		"status": map[string]interface{}{
+			"notAfter":    "2030-01-01T00:00:00Z",
+			"renewalTime": "2029-12-01T00:00:00Z",
+			"conditions":  []interface{}{map[string]interface{}{"type": "Ready", "status": ready}},
+		},
+	}})
+}
+
+func (t *CertManagerTests) addSecret(name, namespace string, certPem []byte) {
+	t.secretList.Items = append(t.secretList.Items, v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       v1.SecretTypeTLS,
+		Data:       map[string][]byte{v1.TLSCertKey: certPem},
+	})
+}
+
+func (t *CertManagerTests) addPod(name, namespace, ip, secret string) {
+	t.pods.AddPods(name, namespace, map[string]string{}, v1.PodIP{IP: ip}, createContainerPort(8443))
+	pod := &t.pods.list.Items[len(t.pods.list.Items)-1]
+	pod.Spec.Volumes = []v1.Volume{{
+		Name:         "tls",
+		VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: secret}},
+	}}
+}
+
+func TestCertManagerSuite(t *testing.T) {
+	suite.Run(t, &CertManagerTests{})
+}
@@ -10,8 +10,18 @@ import (
 
 // GetClientset loads a kubernetes clientset to interact with kubernetes api
 func GetClientset() (*rest.Config, *kubernetes.Clientset, error) {
+	return GetClientsetForContext("", "")
+}
+
+// GetClientsetForContext loads a kubernetes clientset for a context in the given kubeconfig file. An empty
+// path uses the default kubeconfig loading rules and an empty context uses the current context.
+func GetClientsetForContext(kubeconfig, context string) (*rest.Config, *kubernetes.Clientset, error) {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, nil)
+	if kubeconfig != "" {
+		loadingRules.ExplicitPath = kubeconfig
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
 
 	clientConfig, err := kubeConfig.ClientConfig()
 	if err != nil {
@@ -0,0 +1,72 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+)
+
+// ClusterConfig configures pod discovery for one of several clusters, each loaded from its own kubeconfig
+// and context
+type ClusterConfig struct {
+	Source           string          `mapstructure:"source"`
+	Kubeconfig       string          `mapstructure:"kubeconfig"`
+	Context          string          `mapstructure:"context"`
+	Namespace        string          `mapstructure:"namespace"`
+	MatchCIDR        []string        `mapstructure:"match_cidr"`
+	IgnorePods       []IgnorePattern `mapstructure:"ignore_pods"`
+	IgnoreContainers []IgnorePattern `mapstructure:"ignore_containers"`
+}
+
+// MultiClusterDiscovery runs the pod discovery of a number of clusters in parallel
+type MultiClusterDiscovery struct {
+	clusters []Discovery
+}
+
+// CreateMultiClusterDiscovery creates a discovery from the discoveries of each cluster. Each cluster discovery
+// labels its targets with its own source so that the results from each cluster are kept apart.
+func CreateMultiClusterDiscovery(clusters []Discovery) (*MultiClusterDiscovery, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("at least one cluster discovery is required")
+	}
+	return &MultiClusterDiscovery{clusters: clusters}, nil
+}
+
+// Discover runs the discovery for every cluster in parallel. A failure in one cluster does not stop discovery
+// in the others, the errors from all failed clusters are returned together.
+func (d *MultiClusterDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	slog.Debug("starting multi cluster discovery", "clusters", len(d.clusters))
+	errs := make([]error, len(d.clusters))
+	wait := sync.WaitGroup{}
+	wait.Add(len(d.clusters))
+	for x, cluster := range d.clusters {
+		go func(x int, cluster Discovery) {
+			defer wait.Done()
+			errs[x] = cluster.Discover(ctx, targets)
+		}(x, cluster)
+	}
+	wait.Wait()
+	return errors.Join(errs...)
+}
+
+// Watch watches each cluster discovery that can be watched for changes, returning once they have all stopped.
+// The errors from all clusters that could not be watched are returned together.
+func (d *MultiClusterDiscovery) Watch(ctx context.Context, changes chan *TargetChanges) error {
+	errs := make([]error, len(d.clusters))
+	wait := sync.WaitGroup{}
+	for x, cluster := range d.clusters {
+		if watching, ok := cluster.(WatchingDiscovery); ok {
+			wait.Add(1)
+			go func(x int, watching WatchingDiscovery) {
+				defer wait.Done()
+				errs[x] = watching.Watch(ctx, changes)
+			}(x, watching)
+		}
+	}
+	wait.Wait()
+	return errors.Join(errs...)
+}
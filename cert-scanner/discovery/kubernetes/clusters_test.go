@@ -0,0 +1,145 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClusterTests struct {
+	suite.Suite
+}
+
+func (t *ClusterTests) TearDownTest() {
+	viper.Set(config.DiscoveryK8sClusters, nil)
+}
+
+func (t *ClusterTests) TestDiscoveryCreationErrors() {
+	_, err := CreateMultiClusterDiscovery(nil)
+	t.ErrorContains(err, "at least one cluster discovery is required")
+}
+
+func (t *ClusterTests) TestDiscoversFromAllClusters() {
+	discovery, err := CreateMultiClusterDiscovery([]Discovery{
+		&clusterDiscovery{source: "some-cluster", ip: "10.0.0.1"},
+		&clusterDiscovery{source: "another-cluster", ip: "10.0.0.1"},
+	})
+	t.NoError(err)
+
+	targets := make(chan *Target, 2)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	close(targets)
+
+	sources := make([]string, 0)
+	for target := range targets {
+		sources = append(sources, target.Source)
+	}
+	t.ElementsMatch([]string{"some-cluster", "another-cluster"}, sources)
+}
+
+func (t *ClusterTests) TestFailingClusterDoesNotStopOthers() {
+	discovery, err := CreateMultiClusterDiscovery([]Discovery{
+		&clusterDiscovery{source: "some-cluster", err: errors.New("something barfed")},
+		&clusterDiscovery{source: "another-cluster", ip: "10.0.0.1"},
+	})
+	t.NoError(err)
+
+	targets := make(chan *Target, 2)
+	t.ErrorContains(discovery.Discover(context.Background(), targets), "something barfed")
+	t.Equal(1, len(targets))
+	t.Equal("another-cluster", (<-targets).Source)
+}
+
+func (t *ClusterTests) TestDiscoveryLoadsClusters() {
+	kubeconfig := filepath.Join(t.T().TempDir(), "config")
+	t.NoError(os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600))
+
+	viper.Set(config.DiscoveryK8sClusters, []map[string]interface{}{
+		{"source": "some-cluster", "kubeconfig": kubeconfig, "context": "some-context", "namespace": "some-namespace"},
+		{"source": "another-cluster", "kubeconfig": kubeconfig, "context": "another-context", "match_cidr": []string{"10.1.0.0/16", "fd01::/64"},
+			"ignore_pods": []map[string]interface{}{{"pattern": "{.metadata.name}", "match": []string{"some-pod"}}}},
+	})
+
+	d, err := CreateDiscovery()
+	t.NoError(err)
+
+	clusters := d.(*MultiClusterDiscovery).clusters
+	t.Len(clusters, 2)
+
+	some := clusters[0].(*PodDiscovery)
+	t.Equal("some-cluster", some.source)
+	t.Equal("some-namespace", some.namespace)
+	t.Len(some.ignorePatterns, 1)
+
+	another := clusters[1].(*PodDiscovery)
+	t.Equal("another-cluster", another.source)
+	t.Equal("10.1.0.0/16,fd01::/64", formatCIDRs(another.matchCIDRs))
+	t.Len(another.ignorePatterns, 2)
+}
+
+func (t *ClusterTests) TestUnknownContextRaisesError() {
+	kubeconfig := filepath.Join(t.T().TempDir(), "config")
+	t.NoError(os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600))
+
+	viper.Set(config.DiscoveryK8sClusters, []map[string]interface{}{
+		{"source": "some-cluster", "kubeconfig": kubeconfig, "context": "missing-context"},
+	})
+
+	_, err := CreateDiscovery()
+	t.ErrorContains(err, "error creating discovery for cluster some-cluster")
+}
+
+type clusterDiscovery struct {
+	source string
+	ip     string
+	err    error
+}
+
+func (d *clusterDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	if d.err != nil {
+		return d.err
+	}
+	targets <- &Target{
+		Address:  CreateNetIPAddress(netip.MustParseAddrPort(fmt.Sprintf("%s:443", d.ip))),
+		Metadata: Metadata{Source: d.source, SourceType: Kubernetes},
+	}
+	return nil
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: some-cluster
+  cluster:
+    server: https://10.0.0.1:6443
+- name: another-cluster
+  cluster:
+    server: https://10.0.0.2:6443
+contexts:
+- name: some-context
+  context:
+    cluster: some-cluster
+    user: some-user
+- name: another-context
+  context:
+    cluster: another-cluster
+    user: some-user
+current-context: some-context
+users:
+- name: some-user
+  user:
+    token: some-token
+`
+
+func TestClusterSuite(t *testing.T) {
+	suite.Run(t, &ClusterTests{})
+}
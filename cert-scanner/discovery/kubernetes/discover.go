@@ -3,46 +3,219 @@ package kubernetes
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/sgargan/cert-scanner-darkly/config"
 	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/spf13/viper"
+	"k8s.io/client-go/dynamic"
 )
 
 // CreateKubernetesDiscovery creates Discovery instance to detect TLS based services running in
-// a kubernetes cluster
+// a kubernetes cluster. When a list of clusters is configured, a discovery is created for each
+// and they are run in parallel.
 func CreateDiscovery() (Discovery, error) {
-	_, client, err := GetClientset()
+	var clusters []ClusterConfig
+	if err := viper.UnmarshalKey(config.DiscoveryK8sClusters, &clusters); err != nil {
+		return nil, fmt.Errorf("error parsing clusters: %v", err)
+	}
+
+	if len(clusters) == 0 {
+		cluster := ClusterConfig{
+			Source:    viper.GetString(config.DiscoveryK8sSource),
+			Namespace: viper.GetString(config.DiscoveryK8sNamespace),
+			MatchCIDR: viper.GetStringSlice(config.DiscoveryK8sMatchCIDR),
+		}
+		if err := viper.UnmarshalKey(config.DiscoveryK8sIgnorePatterns, &cluster.IgnorePods); err != nil {
+			return nil, fmt.Errorf("error parsing ignore patterns: %v", err)
+		}
+		if err := viper.UnmarshalKey(config.DiscoveryK8sIgnoreContainers, &cluster.IgnoreContainers); err != nil {
+			return nil, fmt.Errorf("error parsing ignore containers: %v", err)
+		}
+		return createClusterDiscovery(cluster)
+	}
+
+	discoveries := make([]Discovery, 0, len(clusters))
+	for _, cluster := range clusters {
+		discovery, err := createClusterDiscovery(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("error creating discovery for cluster %s: %v", cluster.Source, err)
+		}
+		discoveries = append(discoveries, discovery)
+	}
+	return CreateMultiClusterDiscovery(discoveries)
+}
+
+func createClusterDiscovery(cluster ClusterConfig) (Discovery, error) {
+	_, client, err := GetClientsetForContext(cluster.Kubeconfig, cluster.Context)
 	if err != nil {
 		return nil, fmt.Errorf("error getting kubernetes client set: %v", err)
 	}
 
-	var matchCIDR *net.IPNet
-	configuredCidr := viper.GetString(config.DiscoveryK8sMatchCIDR)
-	if configuredCidr != "" {
-		_, matchCIDR, err = net.ParseCIDR(configuredCidr)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing match cidr: %v", err)
+	matchCIDRs, err := parseCIDRs(cluster.MatchCIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := PodDiscoveryConfig{
+		source:            cluster.Source,
+		labelKeys:         viper.GetStringSlice(config.DiscoveryK8sKeys),
+		ignorePatterns:    cluster.IgnorePods,
+		ignoreContainers:  cluster.IgnoreContainers,
+		matchCIDRs:        matchCIDRs,
+		namespace:         cluster.Namespace,
+		labelSelector:     viper.GetString(config.DiscoveryK8sLabelSelector),
+		fieldSelector:     viper.GetString(config.DiscoveryK8sFieldSelector),
+		namespaceSelector: viper.GetString(config.DiscoveryK8sNamespaceSelector),
+		pageSize:          viper.GetInt64(config.DiscoveryK8sPageSize),
+	}
+
+	var workloads *WorkloadResolver
+	if viper.GetBool(config.DiscoveryK8sWorkloads) {
+		if workloads, err = CreateWorkloadResolver(client.AppsV1(), client.BatchV1(), viper.GetStringSlice(config.DiscoveryK8sWorkloadAnnotations)); err != nil {
+			return nil, err
 		}
 	}
 
+	pods := client.CoreV1().Pods(cluster.Namespace)
+	namespaces := client.CoreV1().Namespaces()
+	// an informer only pays off when its cache is kept between scans
+	if viper.GetBool(config.Repeated) && viper.GetBool(config.DiscoveryK8sInformer) {
+		return CreateInformerPodDiscovery(cfg, viper.GetDuration(config.DiscoveryK8sResyncPeriod), pods, namespaces, workloads)
+	}
+	return CreatePodDiscoveryWithWorkloads(cfg, pods, namespaces, workloads)
+}
+
+// CreateServiceDiscoveryFromConfig creates a Discovery instance to detect TLS based services via
+// the Services and EndpointSlices of a kubernetes cluster
+func CreateServiceDiscoveryFromConfig() (Discovery, error) {
+	_, client, err := GetClientset()
+	if err != nil {
+		return nil, fmt.Errorf("error getting kubernetes client set: %v", err)
+	}
+
+	matchCIDR, err := parseMatchCIDR(config.DiscoveryK8sServicesMatchCIDR)
+	if err != nil {
+		return nil, err
+	}
+
 	var ignorePatterns []IgnorePattern
-	if err := viper.UnmarshalKey(config.DiscoveryK8sIgnorePatterns, &ignorePatterns); err != nil {
-		return nil, fmt.Errorf("error parsing ignore patterns: %v", err)
+	if err := viper.UnmarshalKey(config.DiscoveryK8sServicesIgnore, &ignorePatterns); err != nil {
+		return nil, fmt.Errorf("error parsing ignore services: %v", err)
 	}
 
-	var ignoreContainers []IgnorePattern
-	if err := viper.UnmarshalKey(config.DiscoveryK8sIgnoreContainers, &ignoreContainers); err != nil {
-		return nil, fmt.Errorf("error parsing ignore containers: %v", err)
+	namespace := viper.GetString(config.DiscoveryK8sServicesNamespace)
+	cfg := ServiceDiscoveryConfig{
+		source:         viper.GetString(config.DiscoveryK8sServicesSource),
+		labelKeys:      viper.GetStringSlice(config.DiscoveryK8sServicesKeys),
+		ignorePatterns: ignorePatterns,
+		matchCIDR:      matchCIDR,
+		namespace:      namespace,
+		pageSize:       viper.GetInt64(config.DiscoveryK8sServicesPageSize),
 	}
 
-	cfg := PodDiscoveryConfig{
-		source:           viper.GetString(config.DiscoveryK8sSource),
-		labelKeys:        viper.GetStringSlice(config.DiscoveryK8sKeys),
-		ignorePatterns:   ignorePatterns,
-		ignoreContainers: ignoreContainers,
-		matchCIDR:        matchCIDR,
+	return CreateServiceDiscovery(cfg, client.CoreV1().Services(namespace), client.DiscoveryV1().EndpointSlices(namespace))
+}
+
+// CreateIngressDiscoveryFromConfig creates a Discovery instance to detect the TLS hosts served by the
+// Ingresses and, optionally, the Gateway API Gateways of a kubernetes cluster
+func CreateIngressDiscoveryFromConfig() (Discovery, error) {
+	restConfig, client, err := GetClientset()
+	if err != nil {
+		return nil, fmt.Errorf("error getting kubernetes client set: %v", err)
+	}
+
+	cfg := IngressDiscoveryConfig{
+		source:     viper.GetString(config.DiscoveryK8sIngressSource),
+		namespace:  viper.GetString(config.DiscoveryK8sIngressNamespace),
+		gatewayAPI: viper.GetBool(config.DiscoveryK8sIngressGatewayAPI),
 	}
 
-	return CreatePodDiscovery(cfg, client.CoreV1().Pods(viper.GetString(config.DiscoveryK8sNamespace)))
+	var gateways dynamic.Interface
+	if cfg.gatewayAPI {
+		if gateways, err = dynamic.NewForConfig(restConfig); err != nil {
+			return nil, fmt.Errorf("error creating kubernetes dynamic client: %v", err)
+		}
+	}
+
+	return CreateIngressDiscovery(cfg, client.NetworkingV1().Ingresses(cfg.namespace), gateways)
+}
+
+// CreateSecretDiscoveryFromConfig creates a Discovery instance to inventory the certificates stored in
+// the TLS Secrets of a kubernetes cluster without connecting to the workloads that serve them
+func CreateSecretDiscoveryFromConfig() (Discovery, error) {
+	_, client, err := GetClientset()
+	if err != nil {
+		return nil, fmt.Errorf("error getting kubernetes client set: %v", err)
+	}
+
+	namespace := viper.GetString(config.DiscoveryK8sSecretsNamespace)
+	cfg := SecretDiscoveryConfig{
+		source:    viper.GetString(config.DiscoveryK8sSecretsSource),
+		labelKeys: viper.GetStringSlice(config.DiscoveryK8sSecretsKeys),
+		namespace: namespace,
+	}
+
+	return CreateSecretDiscovery(cfg, client.CoreV1().Secrets(namespace))
+}
+
+// CreateCertManagerDiscoveryFromConfig creates a Discovery instance to detect cert-manager Certificates along
+// with the secrets and pods that store and serve them
+func CreateCertManagerDiscoveryFromConfig() (Discovery, error) {
+	restConfig, client, err := GetClientset()
+	if err != nil {
+		return nil, fmt.Errorf("error getting kubernetes client set: %v", err)
+	}
+
+	certificates, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes dynamic client: %v", err)
+	}
+
+	namespace := viper.GetString(config.DiscoveryCertManagerNamespace)
+	cfg := CertManagerDiscoveryConfig{
+		source:    viper.GetString(config.DiscoveryCertManagerSource),
+		labelKeys: viper.GetStringSlice(config.DiscoveryCertManagerKeys),
+		namespace: namespace,
+	}
+
+	return CreateCertManagerDiscovery(cfg, certificates, client.CoreV1().Secrets(namespace), client.CoreV1().Pods(namespace))
+}
+
+func parseMatchCIDR(key string) (*net.IPNet, error) {
+	return parseCIDR(viper.GetString(key))
+}
+
+// parseCIDRs parses a list of cidrs, typically one for each ip family
+func parseCIDRs(configuredCidrs []string) ([]*net.IPNet, error) {
+	cidrs := make([]*net.IPNet, 0, len(configuredCidrs))
+	for _, configuredCidr := range configuredCidrs {
+		cidr, err := parseCIDR(configuredCidr)
+		if err != nil {
+			return nil, err
+		}
+		if cidr != nil {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs, nil
+}
+
+func formatCIDRs(cidrs []*net.IPNet) string {
+	formatted := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		formatted = append(formatted, cidr.String())
+	}
+	return strings.Join(formatted, ",")
+}
+
+func parseCIDR(configuredCidr string) (*net.IPNet, error) {
+	if configuredCidr == "" {
+		return nil, nil
+	}
+	_, matchCIDR, err := net.ParseCIDR(configuredCidr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing match cidr: %v", err)
+	}
+	return matchCIDR, nil
 }
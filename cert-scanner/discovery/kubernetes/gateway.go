@@ -0,0 +1,178 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GatewayName  = "gateway"
+	ListenerName = "listener"
+	RouteName    = "route"
+)
+
+var (
+	GatewaysResource   = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+	HTTPRoutesResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	TLSRoutesResource  = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "tlsroutes"}
+)
+
+// gateway holds the subset of the Gateway API Gateway resource used for discovery
+type gateway struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Listeners []gatewayListener `json:"listeners"`
+	} `json:"spec"`
+	Status struct {
+		Addresses []struct {
+			Value string `json:"value"`
+		} `json:"addresses"`
+	} `json:"status"`
+}
+
+type gatewayListener struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+	Port     int32  `json:"port"`
+	Protocol string `json:"protocol"`
+	TLS      *struct {
+		CertificateRefs []struct {
+			Name string `json:"name"`
+		} `json:"certificateRefs"`
+	} `json:"tls"`
+}
+
+// route holds the subset of the HTTPRoute and TLSRoute resources used for discovery
+type route struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		ParentRefs []struct {
+			Name        string `json:"name"`
+			Namespace   string `json:"namespace"`
+			SectionName string `json:"sectionName"`
+		} `json:"parentRefs"`
+		Hostnames []string `json:"hostnames"`
+	} `json:"spec"`
+}
+
+// discoverGateways lists Gateways and the HTTPRoutes and TLSRoutes attached to them. A target is created
+// for each hostname served by an HTTPS or TLS listener, dialing the Gateway's address.
+func (d *IngressDiscovery) discoverGateways(ctx context.Context, targets chan *Target) (int, error) {
+	gateways := make([]*gateway, 0)
+	if err := d.listGatewayResources(ctx, GatewaysResource, func(obj map[string]interface{}) error {
+		g := &gateway{}
+		gateways = append(gateways, g)
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(obj, g)
+	}); err != nil {
+		return 0, err
+	}
+
+	routes := make([]*route, 0)
+	for _, resource := range []schema.GroupVersionResource{HTTPRoutesResource, TLSRoutesResource} {
+		if err := d.listGatewayResources(ctx, resource, func(obj map[string]interface{}) error {
+			r := &route{}
+			routes = append(routes, r)
+			return runtime.DefaultUnstructuredConverter.FromUnstructured(obj, r)
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	numTargets := 0
+	for _, g := range gateways {
+		numTargets += d.discoverGateway(g, routes, targets)
+	}
+	slog.Debug("finished gateway discovery", "gateways", len(gateways), "routes", len(routes), "targets", numTargets)
+	return numTargets, nil
+}
+
+func (d *IngressDiscovery) discoverGateway(g *gateway, routes []*route, targets chan *Target) int {
+	if len(g.Status.Addresses) == 0 || g.Status.Addresses[0].Value == "" {
+		slog.Debug("gateway has no address", "namespace", g.Namespace, "gateway", g.Name)
+		return 0
+	}
+	dialAddress := g.Status.Addresses[0].Value
+
+	numTargets := 0
+	for _, listener := range g.Spec.Listeners {
+		if listener.Protocol != "HTTPS" && listener.Protocol != "TLS" {
+			continue
+		}
+
+		secretName := ""
+		if listener.TLS != nil && len(listener.TLS.CertificateRefs) > 0 {
+			secretName = listener.TLS.CertificateRefs[0].Name
+		}
+
+		hosts := map[string]string{}
+		if listener.Hostname != "" {
+			hosts[listener.Hostname] = ""
+		}
+		for _, r := range routes {
+			if r.attachedTo(g, listener.Name) {
+				for _, hostname := range r.Spec.Hostnames {
+					hosts[hostname] = r.Name
+				}
+			}
+		}
+
+		for host, routeName := range hosts {
+			labels := Labels{
+				GatewayName:   g.Name,
+				ListenerName:  listener.Name,
+				Namespace:     g.Namespace,
+				TLSSecretName: secretName,
+			}
+			if routeName != "" {
+				labels[RouteName] = routeName
+			}
+			if target := d.createTarget(g.Name, host, listener.Port, dialAddress, labels); target != nil {
+				numTargets++
+				targets <- target
+			}
+		}
+	}
+	return numTargets
+}
+
+// listGatewayResources lists the given resource passing each item to the supplied parse function. The gateway
+// api is optional in a cluster, so missing resource types are logged and treated as empty.
+func (d *IngressDiscovery) listGatewayResources(ctx context.Context, resource schema.GroupVersionResource, parse func(map[string]interface{}) error) error {
+	list, err := d.gateways.Resource(resource).Namespace(d.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		slog.Debug("gateway api resource not present in cluster", "resource", resource.String())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error discovering %s: %v", resource.Resource, err)
+	}
+	return list.EachListItem(func(obj runtime.Object) error {
+		if err := parse(obj.(*unstructured.Unstructured).Object); err != nil {
+			return fmt.Errorf("error parsing %s: %v", resource.Resource, err)
+		}
+		return nil
+	})
+}
+
+// attachedTo checks if the route has a parent reference to the given gateway and listener
+func (r *route) attachedTo(g *gateway, listener string) bool {
+	for _, parent := range r.Spec.ParentRefs {
+		namespace := parent.Namespace
+		if namespace == "" {
+			namespace = r.Namespace
+		}
+		if parent.Name == g.Name && namespace == g.Namespace && (parent.SectionName == "" || parent.SectionName == listener) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// InformerPodDiscovery discovers pods from a shared informer cache rather than listing them from the api on each
+// scan. It is intended for repeated scans, where each scan only needs the pods that changed since the last one.
+// The targets of pods that are deleted, or change so they no longer have them, are watched for and reported removed.
+type InformerPodDiscovery struct {
+	*PodDiscovery
+	informer   cache.SharedIndexInformer
+	handler    cache.ResourceEventHandlerRegistration
+	resync     time.Duration
+	start      sync.Once
+	stopped    sync.Once
+	stop       chan struct{}
+	pending    map[string]struct{}
+	discovered map[string][]*Target
+	selected   map[string]bool
+	removed    []*Target
+	notify     chan struct{}
+	lock       sync.Mutex
+}
+
+// CreateInformerPodDiscovery creates a pod discovery backed by an informer that watches the given pods api. The
+// informer delivers every cached pod again each resync period, so all pods are rescanned at least that often.
+// Pods are filtered with the same selectors and ignore patterns as [PodDiscovery].
+func CreateInformerPodDiscovery(config PodDiscoveryConfig, resync time.Duration, pods PodsInterface, namespaces NamespacesInterface, workloads *WorkloadResolver) (*InformerPodDiscovery, error) {
+	discovery, err := CreatePodDiscoveryWithWorkloads(config, pods, namespaces, workloads)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("creating k8s informer discovery", "source", config.source, "resync", resync.String())
+
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = config.labelSelector
+			options.FieldSelector = config.fieldSelector
+			return pods.List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = config.labelSelector
+			options.FieldSelector = config.fieldSelector
+			return pods.Watch(context.Background(), options)
+		},
+	}, &v1.Pod{}, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+
+	// managed fields are not needed for discovery and are a large part of each cached pod
+	if err := informer.SetTransform(func(obj interface{}) (interface{}, error) {
+		if pod, ok := obj.(*v1.Pod); ok {
+			pod.ManagedFields = nil
+		}
+		return obj, nil
+	}); err != nil {
+		return nil, fmt.Errorf("error configuring pod informer: %v", err)
+	}
+
+	d := &InformerPodDiscovery{
+		PodDiscovery: discovery,
+		informer:     informer,
+		resync:       resync,
+		stop:         make(chan struct{}),
+		pending:      make(map[string]struct{}),
+		discovered:   make(map[string][]*Target),
+		notify:       make(chan struct{}, 1),
+	}
+
+	d.handler, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    d.enqueue,
+		UpdateFunc: func(_, obj interface{}) { d.enqueue(obj) },
+		DeleteFunc: d.dequeue,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error configuring pod informer: %v", err)
+	}
+	return d, nil
+}
+
+// Discover starts the informer on first use and waits for its cache to sync. It then creates candidate [Target]s
+// for the pods added or changed since the previous call, so the first call returns targets for all pods. The
+// targets are marked incremental, as the targets of unchanged pods are not discovered again. Pods outside the
+// namespaces matching the namespace selector are skipped. Pods in namespaces that have become selected are
+// discovered again, and the targets of pods in namespaces that are no longer selected are reported removed.
+// Returns an error if the cache cannot be synced before the context is done.
+func (d *InformerPodDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	d.start.Do(func() {
+		slog.Debug("starting pod informer", "source", d.source)
+		go d.informer.Run(d.stop)
+	})
+
+	// the handler has synced once it has been delivered every pod in the initial list
+	if !cache.WaitForCacheSync(ctx.Done(), d.handler.HasSynced) {
+		return fmt.Errorf("error discovering pods: timed out waiting for pod informer cache to sync")
+	}
+
+	selected, err := d.selectNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	namespaces := make(map[string]bool, len(selected))
+	for _, namespace := range selected {
+		namespaces[namespace] = true
+	}
+	if selected != nil {
+		d.reselect(namespaces)
+	}
+
+	d.resetWorkloads()
+	keys := d.drain()
+	nodeAddresses := make(map[netip.AddrPort]bool)
+	numTargets := 0
+	for _, key := range keys {
+		obj, exists, err := d.informer.GetStore().GetByKey(key)
+		if err != nil {
+			slog.Error("error retrieving pod from informer cache", "pod", key, "error", err.Error())
+			continue
+		}
+		if !exists {
+			continue
+		}
+		pod := obj.(*v1.Pod)
+		if selected != nil && !namespaces[pod.Namespace] {
+			continue
+		}
+		podTargets := d.discoverPod(ctx, pod, nodeAddresses)
+		for _, target := range podTargets {
+			target.Incremental = true
+			targets <- target
+		}
+		d.replace(key, podTargets)
+		numTargets += len(podTargets)
+	}
+	slog.Info("finished informer pod discovery", "changed", len(keys), "cached", len(d.informer.GetStore().ListKeys()), "targets", numTargets)
+	return nil
+}
+
+// Watch emits the targets of pods that were deleted, or changed so they no longer have them, as removed until the
+// context is done. As each discovery only creates targets for the pods that changed, this is the only way the
+// targets of a pod are known to be gone. The informer is stopped once the context is done.
+func (d *InformerPodDiscovery) Watch(ctx context.Context, changes chan *TargetChanges) error {
+	defer d.Stop()
+	for {
+		select {
+		case <-d.notify:
+			d.lock.Lock()
+			removed := d.removed
+			d.removed = nil
+			d.lock.Unlock()
+			if len(removed) == 0 {
+				continue
+			}
+			slog.Info("pod targets removed", "source", d.source, "removed", len(removed))
+			select {
+			case changes <- &TargetChanges{Removed: removed}:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Stop shuts down the informer, if it has not already been stopped
+func (d *InformerPodDiscovery) Stop() {
+	d.stopped.Do(func() {
+		slog.Debug("stopping pod informer", "source", d.source)
+		close(d.stop)
+	})
+}
+
+// reselect queues the pods of namespaces that were not selected by the previous discovery to be discovered, as
+// they were skipped while their namespace was not selected. The targets of pods in namespaces that are no longer
+// selected are queued to be reported removed.
+func (d *InformerPodDiscovery) reselect(namespaces map[string]bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for namespace := range namespaces {
+		if d.selected != nil && d.selected[namespace] {
+			continue
+		}
+		keys, err := d.informer.GetIndexer().IndexKeys(cache.NamespaceIndex, namespace)
+		if err != nil {
+			slog.Error("error retrieving pods of namespace from informer cache", "namespace", namespace, "error", err.Error())
+			continue
+		}
+		for _, key := range keys {
+			d.pending[key] = struct{}{}
+		}
+		slog.Debug("namespace selected", "source", d.source, "namespace", namespace, "pods", len(keys))
+	}
+
+	deselected := make([]*Target, 0)
+	for key, targets := range d.discovered {
+		if namespace, _, err := cache.SplitMetaNamespaceKey(key); err == nil && !namespaces[namespace] {
+			deselected = append(deselected, targets...)
+			delete(d.discovered, key)
+		}
+	}
+	d.queueRemoved(deselected)
+	d.selected = namespaces
+}
+
+// replace records the targets discovered for a pod, queueing the targets it had before that it no longer has
+// to be reported removed
+func (d *InformerPodDiscovery) replace(key string, targets []*Target) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	previous := d.discovered[key]
+	if len(targets) == 0 {
+		delete(d.discovered, key)
+	} else {
+		d.discovered[key] = targets
+	}
+	d.queueRemoved(previous)
+}
+
+// queueRemoved queues the given targets to be reported removed, unless another pod has since been discovered
+// with the same address. Must be called holding the lock.
+func (d *InformerPodDiscovery) queueRemoved(targets []*Target) {
+	held := make(map[string]bool)
+	for _, podTargets := range d.discovered {
+		for _, target := range podTargets {
+			held[target.Address.String()] = true
+		}
+	}
+	queued := false
+	for _, target := range targets {
+		if !held[target.Address.String()] {
+			d.removed = append(d.removed, target)
+			queued = true
+		}
+	}
+	if queued {
+		select {
+		case d.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (d *InformerPodDiscovery) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		slog.Error("error creating key for pod", "error", err.Error())
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.pending[key] = struct{}{}
+}
+
+func (d *InformerPodDiscovery) dequeue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		slog.Error("error creating key for pod", "error", err.Error())
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.pending, key)
+	previous := d.discovered[key]
+	delete(d.discovered, key)
+	d.queueRemoved(previous)
+}
+
+// drain returns the keys of the pods changed since the last drain, sorted so they are discovered in a stable order
+func (d *InformerPodDiscovery) drain() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	keys := make([]string, 0, len(d.pending))
+	for key := range d.pending {
+		keys = append(keys, key)
+	}
+	d.pending = make(map[string]struct{})
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,310 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sgargan/cert-scanner-darkly/reporters/metrics"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/suite"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type InformerTests struct {
+	config    PodDiscoveryConfig
+	client    *fake.Clientset
+	discovery *InformerPodDiscovery
+	suite.Suite
+}
+
+func (t *InformerTests) SetupTest() {
+	t.config = PodDiscoveryConfig{
+		source: "some-cluster",
+	}
+	t.client = fake.NewSimpleClientset(
+		createPod("some-pod", "10.0.0.1"),
+		createPod("another-pod", "10.0.0.2"),
+		createPod("cert-scanner-abcde", "10.0.0.3"),
+	)
+}
+
+func (t *InformerTests) TearDownTest() {
+	if t.discovery != nil {
+		t.discovery.Stop()
+		t.discovery = nil
+	}
+}
+
+func (t *InformerTests) TestDiscoveryCreationErrors() {
+	_, err := CreateInformerPodDiscovery(PodDiscoveryConfig{}, time.Hour, t.client.CoreV1().Pods(""), nil, nil)
+	t.ErrorContains(err, "a valid source label for the cluster is required")
+
+	_, err = CreateInformerPodDiscovery(t.config, time.Hour, nil, nil, nil)
+	t.ErrorContains(err, "no pods api has been provided")
+}
+
+func (t *InformerTests) TestDiscoversOnlyChangedPods() {
+	t.create(time.Hour)
+	t.ElementsMatch([]string{"10.0.0.1:8080", "10.0.0.2:8080"}, t.discover())
+	t.Empty(t.discover())
+
+	pod := createPod("some-pod", "10.0.0.1")
+	pod.Labels = map[string]string{"changed": "true"}
+	_, err := t.client.CoreV1().Pods("some-namespace").Update(context.Background(), pod, metav1.UpdateOptions{})
+	t.NoError(err)
+	_, err = t.client.CoreV1().Pods("some-namespace").Create(context.Background(), createPod("new-pod", "10.0.0.4"), metav1.CreateOptions{})
+	t.NoError(err)
+
+	t.ElementsMatch([]string{"10.0.0.1:8080", "10.0.0.4:8080"}, t.discoverEventually(2))
+}
+
+func (t *InformerTests) TestResyncRediscoversAllPods() {
+	t.create(100 * time.Millisecond)
+	t.ElementsMatch([]string{"10.0.0.1:8080", "10.0.0.2:8080"}, t.discover())
+	t.ElementsMatch([]string{"10.0.0.1:8080", "10.0.0.2:8080"}, t.discoverEventually(2))
+}
+
+func (t *InformerTests) TestDeletedPodsAreNotDiscovered() {
+	t.create(time.Hour)
+	t.discover()
+
+	pod := createPod("some-pod", "10.0.0.1")
+	pod.Labels = map[string]string{"changed": "true"}
+	_, err := t.client.CoreV1().Pods("some-namespace").Update(context.Background(), pod, metav1.UpdateOptions{})
+	t.NoError(err)
+	t.NoError(t.client.CoreV1().Pods("some-namespace").Delete(context.Background(), "some-pod", metav1.DeleteOptions{}))
+	_, err = t.client.CoreV1().Pods("some-namespace").Create(context.Background(), createPod("new-pod", "10.0.0.4"), metav1.CreateOptions{})
+	t.NoError(err)
+
+	t.ElementsMatch([]string{"10.0.0.4:8080"}, t.discoverEventually(1))
+}
+
+func (t *InformerTests) TestReportsRemovedPodTargets() {
+	t.create(time.Hour)
+	t.discover()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *TargetChanges, 1)
+	go t.discovery.Watch(ctx, changes)
+
+	// a pod that is no longer ready has no targets
+	pod := createPod("another-pod", "10.0.0.2")
+	pod.Status.Conditions[0].Status = v1.ConditionFalse
+	_, err := t.client.CoreV1().Pods("some-namespace").Update(context.Background(), pod, metav1.UpdateOptions{})
+	t.NoError(err)
+	t.Equal([]string{"10.0.0.2:8080"}, t.removedEventually(changes))
+
+	t.NoError(t.client.CoreV1().Pods("some-namespace").Delete(context.Background(), "some-pod", metav1.DeleteOptions{}))
+	t.Equal([]string{"10.0.0.1:8080"}, t.removed(changes))
+}
+
+func (t *InformerTests) TestStopsInformerWhenWatchIsDone() {
+	t.create(time.Hour)
+	t.discover()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- t.discovery.Watch(ctx, make(chan *TargetChanges)) }()
+	cancel()
+	t.NoError(<-done)
+	t.Eventually(t.discovery.informer.IsStopped, time.Second, 10*time.Millisecond)
+}
+
+func (t *InformerTests) TestRediscoversPodsOfReselectedNamespaces() {
+	t.config.namespaceSelector = "team=some-team"
+	otherPod := createPod("other-pod", "10.0.0.5")
+	otherPod.Namespace = "other-namespace"
+	t.client = fake.NewSimpleClientset(
+		createNamespace("some-namespace", map[string]string{"team": "some-team"}),
+		createNamespace("other-namespace", nil),
+		createPod("some-pod", "10.0.0.1"),
+		otherPod,
+	)
+	t.create(time.Hour)
+	t.Equal([]string{"10.0.0.1:8080"}, t.discover())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *TargetChanges, 1)
+	go t.discovery.Watch(ctx, changes)
+
+	// the unchanged pod of a namespace that becomes selected is discovered
+	_, err := t.client.CoreV1().Namespaces().Update(context.Background(), createNamespace("other-namespace", map[string]string{"team": "some-team"}), metav1.UpdateOptions{})
+	t.NoError(err)
+	t.Equal([]string{"10.0.0.5:8080"}, t.discover())
+
+	_, err = t.client.CoreV1().Namespaces().Update(context.Background(), createNamespace("some-namespace", nil), metav1.UpdateOptions{})
+	t.NoError(err)
+	t.Empty(t.discover())
+	t.Equal([]string{"10.0.0.1:8080"}, t.removed(changes))
+}
+
+func (t *InformerTests) TestMarksTargetsIncremental() {
+	t.create(time.Hour)
+	targets := make(chan *Target, 10)
+	t.NoError(t.discovery.Discover(context.Background(), targets))
+	close(targets)
+	for target := range targets {
+		t.True(target.Incremental)
+	}
+}
+
+func (t *InformerTests) TestComparatorKeepsMetricsOfUnchangedPods() {
+	t.create(time.Hour)
+	comparator := metrics.CreateMetricsComparator()
+	defer metrics.ExpiryValidationsCounter.DeletePartialMatch(prometheus.Labels{"source": "some-cluster"})
+
+	first := t.scan()
+	t.Len(first, 2)
+	for _, result := range first {
+		values := metrics.FilterLabelsValues(result.Target.Labels(), metrics.ExpiryLabelKeys...)
+		metrics.ExpiryValidationsCounter.WithLabelValues(values...).Inc()
+	}
+
+	// unchanged pods are not discovered again, so are missing from the next scan
+	second := t.scan()
+	t.Empty(second)
+	comparator.Compare(first, second)
+	t.Equal(2, testutil.CollectAndCount(metrics.ExpiryValidationsCounter))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan *TargetChanges, 1)
+	go t.discovery.Watch(ctx, changes)
+
+	t.NoError(t.client.CoreV1().Pods("some-namespace").Delete(context.Background(), "some-pod", metav1.DeleteOptions{}))
+	select {
+	case change := <-changes:
+		comparator.Remove(change.Removed)
+	case <-time.After(5 * time.Second):
+		t.FailNow("timed out waiting for removed targets")
+	}
+	t.Equal(1, testutil.CollectAndCount(metrics.ExpiryValidationsCounter))
+	t.Equal(1, testutil.CollectAndCount(metrics.ExpiryValidationsCounter.MustCurryWith(prometheus.Labels{"address": "10.0.0.2:8080"})))
+}
+
+func (t *InformerTests) create(resync time.Duration) {
+	discovery, err := CreateInformerPodDiscovery(t.config, resync, t.client.CoreV1().Pods(""), t.client.CoreV1().Namespaces(), nil)
+	t.NoError(err)
+	t.discovery = discovery
+}
+
+func (t *InformerTests) discover() []string {
+	targets := make(chan *Target, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	t.NoError(t.discovery.Discover(ctx, targets))
+	close(targets)
+
+	addresses := make([]string, 0)
+	for target := range targets {
+		addresses = append(addresses, target.Address.String())
+	}
+	return addresses
+}
+
+// scan discovers the targets of a scan, returning them as its results
+func (t *InformerTests) scan() completedScan {
+	targets := make(chan *Target, 10)
+	t.NoError(t.discovery.Discover(context.Background(), targets))
+	close(targets)
+
+	results := make(completedScan, 0)
+	for target := range targets {
+		results = append(results, NewTargetScanResult(target))
+	}
+	return results
+}
+
+func (t *InformerTests) removed(changes chan *TargetChanges) []string {
+	addresses := make([]string, 0)
+	select {
+	case change := <-changes:
+		for _, target := range change.Removed {
+			addresses = append(addresses, target.Address.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fail("timed out waiting for removed targets")
+	}
+	return addresses
+}
+
+// removedEventually discovers until targets are reported removed, as pods that change are only discovered again
+// once their watch event has been delivered
+func (t *InformerTests) removedEventually(changes chan *TargetChanges) []string {
+	var change *TargetChanges
+	t.Eventually(func() bool {
+		t.discover()
+		select {
+		case change = <-changes:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 20*time.Millisecond)
+
+	addresses := make([]string, 0)
+	if change != nil {
+		for _, target := range change.Removed {
+			addresses = append(addresses, target.Address.String())
+		}
+	}
+	return addresses
+}
+
+// discoverEventually discovers until the expected number of targets have been seen, as watch events are
+// delivered to the informer asynchronously
+func (t *InformerTests) discoverEventually(expected int) []string {
+	addresses := make([]string, 0)
+	t.Eventually(func() bool {
+		addresses = append(addresses, t.discover()...)
+		return len(addresses) >= expected
+	}, 5*time.Second, 20*time.Millisecond)
+	return addresses
+}
+
+type completedScan []*TargetScan
+
+func (c completedScan) Results() []*TargetScan {
+	return c
+}
+
+func (c completedScan) FailedSources() []string {
+	return nil
+}
+
+func createNamespace(name string, labels map[string]string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func createPod(name, ip string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "some-namespace",
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:  "somecontainer",
+					Ports: []v1.ContainerPort{createContainerPort(8080)},
+				},
+			},
+		},
+		Status: v1.PodStatus{
+			PodIP:      ip,
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func TestInformerSuite(t *testing.T) {
+	suite.Run(t, &InformerTests{})
+}
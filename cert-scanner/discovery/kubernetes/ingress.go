@@ -0,0 +1,145 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	typednetworkingv1 "k8s.io/client-go/kubernetes/typed/networking/v1"
+)
+
+const (
+	IngressName   = "ingress"
+	TLSSecretName = "tls_secret"
+)
+
+type IngressesInterface interface {
+	typednetworkingv1.IngressInterface
+}
+
+type IngressDiscovery struct {
+	ingresses IngressesInterface
+	gateways  dynamic.Interface
+	IngressDiscoveryConfig
+}
+
+type IngressDiscoveryConfig struct {
+	source     string
+	namespace  string
+	gatewayAPI bool
+}
+
+// CreateIngressDiscovery creates a discovery that finds the TLS hosts served by the ingress controllers in the
+// cluster. When gateways is not nil, Gateway API Gateways and their attached routes will be discovered too.
+func CreateIngressDiscovery(config IngressDiscoveryConfig, ingresses IngressesInterface, gateways dynamic.Interface) (*IngressDiscovery, error) {
+	slog.Info("creating k8s ingress discovery", "source", config.source, "namespace", config.namespace, "gatewayAPI", config.gatewayAPI)
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the cluster is required")
+	}
+	if ingresses == nil {
+		return nil, fmt.Errorf("no ingresses api has been provided")
+	}
+	if config.gatewayAPI && gateways == nil {
+		return nil, fmt.Errorf("no dynamic client has been provided for gateway api discovery")
+	}
+
+	return &IngressDiscovery{
+		IngressDiscoveryConfig: config,
+		ingresses:              ingresses,
+		gateways:               gateways,
+	}, nil
+}
+
+// Discover lists the Ingresses in the cluster and creates a [UrlAddress] target for each TLS host. The target
+// connects to the load balancer address of the ingress but uses the host for SNI and hostname validation. If
+// gateway api discovery is enabled the hostnames of Gateway listeners and routes are discovered in the same way.
+func (d *IngressDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	slog.Debug("starting ingress discovery", "source", d.source)
+	ingresses, err := d.ingresses.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error discovering ingresses: %v", err)
+	}
+
+	numTargets := 0
+	for x := range ingresses.Items {
+		numTargets += d.discoverIngress(&ingresses.Items[x], targets)
+	}
+
+	if d.gatewayAPI {
+		discovered, err := d.discoverGateways(ctx, targets)
+		if err != nil {
+			return err
+		}
+		numTargets += discovered
+	}
+	slog.Info("finished ingress discovery", "ingresses", len(ingresses.Items), "targets", numTargets)
+	return nil
+}
+
+func (d *IngressDiscovery) discoverIngress(ingress *networkingv1.Ingress, targets chan *Target) int {
+	dialAddress := ingressAddress(ingress.Status.LoadBalancer.Ingress)
+	if dialAddress == "" {
+		slog.Debug("ingress has no load balancer address", "namespace", ingress.Namespace, "ingress", ingress.Name)
+		return 0
+	}
+
+	numTargets := 0
+	for _, tls := range ingress.Spec.TLS {
+		for _, host := range tls.Hosts {
+			labels := Labels{
+				IngressName:   ingress.Name,
+				Namespace:     ingress.Namespace,
+				TLSSecretName: tls.SecretName,
+			}
+			if target := d.createTarget(ingress.Name, host, 443, dialAddress, labels); target != nil {
+				numTargets++
+				targets <- target
+			}
+		}
+	}
+	return numTargets
+}
+
+// createTarget builds a target that dials the given address but uses the host for SNI. Wildcard hosts cannot
+// be used for SNI so are skipped.
+func (d *IngressDiscovery) createTarget(name, host string, port int32, dialAddress string, labels Labels) *Target {
+	if host == "" || strings.HasPrefix(host, "*") {
+		slog.Debug("skipping wildcard or empty host", "name", name, "host", host)
+		return nil
+	}
+
+	address := CreateUrlAddressWithDialAddress(&url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%s:%d", host, port),
+	}, dialAddress)
+
+	slog.Debug("created target from ingress host", "name", name, "host", host, "port", port, "dial_address", dialAddress)
+	return &Target{
+		Address: address,
+		Metadata: Metadata{
+			Name:       host,
+			Source:     d.source,
+			SourceType: Kubernetes,
+			Labels:     labels,
+		},
+	}
+}
+
+func ingressAddress(ingresses []networkingv1.IngressLoadBalancerIngress) string {
+	for _, ingress := range ingresses {
+		if ingress.IP != "" {
+			return ingress.IP
+		}
+		if ingress.Hostname != "" {
+			return ingress.Hostname
+		}
+	}
+	return ""
+}
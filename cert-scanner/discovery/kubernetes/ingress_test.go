@@ -0,0 +1,215 @@
+//go:generate mockery --name IngressesInterface
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/discovery/kubernetes/mocks"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+type IngressTests struct {
+	config    IngressDiscoveryConfig
+	ingresses *mocks.IngressesInterface
+	list      *networkingv1.IngressList
+	objects   map[schema.GroupVersionResource][]*unstructured.Unstructured
+	err       error
+	suite.Suite
+}
+
+func (t *IngressTests) SetupTest() {
+	t.ingresses = &mocks.IngressesInterface{}
+	t.list = &networkingv1.IngressList{Items: make([]networkingv1.Ingress, 0)}
+	t.objects = make(map[schema.GroupVersionResource][]*unstructured.Unstructured)
+	t.err = nil
+	t.config = IngressDiscoveryConfig{
+		source: "some-cluster",
+	}
+}
+
+func (t *IngressTests) TestDiscoveryCreationErrors() {
+	_, err := CreateIngressDiscovery(IngressDiscoveryConfig{}, t.ingresses, nil)
+	t.ErrorContains(err, "a valid source label for the cluster is required")
+
+	_, err = CreateIngressDiscovery(t.config, nil, nil)
+	t.ErrorContains(err, "no ingresses api has been provided")
+
+	t.config.gatewayAPI = true
+	_, err = CreateIngressDiscovery(t.config, t.ingresses, nil)
+	t.ErrorContains(err, "no dynamic client has been provided for gateway api discovery")
+}
+
+func (t *IngressTests) TestDiscoversIngressTLSHosts() {
+	t.addIngress("some-ingress", "some-namespace", "10.0.0.1",
+		networkingv1.IngressTLS{Hosts: []string{"foo.example.com", "bar.example.com"}, SecretName: "some-secret"},
+	)
+
+	targets := t.discover(2)
+	t.Equal(2, len(targets))
+	t.Equal(&Target{
+		Address: createDialAddress("foo.example.com:443", "10.0.0.1"),
+		Metadata: Metadata{
+			Name:       "foo.example.com",
+			Source:     "some-cluster",
+			SourceType: "kubernetes",
+			Labels: map[string]string{
+				"ingress":          "some-ingress",
+				"target_namespace": "some-namespace",
+				"tls_secret":       "some-secret",
+			},
+		},
+	}, <-targets)
+	t.Equal("bar.example.com", (<-targets).Address.String())
+}
+
+func (t *IngressTests) TestIgnoresIngressesWithoutAddressesOrWildcardHosts() {
+	t.addIngress("some-ingress", "some-namespace", "",
+		networkingv1.IngressTLS{Hosts: []string{"foo.example.com"}, SecretName: "some-secret"},
+	)
+	t.addIngress("another-ingress", "some-namespace", "10.0.0.1",
+		networkingv1.IngressTLS{Hosts: []string{"*.example.com"}, SecretName: "some-secret"},
+	)
+
+	targets := t.discover(2)
+	t.Equal(0, len(targets))
+}
+
+func (t *IngressTests) TestIssueLoadingIngressesRaisesError() {
+	t.err = errors.New("something barfed loading")
+	discovery, err := CreateIngressDiscovery(t.config, t.build(), nil)
+	t.NoError(err)
+	err = discovery.Discover(context.Background(), make(chan *Target, 1))
+	t.ErrorContains(err, "error discovering ingresses: something barfed")
+}
+
+func (t *IngressTests) TestDiscoversGatewayListenersAndRoutes() {
+	t.config.gatewayAPI = true
+	t.addGateway("some-gateway", "some-namespace", "10.0.0.2",
+		map[string]interface{}{"name": "https", "port": int64(8443), "protocol": "HTTPS", "hostname": "*.example.com",
+			"tls": map[string]interface{}{"certificateRefs": []interface{}{map[string]interface{}{"name": "some-secret"}}}},
+		map[string]interface{}{"name": "http", "port": int64(80), "protocol": "HTTP", "hostname": "plain.example.com"},
+	)
+	t.addRoute(HTTPRoutesResource, "HTTPRoute", "some-route", "some-namespace", "some-gateway", "", "foo.example.com")
+	t.addRoute(TLSRoutesResource, "TLSRoute", "another-route", "another-namespace", "some-gateway", "", "ignored.example.com")
+
+	targets := t.discover(2)
+	t.Equal(1, len(targets))
+	t.Equal(&Target{
+		Address: createDialAddress("foo.example.com:8443", "10.0.0.2"),
+		Metadata: Metadata{
+			Name:       "foo.example.com",
+			Source:     "some-cluster",
+			SourceType: "kubernetes",
+			Labels: map[string]string{
+				"gateway":          "some-gateway",
+				"listener":         "https",
+				"route":            "some-route",
+				"target_namespace": "some-namespace",
+				"tls_secret":       "some-secret",
+			},
+		},
+	}, <-targets)
+}
+
+func (t *IngressTests) TestDiscoversGatewayListenerHostnames() {
+	t.config.gatewayAPI = true
+	t.addGateway("some-gateway", "some-namespace", "10.0.0.2",
+		map[string]interface{}{"name": "tls", "port": int64(443), "protocol": "TLS", "hostname": "tls.example.com"},
+		map[string]interface{}{"name": "https", "port": int64(443), "protocol": "HTTPS", "hostname": "https.example.com"},
+	)
+	t.addRoute(HTTPRoutesResource, "HTTPRoute", "some-route", "some-namespace", "some-gateway", "https", "other.example.com")
+
+	targets := t.discover(3)
+	t.Equal(3, len(targets))
+	hosts := []string{}
+	for x := 0; x < 3; x++ {
+		hosts = append(hosts, (<-targets).Address.String())
+	}
+	t.ElementsMatch([]string{"tls.example.com", "https.example.com", "other.example.com"}, hosts)
+}
+
+func (t *IngressTests) discover(buffer int) chan *Target {
+	gateways := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		GatewaysResource:   "GatewayList",
+		HTTPRoutesResource: "HTTPRouteList",
+		TLSRoutesResource:  "TLSRouteList",
+	})
+
+	// the fake tracker guesses the wrong plural for gateways if seeded directly, so create each via the client
+	for resource, objects := range t.objects {
+		for _, obj := range objects {
+			_, err := gateways.Resource(resource).Namespace(obj.GetNamespace()).Create(context.Background(), obj, metav1.CreateOptions{})
+			t.NoError(err)
+		}
+	}
+
+	discovery, err := CreateIngressDiscovery(t.config, t.build(), gateways)
+	t.NoError(err)
+	targets := make(chan *Target, buffer)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	return targets
+}
+
+func (t *IngressTests) build() *mocks.IngressesInterface {
+	t.ingresses.On("List", mock.Anything, mock.AnythingOfType("v1.ListOptions")).Return(t.list, t.err)
+	return t.ingresses
+}
+
+func (t *IngressTests) addIngress(name, namespace, address string, tls ...networkingv1.IngressTLS) {
+	ingress := networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       networkingv1.IngressSpec{TLS: tls},
+	}
+	if address != "" {
+		ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: address}}
+	}
+	t.list.Items = append(t.list.Items, ingress)
+}
+
+func (t *IngressTests) addGateway(name, namespace, address string, listeners ...interface{}) {
+	t.objects[GatewaysResource] = append(t.objects[GatewaysResource], &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec":       map[string]interface{}{"listeners": listeners},
+		"status": map[string]interface{}{
+			"addresses": []interface{}{map[string]interface{}{"type": "IPAddress", "value": address}},
+		},
+	}})
+}
+
+func (t *IngressTests) addRoute(resource schema.GroupVersionResource, kind, name, namespace, gateway, section string, hostnames ...string) {
+	hosts := make([]interface{}, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		hosts = append(hosts, hostname)
+	}
+	t.objects[resource] = append(t.objects[resource], &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": resource.GroupVersion().String(),
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{map[string]interface{}{"name": gateway, "sectionName": section}},
+			"hostnames":  hosts,
+		},
+	}})
+}
+
+func createDialAddress(host, dialAddress string) *UrlAddress {
+	return CreateUrlAddressWithDialAddress(&url.URL{Scheme: "https", Host: host}, dialAddress)
+}
+
+func TestIngressSuite(t *testing.T) {
+	suite.Run(t, &IngressTests{})
+}
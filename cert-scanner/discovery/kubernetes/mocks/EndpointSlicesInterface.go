@@ -0,0 +1,291 @@
+// Code generated by mockery v2.46.3. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mock "github.com/stretchr/testify/mock"
+
+	types "k8s.io/apimachinery/pkg/types"
+
+	v1 "k8s.io/client-go/applyconfigurations/discovery/v1"
+
+	watch "k8s.io/apimachinery/pkg/watch"
+)
+
+// EndpointSlicesInterface is an autogenerated mock type for the EndpointSlicesInterface type
+type EndpointSlicesInterface struct {
+	mock.Mock
+}
+
+// Apply provides a mock function with given fields: ctx, endpointSlice, opts
+func (_m *EndpointSlicesInterface) Apply(ctx context.Context, endpointSlice *v1.EndpointSliceApplyConfiguration, opts metav1.ApplyOptions) (*discoveryv1.EndpointSlice, error) {
+	ret := _m.Called(ctx, endpointSlice, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Apply")
+	}
+
+	var r0 *discoveryv1.EndpointSlice
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.EndpointSliceApplyConfiguration, metav1.ApplyOptions) (*discoveryv1.EndpointSlice, error)); ok {
+		return rf(ctx, endpointSlice, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *v1.EndpointSliceApplyConfiguration, metav1.ApplyOptions) *discoveryv1.EndpointSlice); ok {
+		r0 = rf(ctx, endpointSlice, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*discoveryv1.EndpointSlice)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *v1.EndpointSliceApplyConfiguration, metav1.ApplyOptions) error); ok {
+		r1 = rf(ctx, endpointSlice, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Create provides a mock function with given fields: ctx, endpointSlice, opts
+func (_m *EndpointSlicesInterface) Create(ctx context.Context, endpointSlice *discoveryv1.EndpointSlice, opts metav1.CreateOptions) (*discoveryv1.EndpointSlice, error) {
+	ret := _m.Called(ctx, endpointSlice, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 *discoveryv1.EndpointSlice
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *discoveryv1.EndpointSlice, metav1.CreateOptions) (*discoveryv1.EndpointSlice, error)); ok {
+		return rf(ctx, endpointSlice, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *discoveryv1.EndpointSlice, metav1.CreateOptions) *discoveryv1.EndpointSlice); ok {
+		r0 = rf(ctx, endpointSlice, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*discoveryv1.EndpointSlice)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *discoveryv1.EndpointSlice, metav1.CreateOptions) error); ok {
+		r1 = rf(ctx, endpointSlice, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: ctx, name, opts
+func (_m *EndpointSlicesInterface) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	ret := _m.Called(ctx, name, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, metav1.DeleteOptions) error); ok {
+		r0 = rf(ctx, name, opts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteCollection provides a mock function with given fields: ctx, opts, listOpts
+func (_m *EndpointSlicesInterface) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	ret := _m.Called(ctx, opts, listOpts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteCollection")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, metav1.DeleteOptions, metav1.ListOptions) error); ok {
+		r0 = rf(ctx, opts, listOpts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Get provides a mock function with given fields: ctx, name, opts
+func (_m *EndpointSlicesInterface) Get(ctx context.Context, name string, opts metav1.GetOptions) (*discoveryv1.EndpointSlice, error) {
+	ret := _m.Called(ctx, name, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *discoveryv1.EndpointSlice
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, metav1.GetOptions) (*discoveryv1.EndpointSlice, error)); ok {
+		return rf(ctx, name, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, metav1.GetOptions) *discoveryv1.EndpointSlice); ok {
+		r0 = rf(ctx, name, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*discoveryv1.EndpointSlice)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, metav1.GetOptions) error); ok {
+		r1 = rf(ctx, name, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, opts
+func (_m *EndpointSlicesInterface) List(ctx context.Context, opts metav1.ListOptions) (*discoveryv1.EndpointSliceList, error) {
+	ret := _m.Called(ctx, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *discoveryv1.EndpointSliceList
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, metav1.ListOptions) (*discoveryv1.EndpointSliceList, error)); ok {
+		return rf(ctx, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, metav1.ListOptions) *discoveryv1.EndpointSliceList); ok {
+		r0 = rf(ctx, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*discoveryv1.EndpointSliceList)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, metav1.ListOptions) error); ok {
+		r1 = rf(ctx, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Patch provides a mock function with given fields: ctx, name, pt, data, opts, subresources
+func (_m *EndpointSlicesInterface) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*discoveryv1.EndpointSlice, error) {
+	_va := make([]interface{}, len(subresources))
+	for _i := range subresources {
+		_va[_i] = subresources[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, name, pt, data, opts)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Patch")
+	}
+
+	var r0 *discoveryv1.EndpointSlice
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.PatchType, []byte, metav1.PatchOptions, ...string) (*discoveryv1.EndpointSlice, error)); ok {
+		return rf(ctx, name, pt, data, opts, subresources...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, types.PatchType, []byte, metav1.PatchOptions, ...string) *discoveryv1.EndpointSlice); ok {
+		r0 = rf(ctx, name, pt, data, opts, subresources...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*discoveryv1.EndpointSlice)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, types.PatchType, []byte, metav1.PatchOptions, ...string) error); ok {
+		r1 = rf(ctx, name, pt, data, opts, subresources...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, endpointSlice, opts
+func (_m *EndpointSlicesInterface) Update(ctx context.Context, endpointSlice *discoveryv1.EndpointSlice, opts metav1.UpdateOptions) (*discoveryv1.EndpointSlice, error) {
+	ret := _m.Called(ctx, endpointSlice, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 *discoveryv1.EndpointSlice
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *discoveryv1.EndpointSlice, metav1.UpdateOptions) (*discoveryv1.EndpointSlice, error)); ok {
+		return rf(ctx, endpointSlice, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *discoveryv1.EndpointSlice, metav1.UpdateOptions) *discoveryv1.EndpointSlice); ok {
+		r0 = rf(ctx, endpointSlice, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*discoveryv1.EndpointSlice)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *discoveryv1.EndpointSlice, metav1.UpdateOptions) error); ok {
+		r1 = rf(ctx, endpointSlice, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Watch provides a mock function with given fields: ctx, opts
+func (_m *EndpointSlicesInterface) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	ret := _m.Called(ctx, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Watch")
+	}
+
+	var r0 watch.Interface
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, metav1.ListOptions) (watch.Interface, error)); ok {
+		return rf(ctx, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, metav1.ListOptions) watch.Interface); ok {
+		r0 = rf(ctx, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(watch.Interface)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, metav1.ListOptions) error); ok {
+		r1 = rf(ctx, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewEndpointSlicesInterface creates a new instance of EndpointSlicesInterface. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewEndpointSlicesInterface(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *EndpointSlicesInterface {
+	mock := &EndpointSlicesInterface{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
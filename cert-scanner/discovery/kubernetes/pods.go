@@ -13,6 +13,8 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/util/jsonpath"
 )
@@ -23,6 +25,9 @@ const (
 	Namespace         = "target_namespace"
 	PodName           = "target_pod"
 	Container         = "container"
+	IPFamily          = "ip_family"
+	IPv4              = "ipv4"
+	IPv6              = "ipv6"
 	ScannerPodEnvName = "CERT_SCANNER_POD_NAME"
 )
 
@@ -30,8 +35,14 @@ type PodsInterface interface {
 	typedcorev1.PodInterface
 }
 
+type NamespacesInterface interface {
+	typedcorev1.NamespaceInterface
+}
+
 type PodDiscovery struct {
 	pods             PodsInterface
+	namespaces       NamespacesInterface
+	workloads        *WorkloadResolver
 	ignorePatterns   []parsedIgnorePattern
 	ignoreContainers []parsedIgnorePattern
 	PodDiscoveryConfig
@@ -53,20 +64,45 @@ type PodDiscoveryConfig struct {
 	labelKeys        []string
 	ignorePatterns   []IgnorePattern
 	ignoreContainers []IgnorePattern
-	matchCIDR        *net.IPNet
+	matchCIDRs       []*net.IPNet
 	namespace        string
+
+	// selectors are applied by the api server when listing
+	labelSelector     string
+	fieldSelector     string
+	namespaceSelector string
+	pageSize          int64
 }
 
 // Creates a new Pod discovery instance to discover scan candidates via the k8s cluster with the given source
 // label
 func CreatePodDiscovery(config PodDiscoveryConfig, pods PodsInterface) (*PodDiscovery, error) {
-	slog.Info("creating k8s discovery", "source", config.source, "namespace", config.namespace, "keys", strings.Join(config.labelKeys, ","), "matchCIDR", config.matchCIDR.String())
+	return CreatePodDiscoveryWithNamespaces(config, pods, nil)
+}
+
+// CreatePodDiscoveryWithNamespaces creates a Pod discovery that can use the given namespaces api to restrict
+// discovery to the namespaces matching the configured namespace selector.
+func CreatePodDiscoveryWithNamespaces(config PodDiscoveryConfig, pods PodsInterface, namespaces NamespacesInterface) (*PodDiscovery, error) {
+	return CreatePodDiscoveryWithWorkloads(config, pods, namespaces, nil)
+}
+
+// CreatePodDiscoveryWithWorkloads creates a Pod discovery that labels each target with the workload that owns
+// its pod, as resolved by the given resolver.
+func CreatePodDiscoveryWithWorkloads(config PodDiscoveryConfig, pods PodsInterface, namespaces NamespacesInterface, workloads *WorkloadResolver) (*PodDiscovery, error) {
+	slog.Info("creating k8s discovery", "source", config.source, "namespace", config.namespace, "keys", strings.Join(config.labelKeys, ","), "matchCIDRs", formatCIDRs(config.matchCIDRs),
+		"labelSelector", config.labelSelector, "fieldSelector", config.fieldSelector, "namespaceSelector", config.namespaceSelector)
 	if config.source == "" {
 		return nil, fmt.Errorf("a valid source label for the cluster is required")
 	}
 	if pods == nil {
 		return nil, fmt.Errorf("no pods api has been provided")
 	}
+	if config.namespaceSelector != "" && namespaces == nil {
+		return nil, fmt.Errorf("no namespaces api has been provided for the namespace selector")
+	}
+	if err := validateSelectors(config); err != nil {
+		return nil, err
+	}
 
 	config.ignorePatterns = append(config.ignorePatterns, IgnorePattern{Pattern: "{.metadata.name}", Match: []string{"cert-scanner"}})
 	ignorePodPatterns, err := parseIgnorePatterns(config.ignorePatterns)
@@ -82,86 +118,315 @@ func CreatePodDiscovery(config PodDiscoveryConfig, pods PodsInterface) (*PodDisc
 	return &PodDiscovery{
 		PodDiscoveryConfig: config,
 		pods:               pods,
+		namespaces:         namespaces,
+		workloads:          workloads,
 		ignorePatterns:     ignorePodPatterns,
 		ignoreContainers:   ignoreContainerPatterns,
 	}, nil
 }
 
-// Discover lists all kubernetes pods through the pods api and creates candidate [Target]s for scanning.
-// Each target is emitted onto the given channel for processing. Returns an error if the pods cannot be
-// be retrieved or parsed from the api
+// Discover lists kubernetes pods through the pods api and creates candidate [Target]s for scanning. The
+// configured label and field selectors are applied by the api server and pods are listed in pages. When a
+// namespace selector is configured, only the pods in matching namespaces are listed. Each target is emitted
+// onto the given channel for processing. Returns an error if the pods cannot be be retrieved or parsed from the api
 func (d *PodDiscovery) Discover(ctx context.Context, targets chan *Target) error {
 	slog.Debug("starting pod discovery", "source", d.source)
-	pods, err := d.pods.List(ctx, metav1.ListOptions{})
+	namespaces, err := d.selectNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.resetWorkloads()
+	numPods, numTargets := 0, 0
+	nodeAddresses := make(map[netip.AddrPort]bool)
+	discover := func(pod *v1.Pod) {
+		numPods++
+		for _, target := range d.discoverPod(ctx, pod, nodeAddresses) {
+			numTargets++
+			targets <- target
+		}
+	}
+
+	if namespaces == nil {
+		err = d.listPods(ctx, d.listOptions(""), discover)
+	}
+	for _, namespace := range namespaces {
+		if err = d.listPods(ctx, d.listOptions(namespace), discover); err != nil {
+			break
+		}
+	}
 	if err != nil {
 		slog.Debug("error retrieving pods", "source", d.source)
 		return fmt.Errorf("error discovering pods: %v", err)
 	}
-	slog.Debug("retrieved pods from api", "source", d.source, "pods", len(pods.Items))
-	numTargets := 0
-	for _, pod := range pods.Items {
-		ignored, err := d.ignorePod(&pod)
+	slog.Info("finished pod discovery", "pods", numPods, "targets", numTargets)
+	return nil
+}
+
+func (d *PodDiscovery) resetWorkloads() {
+	if d.workloads != nil {
+		d.workloads.Reset()
+	}
+}
+
+// listPods lists the pods matching the given options one page at a time, passing each to the discover func
+func (d *PodDiscovery) listPods(ctx context.Context, options metav1.ListOptions, discover func(*v1.Pod)) error {
+	for {
+		pods, err := d.pods.List(ctx, options)
 		if err != nil {
-			slog.Error("error ignoring pod", "namespace", pod.Namespace, "pod", pod.Name, "error", err.Error())
-			continue
+			return err
 		}
-		if ignored || !isPodReady(&pod) {
-			continue
+		slog.Debug("retrieved pods from api", "source", d.source, "pods", len(pods.Items))
+		for x := range pods.Items {
+			discover(&pods.Items[x])
 		}
-		podIP := pod.Status.PodIP
-		ip, err := netip.ParseAddr(podIP)
+		if pods.Continue == "" {
+			return nil
+		}
+		options.Continue = pods.Continue
+	}
+}
+
+// listOptions creates the options to list pods with the configured selectors, restricted to the given
+// namespace if it is not empty
+func (d *PodDiscovery) listOptions(namespace string) metav1.ListOptions {
+	fieldSelector := d.fieldSelector
+	if namespace != "" {
+		namespaceSelector := fields.OneTermEqualSelector("metadata.namespace", namespace).String()
+		if fieldSelector == "" {
+			fieldSelector = namespaceSelector
+		} else {
+			fieldSelector = fmt.Sprintf("%s,%s", namespaceSelector, fieldSelector)
+		}
+	}
+	return metav1.ListOptions{
+		LabelSelector: d.labelSelector,
+		FieldSelector: fieldSelector,
+		Limit:         d.pageSize,
+	}
+}
+
+// selectNamespaces lists the names of the namespaces matching the namespace selector. Returns nil if no
+// namespace selector has been configured, meaning pods in all namespaces should be listed.
+func (d *PodDiscovery) selectNamespaces(ctx context.Context) ([]string, error) {
+	if d.namespaceSelector == "" {
+		return nil, nil
+	}
+
+	namespaces, err := d.namespaces.List(ctx, metav1.ListOptions{LabelSelector: d.namespaceSelector})
+	if err != nil {
+		return nil, fmt.Errorf("error discovering namespaces: %v", err)
+	}
+	selected := make([]string, 0, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		// a namespaced pods api can only list pods in its own namespace
+		if d.namespace == "" || d.namespace == namespace.Name {
+			selected = append(selected, namespace.Name)
+		}
+	}
+	slog.Debug("selected namespaces", "source", d.source, "selector", d.namespaceSelector, "namespaces", len(selected))
+	return selected, nil
+}
+
+func validateSelectors(config PodDiscoveryConfig) error {
+	if _, err := labels.Parse(config.labelSelector); err != nil {
+		return fmt.Errorf("error parsing label selector: %v", err)
+	}
+	if _, err := fields.ParseSelector(config.fieldSelector); err != nil {
+		return fmt.Errorf("error parsing field selector: %v", err)
+	}
+	if _, err := labels.Parse(config.namespaceSelector); err != nil {
+		return fmt.Errorf("error parsing namespace selector: %v", err)
+	}
+	return nil
+}
+
+// discoverPod creates a [Target] for each TCP port of each container on every ip of a ready pod, returning the
+// targets created. Ports bound on the node, via hostNetwork or a hostPort, are scanned on the node's
+// ips instead and only once per node address, tracked across pods in the given set. Ignored pods and containers
+// are skipped, and the pod's scan control annotations are applied.
+func (d *PodDiscovery) discoverPod(ctx context.Context, pod *v1.Pod, nodeAddresses map[netip.AddrPort]bool) []*Target {
+	ignored, err := d.ignorePod(pod)
+	if err != nil {
+		slog.Error("error ignoring pod", "namespace", pod.Namespace, "pod", pod.Name, "error", err.Error())
+		return nil
+	}
+	if ignored || !isPodReady(pod) {
+		return nil
+	}
+	annotations, err := parseAnnotations(pod)
+	if err != nil {
+		slog.Error("error parsing pod annotations", "namespace", pod.Namespace, "pod", pod.Name, "error", err.Error())
+		return nil
+	}
+	if annotations.ignore {
+		slog.Debug("pod is annotated to be ignored", "namespace", pod.Namespace, "pod", pod.Name)
+		return nil
+	}
+
+	var owner *workload
+	if d.workloads != nil {
+		owner = d.workloads.Resolve(ctx, pod)
+	}
+
+	podIPs := d.matchingIPs(pod, podIPs(pod))
+	nodeIPs := d.matchingIPs(pod, nodeIPs(pod))
+
+	targets := make([]*Target, 0)
+	for _, container := range pod.Spec.Containers {
+		ignored, err := d.ignoreContainer(pod)
 		if err != nil {
-			slog.Error("error parsing pod ip", "namespace", pod.Namespace, "pod", pod.Name, "ip", podIP, "error", err.Error())
+			slog.Error("error ignoring container", "namespace", pod.Namespace, "pod", pod.Name, "container", container.Name, "error", err.Error())
 			continue
 		}
-
-		if d.matchCIDR != nil && !d.matchCIDR.Contains(net.ParseIP(podIP)) {
-			slog.Debug("pod does not match match cidr", "namespace", pod.Namespace, "pod", pod.Name, "ip", podIP, "matchCIDR", d.matchCIDR.String())
+		if ignored {
 			continue
 		}
 
-		for _, container := range pod.Spec.Containers {
-			ignored, err := d.ignoreContainer(&pod)
-			if err != nil {
-				slog.Error("error ignoring container", "namespace", pod.Namespace, "pod", pod.Name, "container", container.Name, "error", err.Error())
-				continue
-			}
-			if ignored {
+		for _, port := range container.Ports {
+			if port.Protocol != v1.ProtocolTCP || !annotations.scanPort(port) {
 				continue
 			}
 
-			for _, port := range container.Ports {
-				labels := Labels{
-					PortName:  port.Name,
-					Namespace: pod.ObjectMeta.Namespace,
-					PodName:   pod.ObjectMeta.Name,
-					Container: container.Name,
+			ips, portNumber := podIPs, port.ContainerPort
+			onNode := pod.Spec.HostNetwork || port.HostPort != 0
+			if onNode {
+				ips = nodeIPs
+				if port.HostPort != 0 {
+					portNumber = port.HostPort
 				}
+			}
 
-				for _, key := range d.labelKeys {
-					if label, ok := pod.ObjectMeta.Labels[key]; ok {
-						labels[key] = label
+			for _, ip := range ips {
+				address := netip.AddrPortFrom(ip, uint16(portNumber))
+				if onNode {
+					if nodeAddresses[address] {
+						slog.Debug("node address already discovered", "namespace", pod.Namespace, "pod", pod.Name, "address", address.String())
+						continue
 					}
+					nodeAddresses[address] = true
 				}
 
-				if port.Protocol == v1.ProtocolTCP {
-					numTargets++
-					targets <- &Target{
-						Address: CreateNetIPAddress(netip.AddrPortFrom(ip, uint16(port.ContainerPort))),
-						Metadata: Metadata{
-							Name:       pod.ObjectMeta.Name,
-							Source:     d.source,
-							SourceType: Kubernetes,
-							Labels:     labels,
-						},
-					}
-					slog.Debug("created target from pod", "namespace", pod.Namespace, "pod", pod.Name, "ip", podIP, "port", port.ContainerPort)
-				}
+				targets = append(targets, &Target{
+					Address: CreateNetIPAddressWithServerName(address, annotations.serverName),
+					Metadata: Metadata{
+						Name:        pod.ObjectMeta.Name,
+						Source:      d.source,
+						SourceType:  Kubernetes,
+						Labels:      d.podLabels(pod, container, port, ip, owner, annotations),
+						ServerNames: annotations.sni,
+						Network:     d.source,
+					},
+				})
+				slog.Debug("created target from pod", "namespace", pod.Namespace, "pod", pod.Name, "address", address.String())
 			}
 		}
 	}
-	slog.Info("finished pod discovery", "pods", len(pods.Items), "targets", numTargets)
-	return nil
+	return targets
+}
+
+func (d *PodDiscovery) podLabels(pod *v1.Pod, container v1.Container, port v1.ContainerPort, ip netip.Addr, owner *workload, annotations *podAnnotations) Labels {
+	labels := Labels{
+		PortName:  port.Name,
+		Namespace: pod.ObjectMeta.Namespace,
+		PodName:   pod.ObjectMeta.Name,
+		Container: container.Name,
+		IPFamily:  ipFamily(ip),
+	}
+
+	if owner != nil {
+		labels[WorkloadKind] = owner.kind
+		labels[WorkloadName] = owner.name
+	}
+
+	for _, key := range d.labelKeys {
+		if label, ok := pod.ObjectMeta.Labels[key]; ok {
+			labels[key] = label
+		}
+	}
+	if owner != nil {
+		for key, label := range owner.labels {
+			if _, ok := labels[key]; !ok {
+				labels[key] = label
+			}
+		}
+	}
+	// annotated labels cannot replace those set by discovery
+	for key, label := range annotations.labels {
+		if _, ok := labels[key]; !ok {
+			labels[key] = label
+		}
+	}
+	return labels
+}
+
+// matchingIPs parses the given ips, dropping any that are invalid or do not match the configured match cidrs
+func (d *PodDiscovery) matchingIPs(pod *v1.Pod, ips []string) []netip.Addr {
+	matching := make([]netip.Addr, 0, len(ips))
+	for _, podIP := range ips {
+		ip, err := netip.ParseAddr(podIP)
+		if err != nil {
+			slog.Error("error parsing pod ip", "namespace", pod.Namespace, "pod", pod.Name, "ip", podIP, "error", err.Error())
+			continue
+		}
+		if !d.matchesCIDRs(ip) {
+			slog.Debug("pod ip does not match match cidr", "namespace", pod.Namespace, "pod", pod.Name, "ip", podIP)
+			continue
+		}
+		matching = append(matching, ip)
+	}
+	return matching
+}
+
+func (d *PodDiscovery) matchesCIDRs(ip netip.Addr) bool {
+	if len(d.matchCIDRs) == 0 {
+		return true
+	}
+	for _, cidr := range d.matchCIDRs {
+		if cidr.Contains(net.IP(ip.AsSlice())) {
+			return true
+		}
+	}
+	return false
+}
+
+// podIPs returns the ip of each family assigned to the pod, falling back to the primary ip for clusters
+// that do not report them all
+func podIPs(pod *v1.Pod) []string {
+	if len(pod.Status.PodIPs) == 0 {
+		return nonEmpty(pod.Status.PodIP)
+	}
+	ips := make([]string, 0, len(pod.Status.PodIPs))
+	for _, ip := range pod.Status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+	return ips
+}
+
+// nodeIPs returns the ips of the node the pod is running on
+func nodeIPs(pod *v1.Pod) []string {
+	if len(pod.Status.HostIPs) == 0 {
+		return nonEmpty(pod.Status.HostIP)
+	}
+	ips := make([]string, 0, len(pod.Status.HostIPs))
+	for _, ip := range pod.Status.HostIPs {
+		ips = append(ips, ip.IP)
+	}
+	return ips
+}
+
+func nonEmpty(ip string) []string {
+	if ip == "" {
+		return nil
+	}
+	return []string{ip}
+}
+
+func ipFamily(ip netip.Addr) string {
+	if ip.Is4() || ip.Is4In6() {
+		return IPv4
+	}
+	return IPv6
 }
 
 func isPodReady(pod *v1.Pod) bool {
@@ -197,11 +462,11 @@ func (d *PodDiscovery) ignoreContainer(pod *v1.Pod) (bool, error) {
 	return ignore(pod, d.ignoreContainers)
 }
 
-func ignore(pod *v1.Pod, patterns []parsedIgnorePattern) (bool, error) {
+func ignore(obj metav1.Object, patterns []parsedIgnorePattern) (bool, error) {
 	for _, pattern := range patterns {
-		results, err := pattern.jsonPath.FindResults(pod)
+		results, err := pattern.jsonPath.FindResults(obj)
 		if err != nil {
-			return false, fmt.Errorf("error matching jsonpath pattern to pod (%s): %v", pod.Name, err)
+			return false, fmt.Errorf("error matching jsonpath pattern to %s: %v", obj.GetName(), err)
 		}
 
 		for _, result := range results {
@@ -219,7 +484,7 @@ func ignore(pod *v1.Pod, patterns []parsedIgnorePattern) (bool, error) {
 				// Check if extracted value matches any of the specified match values
 				for _, matchValue := range pattern.matches {
 					if matchValue.MatchString(extractedValue) {
-						slog.Debug("ignoring due to pattern match", "pod", pod.Name, "pattern", pattern.pattern, "value", extractedValue)
+						slog.Debug("ignoring due to pattern match", "name", obj.GetName(), "pattern", pattern.pattern, "value", extractedValue)
 						return true, nil
 					}
 				}
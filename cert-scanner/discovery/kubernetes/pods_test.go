@@ -1,12 +1,15 @@
 //go:generate mockery --name PodsInterface
+//go:generate mockery --name NamespacesInterface
 package kubernetes
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/netip"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/sgargan/cert-scanner-darkly/discovery/kubernetes/mocks"
@@ -33,10 +36,10 @@ func (t *PodTests) SetupSuite() {
 func (t *PodTests) SetupTest() {
 	t.MockPods = NewMockPods()
 	t.config = PodDiscoveryConfig{
-		source:    "some-cluster",
-		labelKeys: []string{"foo", "app"},
-		matchCIDR: nil,
-		namespace: "",
+		source:     "some-cluster",
+		labelKeys:  []string{"foo", "app"},
+		matchCIDRs: nil,
+		namespace:  "",
 	}
 }
 
@@ -45,7 +48,7 @@ func (t *PodTests) TestDiscoveryCreationErrors() {
 		source:         "",
 		labelKeys:      []string{},
 		ignorePatterns: []IgnorePattern{},
-		matchCIDR:      nil,
+		matchCIDRs:     nil,
 		namespace:      "",
 	}
 
@@ -55,6 +58,25 @@ func (t *PodTests) TestDiscoveryCreationErrors() {
 	config.source = "somecluster"
 	_, err = CreatePodDiscovery(config, nil)
 	t.ErrorContains(err, "no pods api has been provided")
+
+	config.namespaceSelector = "team=some-team"
+	_, err = CreatePodDiscovery(config, t.Build())
+	t.ErrorContains(err, "no namespaces api has been provided for the namespace selector")
+
+	config.namespaceSelector = ""
+	config.labelSelector = "app in (some-app"
+	_, err = CreatePodDiscovery(config, t.Build())
+	t.ErrorContains(err, "error parsing label selector")
+
+	config.labelSelector = ""
+	config.fieldSelector = "spec.nodeName"
+	_, err = CreatePodDiscovery(config, t.Build())
+	t.ErrorContains(err, "error parsing field selector")
+
+	config.fieldSelector = ""
+	config.namespaceSelector = "team in (some-team"
+	_, err = CreatePodDiscoveryWithNamespaces(config, t.Build(), &mocks.NamespacesInterface{})
+	t.ErrorContains(err, "error parsing namespace selector")
 }
 
 func (t *PodTests) TestDiscoversValidPods() {
@@ -76,12 +98,14 @@ func (t *PodTests) TestDiscoversValidPods() {
 			Name:       "some-pod",
 			Source:     "some-cluster",
 			SourceType: "kubernetes",
+			Network:    "some-cluster",
 			Labels: map[string]string{
 				"foo":              "bar",
 				"port_name":        "some-port",
 				"container":        "somecontainer",
 				"target_pod":       "some-pod",
 				"target_namespace": "some-namespace",
+				"ip_family":        "ipv4",
 			},
 		},
 		Address: getAddress("10.0.1.1:8080"),
@@ -92,12 +116,14 @@ func (t *PodTests) TestDiscoversValidPods() {
 			Name:       "another-pod",
 			Source:     "some-cluster",
 			SourceType: "kubernetes",
+			Network:    "some-cluster",
 			Labels: map[string]string{
 				"app":              "some-app",
 				"port_name":        "another-port",
 				"container":        "somecontainer",
 				"target_pod":       "another-pod",
 				"target_namespace": "another-namespace",
+				"ip_family":        "ipv4",
 			},
 		},
 		Address: getAddress("10.0.1.2:8081"),
@@ -118,6 +144,7 @@ func (t *PodTests) TestExtractsLabels() {
 		"address":          "10.0.1.1:8080",
 		"app":              "some-app",
 		"foo":              "bar",
+		"ip_family":        "ipv4",
 		"target_namespace": "some-namespace",
 		"target_pod":       "some-pod",
 		"container":        "somecontainer",
@@ -161,7 +188,7 @@ func (t *PodTests) TestIgnoresPodsByMatchCIDR() {
 	)
 
 	_, matchCIDR, _ := net.ParseCIDR("10.1.0.0/16")
-	t.config.matchCIDR = matchCIDR
+	t.config.matchCIDRs = []*net.IPNet{matchCIDR}
 
 	// should only match one of the two pods
 	podDiscovery, _ := CreatePodDiscovery(t.config, t.Build())
@@ -232,6 +259,287 @@ func (t *PodTests) TestIgnoresScanner() {
 	t.Equal(0, len(targets))
 }
 
+func (t *PodTests) TestDiscoversEachIPFamily() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Status.PodIPs = []v1.PodIP{{IP: "10.0.1.1"}, {IP: "fd00::1"}}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	families := t.discoverFamilies(podDiscovery)
+	t.Equal(map[string]string{"10.0.1.1:8080": IPv4, "[fd00::1]:8080": IPv6}, families)
+}
+
+func (t *PodTests) TestMatchesCIDRsOfEachFamily() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Status.PodIPs = []v1.PodIP{{IP: "10.0.1.1"}, {IP: "fd00::1"}}
+	t.AddPods("another-pod", "some-namespace", nil, v1.PodIP{IP: "10.1.1.1"}, createContainerPort(8080))
+	t.list.Items[1].Status.PodIPs = []v1.PodIP{{IP: "10.1.1.1"}, {IP: "fd01::1"}}
+
+	cidrs, err := parseCIDRs([]string{"10.0.0.0/16", "fd01::/64"})
+	t.NoError(err)
+	t.config.matchCIDRs = cidrs
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	families := t.discoverFamilies(podDiscovery)
+	t.Equal(map[string]string{"10.0.1.1:8080": IPv4, "[fd01::1]:8080": IPv6}, families)
+}
+
+func (t *PodTests) TestDiscoversHostNetworkPodsOncePerNode() {
+	for _, name := range []string{"some-pod", "another-pod", "third-pod"} {
+		t.AddPods(name, "some-namespace", nil, v1.PodIP{IP: "192.168.0.1"}, createContainerPort(10250))
+		pod := &t.list.Items[len(t.list.Items)-1]
+		pod.Spec.HostNetwork = true
+		pod.Status.HostIPs = []v1.HostIP{{IP: "192.168.0.1"}, {IP: "fd02::1"}}
+	}
+	t.list.Items[2].Status.PodIPs = []v1.PodIP{{IP: "192.168.0.2"}}
+	t.list.Items[2].Status.HostIPs = []v1.HostIP{{IP: "192.168.0.2"}}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	families := t.discoverFamilies(podDiscovery)
+	t.Equal(map[string]string{"192.168.0.1:10250": IPv4, "[fd02::1]:10250": IPv6, "192.168.0.2:10250": IPv4}, families)
+}
+
+func (t *PodTests) TestDiscoversHostPortsOnNode() {
+	port := v1.ContainerPort{Name: "some-port", ContainerPort: 8443, HostPort: 443, Protocol: v1.ProtocolTCP}
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, port)
+	t.AddPods("another-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.2"}, port)
+	t.list.Items[0].Status.HostIP = "192.168.0.1"
+	t.list.Items[1].Status.HostIP = "192.168.0.1"
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	families := t.discoverFamilies(podDiscovery)
+	t.Equal(map[string]string{"192.168.0.1:443": IPv4}, families)
+}
+
+func (t *PodTests) discoverFamilies(podDiscovery *PodDiscovery) map[string]string {
+	targets := make(chan *Target, 10)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	close(targets)
+
+	families := make(map[string]string)
+	for target := range targets {
+		families[target.Address.String()] = target.Metadata.Labels[IPFamily]
+	}
+	return families
+}
+
+func (t *PodTests) TestIgnoresAnnotatedPods() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.AddPods("another-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.2"}, createContainerPort(8080))
+	t.list.Items[0].Annotations = map[string]string{IgnoreAnnotation: "true"}
+	t.list.Items[1].Annotations = map[string]string{IgnoreAnnotation: "false"}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+	t.Equal([]string{"another-pod"}, t.discoverNames(podDiscovery))
+}
+
+func (t *PodTests) TestScansAnnotatedPorts() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Spec.Containers[0].Ports = []v1.ContainerPort{
+		{Name: "https", ContainerPort: 8443, Protocol: v1.ProtocolTCP},
+		{Name: "grpc", ContainerPort: 9443, Protocol: v1.ProtocolTCP},
+		{Name: "metrics", ContainerPort: 9090, Protocol: v1.ProtocolTCP},
+	}
+	t.list.Items[0].Annotations = map[string]string{PortsAnnotation: "https, 9443"}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	targets := make(chan *Target, 3)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	close(targets)
+	addresses := make([]string, 0)
+	for target := range targets {
+		addresses = append(addresses, target.Address.String())
+	}
+	t.Equal([]string{"10.0.1.1:8443", "10.0.1.1:9443"}, addresses)
+}
+
+func (t *PodTests) TestAnnotatedServerNameValidatesHostname() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Annotations = map[string]string{ServerNameAnnotation: "some-service.some-namespace.svc"}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	targets := make(chan *Target, 1)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	target := <-targets
+	t.Equal("10.0.1.1:8080", target.Address.String())
+	t.True(target.Address.ValidateHostname())
+	t.Equal("some-service.some-namespace.svc", target.Address.ServerName())
+}
+
+func (t *PodTests) TestAnnotatedSNINamesAreProbed() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Annotations = map[string]string{SNIAnnotation: "www.example.com, api.example.com"}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	targets := make(chan *Target, 1)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	target := <-targets
+	t.False(target.Address.ValidateHostname())
+	t.Equal([]string{"www.example.com", "api.example.com"}, target.ServerNames)
+}
+
+func (t *PodTests) TestLimitsAnnotatedSNINames() {
+	names := make([]string, 0, MaxSNINames+2)
+	for i := 0; i < MaxSNINames+2; i++ {
+		names = append(names, fmt.Sprintf("host-%d.example.com", i))
+	}
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Annotations = map[string]string{SNIAnnotation: strings.Join(names, ",")}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	targets := make(chan *Target, 1)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	t.Equal(names[:MaxSNINames], (<-targets).ServerNames)
+}
+
+func (t *PodTests) TestAddsAnnotatedLabels() {
+	t.AddPods("some-pod", "some-namespace", map[string]string{"app": "some-app"}, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Annotations = map[string]string{LabelsAnnotation: "team=some-team, app=another-app, target_pod=another-pod"}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	targets := make(chan *Target, 1)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	labels := (<-targets).Metadata.Labels
+	t.Equal("some-team", labels["team"])
+	t.Equal("some-app", labels["app"])
+	t.Equal("some-pod", labels[PodName])
+}
+
+func (t *PodTests) TestDropsReservedAnnotatedLabels() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.list.Items[0].Annotations = map[string]string{LabelsAnnotation: "team=some-team, source=another-source, source_type=file, address=10.0.1.2:443, sni=www.example.com"}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+
+	targets := make(chan *Target, 1)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	target := <-targets
+	labels := target.Labels()
+	t.Equal("some-team", labels["team"])
+	t.Equal(target.Source, labels["source"])
+	t.Equal("kubernetes", labels["source_type"])
+	t.Equal("10.0.1.1:8080", labels["address"])
+	t.NotContains(target.Metadata.Labels, "sni")
+}
+
+func (t *PodTests) TestIgnoresPodsWithInvalidAnnotations() {
+	t.AddPods("some-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	t.AddPods("another-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.2"}, createContainerPort(8080))
+	t.AddPods("third-pod", "some-namespace", nil, v1.PodIP{IP: "10.0.1.3"}, createContainerPort(8080))
+	t.list.Items[0].Annotations = map[string]string{IgnoreAnnotation: "maybe"}
+	t.list.Items[1].Annotations = map[string]string{LabelsAnnotation: "team"}
+
+	podDiscovery, err := CreatePodDiscovery(t.config, t.Build())
+	t.NoError(err)
+	t.Equal([]string{"third-pod"}, t.discoverNames(podDiscovery))
+}
+
+func (t *PodTests) TestListsPodsInPages() {
+	t.config.pageSize = 1
+	pods := &mocks.PodsInterface{}
+	pods.On("List", mock.Anything, metav1.ListOptions{Limit: 1}).Return(
+		t.podList("some-continue", t.createPod("some-pod", "10.0.1.1")), nil).Once()
+	pods.On("List", mock.Anything, metav1.ListOptions{Limit: 1, Continue: "some-continue"}).Return(
+		t.podList("", t.createPod("another-pod", "10.0.1.2")), nil).Once()
+
+	podDiscovery, err := CreatePodDiscovery(t.config, pods)
+	t.NoError(err)
+
+	t.ElementsMatch([]string{"some-pod", "another-pod"}, t.discoverNames(podDiscovery))
+	pods.AssertExpectations(t.T())
+}
+
+func (t *PodTests) TestPassesSelectorsToApi() {
+	t.config.labelSelector = "app=some-app"
+	t.config.fieldSelector = "spec.nodeName=some-node"
+	pods := &mocks.PodsInterface{}
+	pods.On("List", mock.Anything, metav1.ListOptions{LabelSelector: "app=some-app", FieldSelector: "spec.nodeName=some-node"}).Return(
+		t.podList("", t.createPod("some-pod", "10.0.1.1")), nil).Once()
+
+	podDiscovery, err := CreatePodDiscovery(t.config, pods)
+	t.NoError(err)
+
+	t.Equal([]string{"some-pod"}, t.discoverNames(podDiscovery))
+	pods.AssertExpectations(t.T())
+}
+
+func (t *PodTests) TestListsPodsInSelectedNamespaces() {
+	t.config.namespaceSelector = "team=some-team"
+	t.config.fieldSelector = "spec.nodeName=some-node"
+	namespaces := &mocks.NamespacesInterface{}
+	namespaces.On("List", mock.Anything, metav1.ListOptions{LabelSelector: "team=some-team"}).Return(&v1.NamespaceList{
+		Items: []v1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "some-namespace"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "another-namespace"}},
+		},
+	}, nil)
+	pods := &mocks.PodsInterface{}
+	pods.On("List", mock.Anything, metav1.ListOptions{FieldSelector: "metadata.namespace=some-namespace,spec.nodeName=some-node"}).Return(
+		t.podList("", t.createPod("some-pod", "10.0.1.1")), nil).Once()
+	pods.On("List", mock.Anything, metav1.ListOptions{FieldSelector: "metadata.namespace=another-namespace,spec.nodeName=some-node"}).Return(
+		t.podList("", t.createPod("another-pod", "10.0.1.2")), nil).Once()
+
+	podDiscovery, err := CreatePodDiscoveryWithNamespaces(t.config, pods, namespaces)
+	t.NoError(err)
+
+	t.ElementsMatch([]string{"some-pod", "another-pod"}, t.discoverNames(podDiscovery))
+	pods.AssertExpectations(t.T())
+	namespaces.AssertExpectations(t.T())
+}
+
+func (t *PodTests) TestIssueLoadingNamespacesRaisesError() {
+	t.config.namespaceSelector = "team=some-team"
+	namespaces := &mocks.NamespacesInterface{}
+	namespaces.On("List", mock.Anything, mock.Anything).Return(nil, errors.New("something barfed"))
+
+	podDiscovery, err := CreatePodDiscoveryWithNamespaces(t.config, t.Build(), namespaces)
+	t.NoError(err)
+
+	err = podDiscovery.Discover(context.Background(), make(chan *Target, 1))
+	t.ErrorContains(err, "error discovering namespaces: something barfed")
+}
+
+func (t *PodTests) discoverNames(podDiscovery *PodDiscovery) []string {
+	targets := make(chan *Target, 10)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	close(targets)
+
+	names := make([]string, 0)
+	for target := range targets {
+		names = append(names, target.Name)
+	}
+	return names
+}
+
+func (t *PodTests) createPod(name, ip string) v1.Pod {
+	pods := NewMockPods()
+	pods.AddPods(name, "some-namespace", nil, v1.PodIP{IP: ip}, createContainerPort(8080))
+	return pods.list.Items[0]
+}
+
+func (t *PodTests) podList(continueToken string, pods ...v1.Pod) *v1.PodList {
+	return &v1.PodList{ListMeta: metav1.ListMeta{Continue: continueToken}, Items: pods}
+}
+
 func createContainerPort(port int32) v1.ContainerPort {
 	return v1.ContainerPort{
 		Name:          "some-port",
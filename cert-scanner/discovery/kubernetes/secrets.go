@@ -0,0 +1,125 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	SecretName = "secret"
+)
+
+type SecretsInterface interface {
+	typedcorev1.SecretInterface
+}
+
+type SecretDiscovery struct {
+	secrets SecretsInterface
+	SecretDiscoveryConfig
+}
+
+type SecretDiscoveryConfig struct {
+	source    string
+	labelKeys []string
+	namespace string
+}
+
+// CreateSecretDiscovery creates a discovery that inventories the certificates stored in kubernetes.io/tls
+// Secrets. No connections are made, the parsed certificates are carried by a [StaticAddress] so they can
+// be validated even if no running pod serves them.
+func CreateSecretDiscovery(config SecretDiscoveryConfig, secrets SecretsInterface) (*SecretDiscovery, error) {
+	slog.Info("creating k8s secret discovery", "source", config.source, "namespace", config.namespace, "keys", strings.Join(config.labelKeys, ","))
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the cluster is required")
+	}
+	if secrets == nil {
+		return nil, fmt.Errorf("no secrets api has been provided")
+	}
+
+	return &SecretDiscovery{
+		SecretDiscoveryConfig: config,
+		secrets:               secrets,
+	}, nil
+}
+
+// Discover lists the TLS Secrets in the cluster and parses the certificate chain from each tls.crt, emitting
+// a [Target] holding the chain for each. Secrets that cannot be parsed are logged and skipped. Returns an error
+// if the secrets cannot be retrieved from the api
+func (d *SecretDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	slog.Debug("starting secret discovery", "source", d.source)
+	secrets, err := d.secrets.List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("type", string(v1.SecretTypeTLS)).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("error discovering secrets: %v", err)
+	}
+
+	numTargets := 0
+	for x := range secrets.Items {
+		secret := &secrets.Items[x]
+		if secret.Type != v1.SecretTypeTLS {
+			continue
+		}
+
+		certificates, err := parseCertificates(secret.Data[v1.TLSCertKey])
+		if err != nil {
+			slog.Error("error parsing secret certificates", "namespace", secret.Namespace, "secret", secret.Name, "error", err.Error())
+			continue
+		}
+
+		labels := Labels{
+			SecretName: secret.Name,
+			Namespace:  secret.Namespace,
+		}
+		for _, key := range d.labelKeys {
+			if label, ok := secret.Labels[key]; ok {
+				labels[key] = label
+			}
+		}
+
+		numTargets++
+		targets <- &Target{
+			Address: CreateStaticAddress(fmt.Sprintf("secret/%s/%s", secret.Namespace, secret.Name), certificates),
+			Metadata: Metadata{
+				Name:       secret.Name,
+				Source:     d.source,
+				SourceType: Kubernetes,
+				Labels:     labels,
+			},
+		}
+		slog.Debug("created target from secret", "namespace", secret.Namespace, "secret", secret.Name, "certificates", len(certificates))
+	}
+	slog.Info("finished secret discovery", "secrets", len(secrets.Items), "targets", numTargets)
+	return nil
+}
+
+// parseCertificates decodes a PEM encoded certificate chain, leaf first
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0)
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing certificate: %v", err)
+		}
+		certificates = append(certificates, cert)
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", v1.TLSCertKey)
+	}
+	return certificates, nil
+}
@@ -0,0 +1,118 @@
+//go:generate mockery --name SecretsInterface
+package kubernetes
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/discovery/kubernetes/mocks"
+	"github.com/sgargan/cert-scanner-darkly/testutils"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type SecretTests struct {
+	config  SecretDiscoveryConfig
+	secrets *mocks.SecretsInterface
+	list    *v1.SecretList
+	ca      *testutils.TestCA
+	err     error
+	suite.Suite
+}
+
+func (t *SecretTests) SetupTest() {
+	ca, err := testutils.CreateTestCA(2)
+	t.NoError(err)
+	t.ca = ca
+	t.secrets = &mocks.SecretsInterface{}
+	t.list = &v1.SecretList{Items: make([]v1.Secret, 0)}
+	t.err = nil
+	t.config = SecretDiscoveryConfig{
+		source:    "some-cluster",
+		labelKeys: []string{"app"},
+	}
+}
+
+func (t *SecretTests) TestDiscoveryCreationErrors() {
+	_, err := CreateSecretDiscovery(SecretDiscoveryConfig{}, t.secrets)
+	t.ErrorContains(err, "a valid source label for the cluster is required")
+
+	_, err = CreateSecretDiscovery(t.config, nil)
+	t.ErrorContains(err, "no secrets api has been provided")
+}
+
+func (t *SecretTests) TestDiscoversCertificatesFromTLSSecrets() {
+	cert, certPem, _, err := t.ca.CreateLeafCert("somehost")
+	t.NoError(err)
+	t.addSecret("some-secret", "some-namespace", v1.SecretTypeTLS, certPem)
+
+	targets := t.discover(1)
+	t.Equal(1, len(targets))
+	t.Equal(&Target{
+		Address: CreateStaticAddress("secret/some-namespace/some-secret", []*x509.Certificate{cert}),
+		Metadata: Metadata{
+			Name:       "some-secret",
+			Source:     "some-cluster",
+			SourceType: "kubernetes",
+			Labels: map[string]string{
+				"app":              "some-app",
+				"secret":           "some-secret",
+				"target_namespace": "some-namespace",
+			},
+		},
+	}, <-targets)
+}
+
+func (t *SecretTests) TestSkipsInvalidAndNonTLSSecrets() {
+	_, certPem, _, err := t.ca.CreateLeafCert("somehost")
+	t.NoError(err)
+	t.addSecret("opaque-secret", "some-namespace", v1.SecretTypeOpaque, certPem)
+	t.addSecret("invalid-secret", "some-namespace", v1.SecretTypeTLS, []byte("not a certificate"))
+
+	targets := t.discover(2)
+	t.Equal(0, len(targets))
+}
+
+func (t *SecretTests) TestListsOnlyTLSSecrets() {
+	t.discover(1)
+	t.secrets.AssertCalled(t.T(), "List", mock.Anything, metav1.ListOptions{FieldSelector: "type=kubernetes.io/tls"})
+}
+
+func (t *SecretTests) TestIssueLoadingSecretsRaisesError() {
+	t.err = errors.New("something barfed loading")
+	discovery, err := CreateSecretDiscovery(t.config, t.build())
+	t.NoError(err)
+	err = discovery.Discover(context.Background(), make(chan *Target, 1))
+	t.ErrorContains(err, "error discovering secrets: something barfed")
+}
+
+func (t *SecretTests) discover(buffer int) chan *Target {
+	discovery, err := CreateSecretDiscovery(t.config, t.build())
+	t.NoError(err)
+	targets := make(chan *Target, buffer)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	return targets
+}
+
+func (t *SecretTests) build() *mocks.SecretsInterface {
+	t.secrets.On("List", mock.Anything, mock.AnythingOfType("v1.ListOptions")).Return(t.list, t.err)
+	return t.secrets
+}
+
+func (t *SecretTests) addSecret(name, namespace string, secretType v1.SecretType, certPem []byte) {
+	t.list.Items = append(t.list.Items, v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "some-app"}},
+		Type:       secretType,
+		Data:       map[string][]byte{v1.TLSCertKey: certPem},
+	})
+}
+
+func TestSecretSuite(t *testing.T) {
+	suite.Run(t, &SecretTests{})
+}
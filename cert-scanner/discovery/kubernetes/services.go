@@ -0,0 +1,231 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	typeddiscoveryv1 "k8s.io/client-go/kubernetes/typed/discovery/v1"
+)
+
+const (
+	ServiceName = "target_service"
+	AppProtocol = "app_protocol"
+)
+
+type ServicesInterface interface {
+	typedcorev1.ServiceInterface
+}
+
+type EndpointSlicesInterface interface {
+	typeddiscoveryv1.EndpointSliceInterface
+}
+
+type ServiceDiscovery struct {
+	services       ServicesInterface
+	endpointSlices EndpointSlicesInterface
+	ignorePatterns []parsedIgnorePattern
+	ServiceDiscoveryConfig
+}
+
+type ServiceDiscoveryConfig struct {
+	source         string
+	labelKeys      []string
+	ignorePatterns []IgnorePattern
+	matchCIDR      *net.IPNet
+	namespace      string
+	pageSize       int64
+}
+
+// CreateServiceDiscovery creates a discovery that finds scan candidates by walking the Services in the cluster
+// and the EndpointSlices that back them. Only endpoints that are ready to receive traffic are emitted.
+func CreateServiceDiscovery(config ServiceDiscoveryConfig, services ServicesInterface, endpointSlices EndpointSlicesInterface) (*ServiceDiscovery, error) {
+	slog.Info("creating k8s service discovery", "source", config.source, "namespace", config.namespace, "keys", strings.Join(config.labelKeys, ","), "matchCIDR", config.matchCIDR.String())
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the cluster is required")
+	}
+	if services == nil {
+		return nil, fmt.Errorf("no services api has been provided")
+	}
+	if endpointSlices == nil {
+		return nil, fmt.Errorf("no endpoint slices api has been provided")
+	}
+
+	ignorePatterns, err := parseIgnorePatterns(config.ignorePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ignore service patterns: %v", err)
+	}
+
+	return &ServiceDiscovery{
+		ServiceDiscoveryConfig: config,
+		services:               services,
+		endpointSlices:         endpointSlices,
+		ignorePatterns:         ignorePatterns,
+	}, nil
+}
+
+// Discover lists the Services in the cluster along with their EndpointSlices, creating a [Target] for each
+// ready endpoint address and port. Each target is emitted onto the given channel for processing. Returns an
+// error if the services or slices cannot be retrieved from the api
+func (d *ServiceDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	slog.Debug("starting service discovery", "source", d.source)
+	services, err := d.listServices(ctx)
+	if err != nil {
+		return fmt.Errorf("error discovering services: %v", err)
+	}
+
+	slices, err := d.listEndpointSlices(ctx)
+	if err != nil {
+		return fmt.Errorf("error discovering endpoint slices: %v", err)
+	}
+	slog.Debug("retrieved services from api", "source", d.source, "services", len(services), "slices", len(slices))
+
+	slicesByService := make(map[string][]*discoveryv1.EndpointSlice)
+	for x := range slices {
+		slice := &slices[x]
+		key := namespacedKey(slice.Namespace, slice.Labels[discoveryv1.LabelServiceName])
+		slicesByService[key] = append(slicesByService[key], slice)
+	}
+
+	numTargets := 0
+	for x := range services {
+		service := &services[x]
+		ignored, err := ignore(service, d.ignorePatterns)
+		if err != nil {
+			slog.Error("error ignoring service", "namespace", service.Namespace, "service", service.Name, "error", err.Error())
+			continue
+		}
+		if ignored || service.Spec.Type == v1.ServiceTypeExternalName {
+			continue
+		}
+
+		for _, slice := range slicesByService[namespacedKey(service.Namespace, service.Name)] {
+			numTargets += d.discoverSlice(service, slice, targets)
+		}
+	}
+	slog.Info("finished service discovery", "services", len(services), "targets", numTargets)
+	return nil
+}
+
+// listServices lists the services one page at a time
+func (d *ServiceDiscovery) listServices(ctx context.Context) ([]v1.Service, error) {
+	services := make([]v1.Service, 0)
+	options := metav1.ListOptions{Limit: d.pageSize}
+	for {
+		page, err := d.services.List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, page.Items...)
+		if page.Continue == "" {
+			return services, nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+// listEndpointSlices lists the endpoint slices one page at a time
+func (d *ServiceDiscovery) listEndpointSlices(ctx context.Context) ([]discoveryv1.EndpointSlice, error) {
+	slices := make([]discoveryv1.EndpointSlice, 0)
+	options := metav1.ListOptions{Limit: d.pageSize}
+	for {
+		page, err := d.endpointSlices.List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		slices = append(slices, page.Items...)
+		if page.Continue == "" {
+			return slices, nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+// discoverSlice emits a target for each ready endpoint address in the slice and each of the slices TCP
+// ports, returning the number of targets emitted.
+func (d *ServiceDiscovery) discoverSlice(service *v1.Service, slice *discoveryv1.EndpointSlice, targets chan *Target) int {
+	if slice.AddressType == discoveryv1.AddressTypeFQDN {
+		slog.Debug("skipping fqdn endpoint slice", "namespace", slice.Namespace, "slice", slice.Name)
+		return 0
+	}
+
+	numTargets := 0
+	for _, endpoint := range slice.Endpoints {
+		if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+			continue
+		}
+
+		for _, address := range endpoint.Addresses {
+			ip, err := netip.ParseAddr(address)
+			if err != nil {
+				slog.Error("error parsing endpoint ip", "namespace", slice.Namespace, "slice", slice.Name, "ip", address, "error", err.Error())
+				continue
+			}
+
+			if d.matchCIDR != nil && !d.matchCIDR.Contains(net.IP(ip.AsSlice())) {
+				slog.Debug("endpoint does not match match cidr", "namespace", slice.Namespace, "service", service.Name, "ip", address, "matchCIDR", d.matchCIDR.String())
+				continue
+			}
+
+			for _, port := range slice.Ports {
+				if port.Port == nil || (port.Protocol != nil && *port.Protocol != v1.ProtocolTCP) {
+					continue
+				}
+
+				numTargets++
+				targets <- &Target{
+					Address: CreateNetIPAddress(netip.AddrPortFrom(ip, uint16(*port.Port))),
+					Metadata: Metadata{
+						Name:       service.Name,
+						Source:     d.source,
+						SourceType: Kubernetes,
+						Labels:     d.createLabels(service, endpoint, port),
+						Network:    d.source,
+					},
+				}
+				slog.Debug("created target from service endpoint", "namespace", service.Namespace, "service", service.Name, "ip", address, "port", *port.Port)
+			}
+		}
+	}
+	return numTargets
+}
+
+func (d *ServiceDiscovery) createLabels(service *v1.Service, endpoint discoveryv1.Endpoint, port discoveryv1.EndpointPort) Labels {
+	labels := Labels{
+		ServiceName: service.Name,
+		Namespace:   service.Namespace,
+		PortName:    stringOrEmpty(port.Name),
+		AppProtocol: stringOrEmpty(port.AppProtocol),
+	}
+
+	if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+		labels[PodName] = endpoint.TargetRef.Name
+	}
+
+	for _, key := range d.labelKeys {
+		if label, ok := service.Labels[key]; ok {
+			labels[key] = label
+		}
+	}
+	return labels
+}
+
+func namespacedKey(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,272 @@
+//go:generate mockery --name ServicesInterface
+//go:generate mockery --name EndpointSlicesInterface
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/discovery/kubernetes/mocks"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ServiceTests struct {
+	config ServiceDiscoveryConfig
+	MockServices
+	suite.Suite
+}
+
+func (t *ServiceTests) SetupTest() {
+	t.MockServices = NewMockServices()
+	t.config = ServiceDiscoveryConfig{
+		source:    "some-cluster",
+		labelKeys: []string{"app"},
+	}
+}
+
+func (t *ServiceTests) TestDiscoveryCreationErrors() {
+	services, slices := t.Build()
+	_, err := CreateServiceDiscovery(ServiceDiscoveryConfig{}, services, slices)
+	t.ErrorContains(err, "a valid source label for the cluster is required")
+
+	_, err = CreateServiceDiscovery(t.config, nil, slices)
+	t.ErrorContains(err, "no services api has been provided")
+
+	_, err = CreateServiceDiscovery(t.config, services, nil)
+	t.ErrorContains(err, "no endpoint slices api has been provided")
+}
+
+func (t *ServiceTests) TestDiscoversReadyEndpoints() {
+	t.AddService("some-service", "some-namespace", map[string]string{"app": "some-app"})
+	t.AddSlice("some-service", "some-namespace", createEndpointPort("https", "kubernetes.io/h2c", 8443),
+		createEndpoint("some-pod", true, "10.0.1.1"),
+		createEndpoint("another-pod", false, "10.0.1.2"),
+	)
+
+	targets := t.discover(2)
+	t.Equal(1, len(targets))
+	t.Equal(&Target{
+		Metadata: Metadata{
+			Name:       "some-service",
+			Source:     "some-cluster",
+			SourceType: "kubernetes",
+			Network:    "some-cluster",
+			Labels: map[string]string{
+				"app":              "some-app",
+				"app_protocol":     "kubernetes.io/h2c",
+				"port_name":        "https",
+				"target_namespace": "some-namespace",
+				"target_pod":       "some-pod",
+				"target_service":   "some-service",
+			},
+		},
+		Address: getAddress("10.0.1.1:8443"),
+	}, <-targets)
+}
+
+func (t *ServiceTests) TestDiscoversEachAddressAndPort() {
+	t.AddService("some-service", "some-namespace", map[string]string{})
+	t.AddSlice("some-service", "some-namespace", createEndpointPort("https", "", 8443),
+		createEndpoint("some-pod", true, "10.0.1.1"),
+		createEndpoint("another-pod", true, "10.0.1.2"),
+	)
+	t.slices.Items[0].Ports = append(t.slices.Items[0].Ports, createEndpointPort("grpc", "", 9443))
+
+	targets := t.discover(4)
+	t.Equal(4, len(targets))
+	addresses := []string{}
+	for x := 0; x < 4; x++ {
+		addresses = append(addresses, (<-targets).Address.String())
+	}
+	t.ElementsMatch([]string{"10.0.1.1:8443", "10.0.1.1:9443", "10.0.1.2:8443", "10.0.1.2:9443"}, addresses)
+}
+
+func (t *ServiceTests) TestMatchesSlicesToServices() {
+	t.AddService("some-service", "some-namespace", map[string]string{})
+	t.AddSlice("some-service", "another-namespace", createEndpointPort("https", "", 8443),
+		createEndpoint("some-pod", true, "10.0.1.1"),
+	)
+	t.AddSlice("another-service", "some-namespace", createEndpointPort("https", "", 8443),
+		createEndpoint("another-pod", true, "10.0.1.2"),
+	)
+
+	targets := t.discover(2)
+	t.Equal(0, len(targets))
+}
+
+func (t *ServiceTests) TestIgnoresServices() {
+	t.AddService("some-service", "kube-system", map[string]string{})
+	t.AddSlice("some-service", "kube-system", createEndpointPort("https", "", 8443),
+		createEndpoint("some-pod", true, "10.0.1.1"),
+	)
+	t.config.ignorePatterns = []IgnorePattern{{Pattern: "{.metadata.namespace}", Match: []string{"kube-system"}}}
+
+	targets := t.discover(1)
+	t.Equal(0, len(targets))
+}
+
+func (t *ServiceTests) TestIgnoresNonTCPPortsAndFQDNSlices() {
+	t.AddService("some-service", "some-namespace", map[string]string{})
+	udp := v1.ProtocolUDP
+	port := createEndpointPort("dns", "", 53)
+	port.Protocol = &udp
+	t.AddSlice("some-service", "some-namespace", port, createEndpoint("some-pod", true, "10.0.1.1"))
+	t.AddSlice("some-service", "some-namespace", createEndpointPort("https", "", 443), createEndpoint("", true, "some.host.com"))
+	t.slices.Items[1].AddressType = discoveryv1.AddressTypeFQDN
+
+	targets := t.discover(2)
+	t.Equal(0, len(targets))
+}
+
+func (t *ServiceTests) TestIgnoresEndpointsByMatchCIDR() {
+	t.AddService("some-service", "some-namespace", map[string]string{})
+	t.AddSlice("some-service", "some-namespace", createEndpointPort("https", "", 8443),
+		createEndpoint("some-pod", true, "10.0.1.1"),
+		createEndpoint("another-pod", true, "10.1.1.1"),
+	)
+	_, t.config.matchCIDR, _ = net.ParseCIDR("10.1.0.0/16")
+
+	targets := t.discover(2)
+	t.Equal(1, len(targets))
+	t.Equal("10.1.1.1:8443", (<-targets).Address.String())
+}
+
+func (t *ServiceTests) TestIssueLoadingServicesRaisesError() {
+	t.RaiseError("something barfed loading")
+	services, slices := t.Build()
+	discovery, err := CreateServiceDiscovery(t.config, services, slices)
+	t.NoError(err)
+	err = discovery.Discover(context.Background(), make(chan *Target, 1))
+	t.ErrorContains(err, "error discovering services: something barfed")
+}
+
+func (t *ServiceTests) TestListsServicesAndSlicesInPages() {
+	t.config.pageSize = 1
+	t.AddService("some-service", "some-namespace", nil)
+	t.AddService("another-service", "some-namespace", nil)
+	t.AddSlice("some-service", "some-namespace", createEndpointPort("https", "", 8443), createEndpoint("", true, "10.0.1.1"))
+	t.AddSlice("another-service", "some-namespace", createEndpointPort("https", "", 8443), createEndpoint("", true, "10.0.1.2"))
+
+	services := &mocks.ServicesInterface{}
+	services.On("List", mock.Anything, metav1.ListOptions{Limit: 1}).Return(
+		&v1.ServiceList{ListMeta: metav1.ListMeta{Continue: "some-continue"}, Items: t.list.Items[:1]}, nil).Once()
+	services.On("List", mock.Anything, metav1.ListOptions{Limit: 1, Continue: "some-continue"}).Return(
+		&v1.ServiceList{Items: t.list.Items[1:]}, nil).Once()
+	slices := &mocks.EndpointSlicesInterface{}
+	slices.On("List", mock.Anything, metav1.ListOptions{Limit: 1}).Return(
+		&discoveryv1.EndpointSliceList{ListMeta: metav1.ListMeta{Continue: "another-continue"}, Items: t.slices.Items[:1]}, nil).Once()
+	slices.On("List", mock.Anything, metav1.ListOptions{Limit: 1, Continue: "another-continue"}).Return(
+		&discoveryv1.EndpointSliceList{Items: t.slices.Items[1:]}, nil).Once()
+
+	discovery, err := CreateServiceDiscovery(t.config, services, slices)
+	t.NoError(err)
+	targets := make(chan *Target, 2)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	close(targets)
+
+	addresses := make([]string, 0)
+	for target := range targets {
+		addresses = append(addresses, target.Address.String())
+	}
+	t.ElementsMatch([]string{"10.0.1.1:8443", "10.0.1.2:8443"}, addresses)
+	services.AssertExpectations(t.T())
+	slices.AssertExpectations(t.T())
+}
+
+func (t *ServiceTests) discover(buffer int) chan *Target {
+	services, slices := t.Build()
+	discovery, err := CreateServiceDiscovery(t.config, services, slices)
+	t.NoError(err)
+	targets := make(chan *Target, buffer)
+	t.NoError(discovery.Discover(context.Background(), targets))
+	return targets
+}
+
+func createEndpoint(pod string, ready bool, addresses ...string) discoveryv1.Endpoint {
+	endpoint := discoveryv1.Endpoint{
+		Addresses:  addresses,
+		Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+	}
+	if pod != "" {
+		endpoint.TargetRef = &v1.ObjectReference{Kind: "Pod", Name: pod}
+	}
+	return endpoint
+}
+
+func createEndpointPort(name, appProtocol string, port int32) discoveryv1.EndpointPort {
+	tcp := v1.ProtocolTCP
+	endpointPort := discoveryv1.EndpointPort{
+		Name:     &name,
+		Port:     &port,
+		Protocol: &tcp,
+	}
+	if appProtocol != "" {
+		endpointPort.AppProtocol = &appProtocol
+	}
+	return endpointPort
+}
+
+type MockServices struct {
+	services       *mocks.ServicesInterface
+	endpointSlices *mocks.EndpointSlicesInterface
+	list           *v1.ServiceList
+	slices         *discoveryv1.EndpointSliceList
+	err            error
+}
+
+func NewMockServices() MockServices {
+	return MockServices{
+		services:       &mocks.ServicesInterface{},
+		endpointSlices: &mocks.EndpointSlicesInterface{},
+		list:           &v1.ServiceList{Items: make([]v1.Service, 0)},
+		slices:         &discoveryv1.EndpointSliceList{Items: make([]discoveryv1.EndpointSlice, 0)},
+	}
+}
+
+func (m *MockServices) AddService(name, namespace string, labels map[string]string) {
+	m.list.Items = append(m.list.Items, v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeClusterIP,
+		},
+	})
+}
+
+func (m *MockServices) AddSlice(service, namespace string, port discoveryv1.EndpointPort, endpoints ...discoveryv1.Endpoint) {
+	m.slices.Items = append(m.slices.Items, discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service + "-abcde",
+			Namespace: namespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   endpoints,
+		Ports:       []discoveryv1.EndpointPort{port},
+	})
+}
+
+func (m *MockServices) RaiseError(err string) {
+	m.err = errors.New(err)
+}
+
+func (m *MockServices) Build() (*mocks.ServicesInterface, *mocks.EndpointSlicesInterface) {
+	m.services.On("List", mock.Anything, mock.AnythingOfType("v1.ListOptions")).Return(m.list, m.err)
+	m.endpointSlices.On("List", mock.Anything, mock.AnythingOfType("v1.ListOptions")).Return(m.slices, nil)
+	return m.services, m.endpointSlices
+}
+
+func TestServiceSuite(t *testing.T) {
+	suite.Run(t, &ServiceTests{})
+}
@@ -0,0 +1,127 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedappsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+	typedbatchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
+)
+
+const (
+	WorkloadKind = "workload_kind"
+	WorkloadName = "workload_name"
+)
+
+type workload struct {
+	kind   string
+	name   string
+	labels Labels
+}
+
+// WorkloadResolver resolves the workload that owns a pod by following its controller owner references, so
+// targets can be labelled with a name that outlives the pod. ReplicaSets are resolved to their Deployment
+// and Jobs to their CronJob. Resolved workloads are cached until the resolver is reset.
+type WorkloadResolver struct {
+	apps        typedappsv1.AppsV1Interface
+	batch       typedbatchv1.BatchV1Interface
+	annotations []string
+	cache       map[string]*workload
+}
+
+// CreateWorkloadResolver creates a resolver that looks up owners with the given apps and batch apis. The
+// given annotations are copied from each resolved workload into the labels of its targets.
+func CreateWorkloadResolver(apps typedappsv1.AppsV1Interface, batch typedbatchv1.BatchV1Interface, annotations []string) (*WorkloadResolver, error) {
+	if apps == nil || batch == nil {
+		return nil, fmt.Errorf("apps and batch apis are required to resolve workloads")
+	}
+	return &WorkloadResolver{
+		apps:        apps,
+		batch:       batch,
+		annotations: annotations,
+		cache:       make(map[string]*workload),
+	}, nil
+}
+
+// Reset clears the cached workloads so changes to them are picked up by the next discovery
+func (r *WorkloadResolver) Reset() {
+	r.cache = make(map[string]*workload)
+}
+
+// Resolve returns the top level workload that owns the given pod. Pods without a controller are their own
+// workload. If an owner cannot be retrieved, the last owner that was resolved is returned.
+func (r *WorkloadResolver) Resolve(ctx context.Context, pod *v1.Pod) *workload {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return &workload{kind: "Pod", name: pod.Name, labels: r.copyAnnotations(pod)}
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", owner.Kind, pod.Namespace, owner.Name)
+	if resolved, ok := r.cache[key]; ok {
+		return resolved
+	}
+	resolved := r.resolveOwner(ctx, pod.Namespace, owner.Kind, owner.Name)
+	r.cache[key] = resolved
+	return resolved
+}
+
+func (r *WorkloadResolver) resolveOwner(ctx context.Context, namespace, kind, name string) *workload {
+	obj, err := r.get(ctx, namespace, kind, name)
+	if err != nil {
+		slog.Debug("error retrieving workload", "namespace", namespace, "kind", kind, "name", name, "error", err.Error())
+		return &workload{kind: kind, name: name, labels: Labels{}}
+	}
+	if obj == nil {
+		return &workload{kind: kind, name: name, labels: Labels{}}
+	}
+
+	// only ReplicaSets and Jobs are owned by another workload
+	if kind == "ReplicaSet" || kind == "Job" {
+		if parent := metav1.GetControllerOf(obj); parent != nil {
+			return r.resolveOwner(ctx, namespace, parent.Kind, parent.Name)
+		}
+	}
+	return &workload{kind: kind, name: name, labels: r.copyAnnotations(obj)}
+}
+
+// get retrieves the given workload. Top level workloads are only retrieved when their annotations are
+// needed, returning nil otherwise.
+func (r *WorkloadResolver) get(ctx context.Context, namespace, kind, name string) (metav1.Object, error) {
+	switch kind {
+	case "ReplicaSet":
+		return r.apps.ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "Job":
+		return r.batch.Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+
+	if len(r.annotations) == 0 {
+		return nil, nil
+	}
+	switch kind {
+	case "Deployment":
+		return r.apps.Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "StatefulSet":
+		return r.apps.StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "DaemonSet":
+		return r.apps.DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "CronJob":
+		return r.batch.CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	return nil, nil
+}
+
+func (r *WorkloadResolver) copyAnnotations(obj metav1.Object) Labels {
+	labels := Labels{}
+	annotations := obj.GetAnnotations()
+	for _, key := range r.annotations {
+		if value, ok := annotations[key]; ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
@@ -0,0 +1,128 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/suite"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type WorkloadTests struct {
+	client   *fake.Clientset
+	resolver *WorkloadResolver
+	suite.Suite
+}
+
+func (t *WorkloadTests) SetupTest() {
+	t.client = fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: t.meta("some-deployment", nil, map[string]string{"team": "some-team"})},
+		&appsv1.ReplicaSet{ObjectMeta: t.meta("some-deployment-abcde", t.owner("Deployment", "some-deployment"), nil)},
+		&appsv1.ReplicaSet{ObjectMeta: t.meta("some-replicaset", nil, map[string]string{"team": "another-team"})},
+		&appsv1.StatefulSet{ObjectMeta: t.meta("some-statefulset", nil, map[string]string{"owner": "someone"})},
+		&batchv1.CronJob{ObjectMeta: t.meta("some-cronjob", nil, map[string]string{"team": "some-team"})},
+		&batchv1.Job{ObjectMeta: t.meta("some-cronjob-12345", t.owner("CronJob", "some-cronjob"), nil)},
+	)
+	resolver, err := CreateWorkloadResolver(t.client.AppsV1(), t.client.BatchV1(), []string{"team", "owner"})
+	t.NoError(err)
+	t.resolver = resolver
+}
+
+func (t *WorkloadTests) TestResolverCreationErrors() {
+	_, err := CreateWorkloadResolver(nil, t.client.BatchV1(), nil)
+	t.ErrorContains(err, "apps and batch apis are required to resolve workloads")
+}
+
+func (t *WorkloadTests) TestResolvesDeployments() {
+	t.assertWorkload("Deployment", "some-deployment", Labels{"team": "some-team"}, t.pod("ReplicaSet", "some-deployment-abcde"))
+}
+
+func (t *WorkloadTests) TestResolvesCronJobs() {
+	t.assertWorkload("CronJob", "some-cronjob", Labels{"team": "some-team"}, t.pod("Job", "some-cronjob-12345"))
+}
+
+func (t *WorkloadTests) TestResolvesDirectOwners() {
+	t.assertWorkload("StatefulSet", "some-statefulset", Labels{"owner": "someone"}, t.pod("StatefulSet", "some-statefulset"))
+	t.assertWorkload("ReplicaSet", "some-replicaset", Labels{"team": "another-team"}, t.pod("ReplicaSet", "some-replicaset"))
+	t.assertWorkload("DaemonSet", "missing-daemonset", Labels{}, t.pod("DaemonSet", "missing-daemonset"))
+}
+
+func (t *WorkloadTests) TestResolvesPodsWithoutOwners() {
+	pod := &v1.Pod{ObjectMeta: t.meta("some-pod", nil, map[string]string{"team": "some-team"})}
+	t.assertWorkload("Pod", "some-pod", Labels{"team": "some-team"}, pod)
+}
+
+func (t *WorkloadTests) TestFallsBackToKnownOwner() {
+	t.assertWorkload("ReplicaSet", "missing-replicaset", Labels{}, t.pod("ReplicaSet", "missing-replicaset"))
+}
+
+func (t *WorkloadTests) TestCachesWorkloadsUntilReset() {
+	pod := t.pod("ReplicaSet", "some-deployment-abcde")
+	t.resolver.Resolve(context.Background(), pod)
+	t.NoError(t.client.AppsV1().Deployments("some-namespace").Delete(context.Background(), "some-deployment", metav1.DeleteOptions{}))
+	t.assertWorkload("Deployment", "some-deployment", Labels{"team": "some-team"}, pod)
+
+	t.resolver.Reset()
+	t.assertWorkload("Deployment", "some-deployment", Labels{}, pod)
+}
+
+func (t *WorkloadTests) TestOnlyRetrievesOwnersWhenNeeded() {
+	resolver, err := CreateWorkloadResolver(t.client.AppsV1(), t.client.BatchV1(), nil)
+	t.NoError(err)
+	t.client.ClearActions()
+
+	resolver.Resolve(context.Background(), t.pod("ReplicaSet", "some-deployment-abcde"))
+	resolver.Resolve(context.Background(), t.pod("StatefulSet", "some-statefulset"))
+	t.Len(t.client.Actions(), 1)
+}
+
+func (t *WorkloadTests) TestLabelsPodTargets() {
+	mockPods := NewMockPods()
+	mockPods.AddPods("some-pod", "some-namespace", map[string]string{"team": "pod-team"}, v1.PodIP{IP: "10.0.1.1"}, createContainerPort(8080))
+	mockPods.list.Items[0].OwnerReferences = t.owner("ReplicaSet", "some-deployment-abcde")
+
+	config := PodDiscoveryConfig{source: "some-cluster", labelKeys: []string{"team"}}
+	podDiscovery, err := CreatePodDiscoveryWithWorkloads(config, mockPods.Build(), nil, t.resolver)
+	t.NoError(err)
+
+	targets := make(chan *Target, 1)
+	t.NoError(podDiscovery.Discover(context.Background(), targets))
+	labels := (<-targets).Metadata.Labels
+	t.Equal("Deployment", labels[WorkloadKind])
+	t.Equal("some-deployment", labels[WorkloadName])
+	t.Equal("pod-team", labels["team"])
+}
+
+func (t *WorkloadTests) assertWorkload(kind, name string, labels Labels, pod *v1.Pod) {
+	resolved := t.resolver.Resolve(context.Background(), pod)
+	t.Equal(kind, resolved.kind)
+	t.Equal(name, resolved.name)
+	t.Equal(labels, resolved.labels)
+}
+
+func (t *WorkloadTests) pod(kind, name string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: t.meta("some-pod", t.owner(kind, name), nil)}
+}
+
+func (t *WorkloadTests) meta(name string, owners []metav1.OwnerReference, annotations map[string]string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:            name,
+		Namespace:       "some-namespace",
+		OwnerReferences: owners,
+		Annotations:     annotations,
+	}
+}
+
+func (t *WorkloadTests) owner(kind, name string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+}
+
+func TestWorkloadSuite(t *testing.T) {
+	suite.Run(t, &WorkloadTests{})
+}
@@ -0,0 +1,160 @@
+package portscan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/sgargan/cert-scanner-darkly/utils"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	PortScan = "port_scan"
+	Scanner  = "scanner"
+	Service  = "service"
+	Product  = "product"
+	Hostname = "hostname"
+	Port     = "port"
+
+	Nmap    = "nmap"
+	Masscan = "masscan"
+)
+
+// openPort is an open tcp port found by a port scanner
+type openPort struct {
+	address  netip.AddrPort
+	hostname string
+	service  string
+	product  string
+	tls      bool
+}
+
+type PortScanDiscoveryConfig struct {
+	source  string
+	paths   []string
+	tlsOnly bool
+}
+
+type PortScanDiscovery struct {
+	PortScanDiscoveryConfig
+}
+
+// CreateDiscovery creates a Discovery instance that imports targets from nmap and masscan results
+func CreateDiscovery() (Discovery, error) {
+	return CreatePortScanDiscovery(PortScanDiscoveryConfig{
+		source:  viper.GetString(config.DiscoveryPortScansSource),
+		paths:   viper.GetStringSlice(config.DiscoveryPortScansPaths),
+		tlsOnly: viper.GetBool(config.DiscoveryPortScansTLSOnly),
+	})
+}
+
+// CreatePortScanDiscovery creates a discovery that imports the scan results in the given paths
+func CreatePortScanDiscovery(config PortScanDiscoveryConfig) (*PortScanDiscovery, error) {
+	slog.Info("creating port scan discovery", "source", config.source, "paths", config.paths, "tlsOnly", config.tlsOnly)
+	if config.source == "" {
+		return nil, fmt.Errorf("a valid source label for the port scans is required")
+	}
+	if len(config.paths) == 0 {
+		return nil, fmt.Errorf("no port scan result paths configured")
+	}
+	for _, path := range config.paths {
+		if _, err := filepath.Match(path, ""); err != nil {
+			return nil, fmt.Errorf("error parsing port scan result path %s: %v", path, err)
+		}
+	}
+	return &PortScanDiscovery{PortScanDiscoveryConfig: config}, nil
+}
+
+// Discover parses each result file, detecting whether it is nmap xml or masscan json from its content, and
+// emits a [Target] for each open tcp port. If tlsOnly is configured, only ports where the scanner detected
+// a tls service are discovered. Ports from every file that could be parsed are discovered, then a
+// [SourceError] for each that could not be is returned.
+func (d *PortScanDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	var errs error
+	files, err := utils.ResolveFiles(d.paths)
+	if err != nil {
+		errs = CreateSourceError(err, d.source)
+	}
+	numTargets := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error reading port scan results %s: %v", file, err), d.source))
+			continue
+		}
+
+		scanner, ports, err := parseResults(data)
+		if err != nil {
+			errs = errors.Join(errs, CreateSourceError(fmt.Errorf("error parsing port scan results %s: %v", file, err), d.source))
+			continue
+		}
+		slog.Debug("loaded port scan results", "file", file, "scanner", scanner, "ports", len(ports))
+
+		for _, port := range ports {
+			if d.tlsOnly && !port.tls {
+				continue
+			}
+			numTargets++
+			targets <- d.createTarget(scanner, port)
+		}
+	}
+	slog.Info("finished port scan discovery", "source", d.source, "files", len(files), "targets", numTargets)
+	return errs
+}
+
+func (d *PortScanDiscovery) createTarget(scanner string, port openPort) *Target {
+	name := port.address.Addr().String()
+	if port.hostname != "" {
+		name = port.hostname
+	}
+	labels := Labels{
+		Scanner: scanner,
+		Port:    strconv.Itoa(int(port.address.Port())),
+	}
+	for key, value := range map[string]string{Service: port.service, Product: port.product, Hostname: port.hostname} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	return &Target{
+		Address: CreateNetIPAddress(port.address),
+		Metadata: Metadata{
+			Name:       name,
+			Source:     d.source,
+			SourceType: PortScan,
+			Labels:     labels,
+		},
+	}
+}
+
+// parseResults parses nmap xml or masscan json results, returning the scanner that produced them and the
+// open tcp ports they hold
+func parseResults(data []byte) (string, []openPort, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		ports, err := parseNmap(trimmed)
+		return Nmap, ports, err
+	case bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")):
+		ports, err := parseMasscan(trimmed)
+		return Masscan, ports, err
+	default:
+		return "", nil, fmt.Errorf("unrecognised format, expected nmap xml or masscan json")
+	}
+}
+
+// isTLSService returns true if the service name reported by the scanner is a tls service
+func isTLSService(name string) bool {
+	name = strings.ToLower(name)
+	return name == "ssl" || name == "https" || name == "x509" || strings.HasPrefix(name, "ssl/")
+}
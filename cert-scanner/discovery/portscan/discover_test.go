@@ -0,0 +1,179 @@
+package portscan
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+const testNmap = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<nmaprun scanner="nmap" args="nmap -sV -oX scan.xml 10.0.0.0/30">
+<host><status state="up" reason="syn-ack"/>
+<address addr="10.0.0.1" addrtype="ipv4"/>
+<address addr="00:11:22:33:44:55" addrtype="mac"/>
+<hostnames><hostname name="web.internal" type="PTR"/></hostnames>
+<ports>
+<port protocol="tcp" portid="22"><state state="open"/><service name="ssh" product="OpenSSH"/></port>
+<port protocol="tcp" portid="443"><state state="open"/><service name="http" product="nginx" tunnel="ssl"/></port>
+<port protocol="tcp" portid="8443"><state state="open"/><service name="https"/></port>
+<port protocol="tcp" portid="9443"><state state="filtered"/><service name="tungsten-https"/></port>
+<port protocol="udp" portid="53"><state state="open"/><service name="domain"/></port>
+</ports>
+</host>
+<host><status state="down" reason="no-response"/>
+<address addr="10.0.0.2" addrtype="ipv4"/>
+</host>
+<host><status state="up" reason="syn-ack"/>
+<address addr="fd00::3" addrtype="ipv6"/>
+<ports><port protocol="tcp" portid="636"><state state="open"/><service name="ssl/ldap"/></port></ports>
+</host>
+</nmaprun>`
+
+const testMasscan = `[
+{   "ip": "10.1.0.1",   "timestamp": "1700000000", "ports": [ {"port": 443, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "10.1.0.1",   "timestamp": "1700000001", "ports": [ {"port": 443, "proto": "tcp", "service": {"name": "http", "banner": "HTTP/1.1 400"} } ] },
+{   "ip": "10.1.0.1",   "timestamp": "1700000001", "ports": [ {"port": 443, "proto": "tcp", "service": {"name": "X509", "banner": "MIIB..."} } ] },
+{   "ip": "10.1.0.2",   "timestamp": "1700000002", "ports": [ {"port": 8080, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "10.1.0.3",   "timestamp": "1700000002", "ports": [ {"port": 53, "proto": "udp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+]`
+
+type PortScanDiscoveryTests struct {
+	dir    string
+	config PortScanDiscoveryConfig
+	suite.Suite
+}
+
+func (t *PortScanDiscoveryTests) SetupTest() {
+	t.dir = t.T().TempDir()
+	t.config = PortScanDiscoveryConfig{source: "red-team", paths: []string{filepath.Join(t.dir, "*")}}
+}
+
+func (t *PortScanDiscoveryTests) TearDownTest() {
+	viper.Reset()
+}
+
+func (t *PortScanDiscoveryTests) TestDiscoveryCreationErrors() {
+	_, err := CreatePortScanDiscovery(PortScanDiscoveryConfig{paths: []string{"scan.xml"}})
+	t.ErrorContains(err, "a valid source label for the port scans is required")
+
+	_, err = CreatePortScanDiscovery(PortScanDiscoveryConfig{source: "red-team"})
+	t.ErrorContains(err, "no port scan result paths configured")
+
+	_, err = CreatePortScanDiscovery(PortScanDiscoveryConfig{source: "red-team", paths: []string{"scans/[.xml"}})
+	t.ErrorContains(err, "error parsing port scan result path scans/[.xml")
+}
+
+func (t *PortScanDiscoveryTests) TestDiscoversNmapPorts() {
+	t.writeResults("scan.xml", testNmap)
+	targets, err := t.discover()
+	t.NoError(err)
+	t.Equal([]string{"10.0.0.1:22", "10.0.0.1:443", "10.0.0.1:8443", "[fd00::3]:636"}, addresses(targets))
+
+	t.Equal(&Target{
+		Address: CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.1:443")),
+		Metadata: Metadata{
+			Name:       "web.internal",
+			Source:     "red-team",
+			SourceType: PortScan,
+			Labels: Labels{
+				Scanner:  Nmap,
+				Port:     "443",
+				Service:  "http",
+				Product:  "nginx",
+				Hostname: "web.internal",
+			},
+		},
+	}, targets[1])
+	t.Equal("fd00::3", targets[3].Name)
+}
+
+func (t *PortScanDiscoveryTests) TestDiscoversMasscanPorts() {
+	t.writeResults("scan.json", testMasscan)
+	targets, err := t.discover()
+	t.NoError(err)
+	t.Equal([]string{"10.1.0.1:443", "10.1.0.2:8080"}, addresses(targets))
+	t.Equal(Labels{Scanner: Masscan, Port: "443", Service: "X509"}, targets[0].Metadata.Labels)
+}
+
+func (t *PortScanDiscoveryTests) TestDiscoversMasscanLines() {
+	t.writeResults("scan.json", `{"ip": "10.1.0.1", "ports": [{"port": 443, "proto": "tcp", "status": "open"}]}
+{"ip": "10.1.0.2", "ports": [{"port": 8443, "proto": "tcp", "status": "open"}]}
+`)
+	targets, err := t.discover()
+	t.NoError(err)
+	t.Equal([]string{"10.1.0.1:443", "10.1.0.2:8443"}, addresses(targets))
+}
+
+func (t *PortScanDiscoveryTests) TestLimitsToTLSServices() {
+	t.config.tlsOnly = true
+	t.writeResults("nmap.xml", testNmap)
+	t.writeResults("masscan.json", testMasscan)
+	targets, err := t.discover()
+	t.NoError(err)
+	t.Equal([]string{"10.1.0.1:443", "10.0.0.1:443", "10.0.0.1:8443", "[fd00::3]:636"}, addresses(targets))
+}
+
+func (t *PortScanDiscoveryTests) TestDiscoversValidFiles() {
+	t.writeResults("a.xml", "<nmaprun><host>")
+	t.writeResults("b.txt", "Discovered open port 443/tcp on 10.0.0.1")
+	t.writeResults("c.xml", testNmap)
+	t.config.paths = append(t.config.paths, filepath.Join(t.dir, "missing.xml"))
+
+	targets, err := t.discover()
+	t.Len(targets, 4)
+	t.ErrorContains(err, "a.xml: error decoding nmap xml")
+	t.ErrorContains(err, "b.txt: unrecognised format, expected nmap xml or masscan json")
+	t.ErrorContains(err, "error reading port scan results "+filepath.Join(t.dir, "missing.xml"))
+	t.Equal([]string{"red-team"}, FailedSources(err))
+}
+
+func (t *PortScanDiscoveryTests) TestCreatesDiscoveryFromConfig() {
+	viper.Set(config.DiscoveryPortScansSource, "red-team")
+	viper.Set(config.DiscoveryPortScansPaths, []string{"/scans/*.xml"})
+	viper.Set(config.DiscoveryPortScansTLSOnly, true)
+
+	discovery, err := CreateDiscovery()
+	t.NoError(err)
+	d := discovery.(*PortScanDiscovery)
+	t.Equal([]string{"/scans/*.xml"}, d.paths)
+	t.True(d.tlsOnly)
+}
+
+func (t *PortScanDiscoveryTests) writeResults(name, content string) {
+	t.NoError(os.WriteFile(filepath.Join(t.dir, name), []byte(content), os.ModePerm))
+}
+
+func (t *PortScanDiscoveryTests) discover() ([]*Target, error) {
+	discovery, err := CreatePortScanDiscovery(t.config)
+	t.NoError(err)
+
+	targets := make(chan *Target, 10)
+	err = discovery.Discover(context.Background(), targets)
+	close(targets)
+
+	discovered := make([]*Target, 0)
+	for target := range targets {
+		discovered = append(discovered, target)
+	}
+	return discovered, err
+}
+
+func addresses(targets []*Target) []string {
+	addresses := make([]string, 0, len(targets))
+	for _, target := range targets {
+		addresses = append(addresses, target.Address.String())
+	}
+	return addresses
+}
+
+func TestPortScanDiscovery(t *testing.T) {
+	suite.Run(t, &PortScanDiscoveryTests{})
+}
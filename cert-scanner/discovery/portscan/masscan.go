@@ -0,0 +1,76 @@
+package portscan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+)
+
+// older versions of masscan end their json array with a trailing comma
+var trailingComma = regexp.MustCompile(`,\s*\]$`)
+
+type masscanRecord struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port    uint16 `json:"port"`
+		Proto   string `json:"proto"`
+		Status  string `json:"status"`
+		Service *struct {
+			Name string `json:"name"`
+		} `json:"service"`
+	} `json:"ports"`
+}
+
+// parseMasscan parses the open tcp ports from masscan -oJ output, either a json array or a json object per
+// line. With --banners, masscan records each banner it grabs separately, so these are merged into the port
+// they were grabbed from. Ports are tls if masscan grabbed an ssl or x509 banner.
+func parseMasscan(data []byte) ([]openPort, error) {
+	records := make([]masscanRecord, 0)
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(trailingComma.ReplaceAll(data, []byte("]")), &records); err != nil {
+			return nil, fmt.Errorf("error decoding masscan json: %v", err)
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for {
+			record := masscanRecord{}
+			if err := decoder.Decode(&record); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("error decoding masscan json: %v", err)
+			}
+			records = append(records, record)
+		}
+	}
+
+	ports := make([]openPort, 0)
+	found := make(map[netip.AddrPort]int)
+	for _, record := range records {
+		ip, err := netip.ParseAddr(record.IP)
+		if err != nil {
+			continue
+		}
+		for _, port := range record.Ports {
+			if port.Proto != "tcp" || (port.Status != "open" && port.Service == nil) {
+				continue
+			}
+			address := netip.AddrPortFrom(ip, port.Port)
+			x, ok := found[address]
+			if !ok {
+				x = len(ports)
+				found[address] = x
+				ports = append(ports, openPort{address: address})
+			}
+			if port.Service != nil && isTLSService(port.Service.Name) {
+				ports[x].tls = true
+				ports[x].service = port.Service.Name
+			} else if port.Service != nil && ports[x].service == "" {
+				ports[x].service = port.Service.Name
+			}
+		}
+	}
+	return ports, nil
+}
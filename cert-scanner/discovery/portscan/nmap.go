@@ -0,0 +1,96 @@
+package portscan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/netip"
+)
+
+type nmapRun struct {
+	Hosts []nmapHost `xml:"host"`
+}
+
+type nmapHost struct {
+	Status struct {
+		State string `xml:"state,attr"`
+	} `xml:"status"`
+	Addresses []struct {
+		Addr     string `xml:"addr,attr"`
+		AddrType string `xml:"addrtype,attr"`
+	} `xml:"address"`
+	Hostnames []struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"hostnames>hostname"`
+	Ports []struct {
+		Protocol string `xml:"protocol,attr"`
+		PortID   uint16 `xml:"portid,attr"`
+		State    struct {
+			State string `xml:"state,attr"`
+		} `xml:"state"`
+		Service struct {
+			Name    string `xml:"name,attr"`
+			Product string `xml:"product,attr"`
+			Tunnel  string `xml:"tunnel,attr"`
+		} `xml:"service"`
+	} `xml:"ports>port"`
+}
+
+// parseNmap parses the open tcp ports of the hosts that are up from nmap -oX output. Ports are tls if nmap
+// detected an ssl or https service, or an ssl tunnel
+func parseNmap(data []byte) ([]openPort, error) {
+	run := nmapRun{}
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("error decoding nmap xml: %v", err)
+	}
+
+	ports := make([]openPort, 0)
+	for _, host := range run.Hosts {
+		if host.Status.State != "" && host.Status.State != "up" {
+			continue
+		}
+		ip, ok := host.ip()
+		if !ok {
+			continue
+		}
+		for _, port := range host.Ports {
+			if port.Protocol != "tcp" || port.State.State != "open" {
+				continue
+			}
+			ports = append(ports, openPort{
+				address:  netip.AddrPortFrom(ip, port.PortID),
+				hostname: host.hostname(),
+				service:  port.Service.Name,
+				product:  port.Service.Product,
+				tls:      isTLSService(port.Service.Name) || port.Service.Tunnel == "ssl",
+			})
+		}
+	}
+	return ports, nil
+}
+
+// ip returns the ipv4 or ipv6 address of the host
+func (h *nmapHost) ip() (netip.Addr, bool) {
+	for _, address := range h.Addresses {
+		if address.AddrType != "ipv4" && address.AddrType != "ipv6" {
+			continue
+		}
+		if ip, err := netip.ParseAddr(address.Addr); err == nil {
+			return ip, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// hostname returns the hostname the host was scanned by, or its first reverse dns name
+func (h *nmapHost) hostname() string {
+	for _, hostname := range h.Hostnames {
+		if hostname.Type == "user" {
+			return hostname.Name
+		}
+	}
+	if len(h.Hostnames) > 0 {
+		return h.Hostnames[0].Name
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package processors
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slices"
+)
+
+// ClientCertificate is a certificate and key presented to targets that request a client certificate, e.g.
+// in a service mesh. One with sources or labels is only presented to targets discovered by one of the sources
+// that have all of the labels, one with neither is presented to every target.
+type ClientCertificate struct {
+	Cert    string            `mapstructure:"cert"`
+	Key     string            `mapstructure:"key"`
+	Sources []string          `mapstructure:"sources"`
+	Labels  map[string]string `mapstructure:"labels"`
+
+	certificate tls.Certificate
+}
+
+// loadClientCertificates loads the configured client certificates and their keys. Returns an error if a
+// certificate or key is missing or cannot be loaded.
+func loadClientCertificates() ([]*ClientCertificate, error) {
+	var certificates []*ClientCertificate
+	if err := viper.UnmarshalKey(config.ProcessorsTlsClientCerts, &certificates); err != nil {
+		return nil, fmt.Errorf("error parsing client certificates: %v", err)
+	}
+	for _, c := range certificates {
+		if c.Cert == "" || c.Key == "" {
+			return nil, fmt.Errorf("client certificates require both a cert and a key")
+		}
+		certificate, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate %s: %v", c.Cert, err)
+		}
+		c.certificate = certificate
+	}
+	return certificates, nil
+}
+
+// matches returns true if the certificate should be presented to the given target
+func (c *ClientCertificate) matches(target *Target) bool {
+	if len(c.Sources) > 0 && !slices.Contains(c.Sources, target.Source) {
+		return false
+	}
+	for k, v := range c.Labels {
+		if value, present := target.Metadata.Labels[k]; !present || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// clientCertificate returns the first configured client certificate that matches the target, or nil if none do
+func (c *TLSStateRetrieval) clientCertificate(target *Target) *tls.Certificate {
+	for _, certificate := range c.clientCerts {
+		if certificate.matches(target) {
+			return &certificate.certificate
+		}
+	}
+	return nil
+}
+
+// acceptableCAs decodes the distinguished names of the CAs a server accepts client certificates from
+func acceptableCAs(names [][]byte) []string {
+	decoded := make([]string, 0, len(names))
+	for _, der := range names {
+		var rdns pkix.RDNSequence
+		if _, err := asn1.Unmarshal(der, &rdns); err != nil {
+			continue
+		}
+		name := pkix.Name{}
+		name.FillFromRDNSequence(&rdns)
+		decoded = append(decoded, name.String())
+	}
+	return decoded
+}
@@ -0,0 +1,111 @@
+package processors
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sgargan/cert-scanner-darkly/processors/starttls"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/sgargan/cert-scanner-darkly/utils"
+	"golang.org/x/exp/slog"
+)
+
+// enumeration finds the tls versions and ciphers a single target accepts, counting the handshakes it makes
+type enumeration struct {
+	retrieval  *TLSStateRetrieval
+	target     *Target
+	negotiate  starttls.Negotiator
+	clientCert *tls.Certificate
+	handshakes atomic.Int32
+}
+
+// enumerate finds the ciphers the target accepts for each tls version, enumerating up to the configured
+// number of versions at once across every target dialling the same server. Results are returned in order of version, then the order the target chose the
+// ciphers in, so the first successful result is from the lowest version the target supports.
+func (e *enumeration) enumerate(ctx context.Context) []*ScanResult {
+	lock := sync.Mutex{}
+	versionResults := make(map[uint16][]*ScanResult)
+	limit := e.retrieval.limit(e.target)
+
+	wait := &utils.ContextualWaitGroup{}
+	wait.Add(len(tlsVersions))
+	for _, v := range tlsVersions {
+		version := v
+		go func() {
+			defer wait.Done()
+			select {
+			case limit <- struct{}{}:
+				defer func() { <-limit }()
+			case <-ctx.Done():
+				return
+			}
+			results := e.enumerateVersion(ctx, version)
+			lock.Lock()
+			defer lock.Unlock()
+			versionResults[version] = results
+		}()
+	}
+	wait.WaitWithContext(ctx)
+
+	lock.Lock()
+	defer lock.Unlock()
+	results := make([]*ScanResult, 0)
+	for _, version := range tlsVersions {
+		results = append(results, versionResults[version]...)
+	}
+	return results
+}
+
+// enumerateVersion first checks the target supports the version by offering every cipher of the version. If
+// it does, the cipher it chose is removed from the offer and the handshake repeated until it accepts none of
+// those left, finding the ciphers it accepts in the order it prefers them. Go does not allow the tls 1.3
+// ciphers to be offered selectively, so only the one the target chooses is found for tls 1.3. Returns a
+// single failed result if the version is not supported.
+func (e *enumeration) enumerateVersion(ctx context.Context, version uint16) []*ScanResult {
+	offered := versionCiphers[version]
+	result := e.handshake(ctx, version, offered)
+	if result.Failed {
+		return []*ScanResult{result}
+	}
+
+	accepted := []*ScanResult{result}
+	for version != tls.VersionTLS13 && ctx.Err() == nil {
+		offered = without(offered, result.State.CipherSuite)
+		if len(offered) == 0 {
+			break
+		}
+		result = e.handshake(ctx, version, offered)
+		if result.Failed {
+			// the target accepts none of the remaining ciphers
+			break
+		}
+		accepted = append(accepted, result)
+	}
+	slog.Debug("enumerated ciphers of target", "target", e.target.Name, "address", e.target.Address.String(), "version", tls.VersionName(version), "ciphers", len(accepted))
+	return accepted
+}
+
+// handshake connects to the target and attempts a handshake at the given version, offering the given ciphers
+func (e *enumeration) handshake(ctx context.Context, version uint16, ciphers []uint16) *ScanResult {
+	e.handshakes.Add(1)
+	result := NewScanResult()
+	state, err := e.retrieval.makeConnectionWithConfig(ctx, result, e.target, getConfig(e.target, ciphers, version), e.negotiate, e.clientCert)
+	var cipher *tls.CipherSuite
+	if state != nil {
+		cipher = cipherSuites[state.CipherSuite]
+	}
+	result.SetState(state, cipher, err)
+	return result
+}
+
+func without(ciphers []uint16, cipher uint16) []uint16 {
+	remaining := make([]uint16, 0, len(ciphers))
+	for _, c := range ciphers {
+		if c != cipher {
+			remaining = append(remaining, c)
+		}
+	}
+	return remaining
+}
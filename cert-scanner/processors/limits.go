@@ -0,0 +1,25 @@
+package processors
+
+import (
+	"strconv"
+
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+)
+
+// limit returns the semaphore limiting the connections made to the server the target dials to max_connections
+// at once. A server is often dialled by more than one target, such as the resolved ip and sni probe targets of
+// a host, so the semaphore is shared by every target that dials the same address in the same network through
+// the same proxy.
+func (c *TLSStateRetrieval) limit(target *Target) chan struct{} {
+	key := target.Network + "|" + strconv.Itoa(proxy.Select(c.proxies, target.Source)) + "|" + target.Address.DialAddress()
+
+	c.limitsLock.Lock()
+	defer c.limitsLock.Unlock()
+	limit, found := c.limits[key]
+	if !found {
+		limit = make(chan struct{}, c.maxConnections)
+		c.limits[key] = limit
+	}
+	return limit
+}
@@ -6,7 +6,10 @@ import (
 	. "github.com/sgargan/cert-scanner-darkly/types"
 )
 
-var factories = map[string]Factory[Processor]{"tls-state": CreateTLSStateRetrieval}
+var factories = map[string]Factory[Processor]{
+	"tls-state":    CreateTLSStateRetrieval,
+	"static-certs": CreateStaticCertificateRetrieval,
+}
 
 func CreateProcessors() (Processors, error) {
 	return config.CreateConfigured[Processor]("processors", factories)
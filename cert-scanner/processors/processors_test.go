@@ -14,12 +14,13 @@ type ProcessorsTests struct {
 
 func (t *ProcessorsTests) TestProcessorsOnlyAppliedIfEnabled() {
 	viper.Set("processors.tls-state.enabled", false)
-	for x, processors := range []string{"tls-state"} {
+	viper.Set("processors.static-certs.enabled", false)
+	for x, processors := range []string{"tls-state", "static-certs"} {
 		t.assertProcessors(x)
 		viper.Set(fmt.Sprintf("processors.%s.enabled", processors), true)
 		t.assertProcessors(x + 1)
 	}
-	t.assertProcessors(1)
+	t.assertProcessors(2)
 }
 
 func (t *ProcessorsTests) assertProcessors(expected int) {
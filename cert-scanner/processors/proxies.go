@@ -0,0 +1,32 @@
+package processors
+
+import (
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+)
+
+// loadProxies creates a dialer for each configured proxy. Returns an error if a proxy is misconfigured.
+func loadProxies() ([]proxy.Config, []Dialer, error) {
+	proxies, err := proxy.LoadConfigs()
+	if err != nil {
+		return nil, nil, err
+	}
+	dialers := make([]Dialer, 0, len(proxies))
+	for _, p := range proxies {
+		dialer, err := proxy.CreateDialer(p, DefaultDialer)
+		if err != nil {
+			return nil, nil, err
+		}
+		dialers = append(dialers, dialer)
+	}
+	return proxies, dialers, nil
+}
+
+// dialer returns the dialer of the first configured proxy for the source of the target, or the default
+// dialer if it is not dialled through a proxy
+func (c *TLSStateRetrieval) dialer(target *Target) Dialer {
+	if i := proxy.Select(c.proxies, target.Source); i >= 0 {
+		return c.dialers[i]
+	}
+	return DefaultDialer
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+)
+
+// HTTPConnectDialer connects to addresses through an http proxy, asking it to open a tunnel to each with a
+// CONNECT request
+type HTTPConnectDialer struct {
+	proxy    string
+	username string
+	password string
+	forward  Dialer
+}
+
+// CreateHTTPConnectDialer creates a dialer that tunnels through the proxy at the given address, authenticating
+// with basic auth if a username is given
+func CreateHTTPConnectDialer(proxy, username, password string, forward Dialer) *HTTPConnectDialer {
+	return &HTTPConnectDialer{
+		proxy:    proxy,
+		username: username,
+		password: password,
+		forward:  forward,
+	}
+}
+
+func (d *HTTPConnectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxy)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to proxy %s: %v", d.proxy, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if d.username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.username + ":" + d.password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error sending connect to proxy %s: %v", d.proxy, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reading connect response from proxy %s: %v", d.proxy, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", d.proxy, address, response.Status)
+	}
+
+	// anything the proxy sent after its response is from the target
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
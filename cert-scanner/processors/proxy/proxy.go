@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"golang.org/x/exp/slices"
+	netproxy "golang.org/x/net/proxy"
+)
+
+// Supported proxy schemes
+const (
+	HTTP   = "http"
+	SOCKS5 = "socks5"
+)
+
+// defaultPorts are the ports of proxies whose url does not give one
+var defaultPorts = map[string]string{
+	HTTP:   "3128",
+	SOCKS5: "1080",
+}
+
+// Config is a forward proxy that targets discovered by the given sources are dialled through, either an http
+// proxy supporting CONNECT or a socks5 proxy. A proxy without sources is used for every target. Credentials
+// can be given in the url, or as a username with a password or password file.
+type Config struct {
+	URL          string   `mapstructure:"url"`
+	Username     string   `mapstructure:"username"`
+	Password     string   `mapstructure:"password"`
+	PasswordFile string   `mapstructure:"password_file"`
+	Sources      []string `mapstructure:"sources"`
+}
+
+// LoadConfigs reads the configured proxies. Returns an error if they cannot be parsed.
+func LoadConfigs() ([]Config, error) {
+	var proxies []Config
+	if err := viper.UnmarshalKey(config.ProcessorsTlsProxies, &proxies); err != nil {
+		return nil, fmt.Errorf("error parsing proxies: %v", err)
+	}
+	return proxies, nil
+}
+
+// Select returns the index of the first of the proxies that targets of the given source are dialled through,
+// or -1 if they are dialled directly
+func Select(proxies []Config, source string) int {
+	for i, p := range proxies {
+		if len(p.Sources) == 0 || slices.Contains(p.Sources, source) {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateDialer creates a dialer that connects to addresses through the proxy with the given config, using the
+// forward dialer to connect to the proxy itself. Returns an error if the url is invalid, its scheme is not
+// supported or its password file cannot be read.
+func CreateDialer(config Config, forward Dialer) (Dialer, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy %s is not a valid url", config.URL)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), defaultPorts[u.Scheme])
+	}
+
+	username, password := config.Username, config.Password
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	if config.PasswordFile != "" {
+		contents, err := os.ReadFile(config.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading password file for proxy %s: %v", u.Redacted(), err)
+		}
+		password = strings.TrimSpace(string(contents))
+	}
+
+	switch u.Scheme {
+	case HTTP:
+		return CreateHTTPConnectDialer(address, username, password, forward), nil
+	case SOCKS5:
+		var auth *netproxy.Auth
+		if username != "" {
+			auth = &netproxy.Auth{User: username, Password: password}
+		}
+		dialer, err := netproxy.SOCKS5("tcp", address, auth, forwardDialer{forward})
+		if err != nil {
+			return nil, fmt.Errorf("error creating socks5 dialer for proxy %s: %v", u.Redacted(), err)
+		}
+		return dialer.(netproxy.ContextDialer), nil
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s for proxy %s, expected %s or %s", u.Scheme, u.Redacted(), HTTP, SOCKS5)
+	}
+}
+
+// forwardDialer adapts a Dialer to the dialer golang.org/x/net/proxy connects to proxies with, which
+// uses its DialContext
+type forwardDialer struct {
+	Dialer
+}
+
+func (f forwardDialer) Dial(network, address string) (net.Conn, error) {
+	return f.DialContext(context.Background(), network, address)
+}
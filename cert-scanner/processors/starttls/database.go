@@ -0,0 +1,109 @@
+package starttls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	postgresSSLRequestCode = 80877103
+
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+	mysqlMaxPacketSize          = 1 << 24
+	mysqlCharsetUTF8            = 33
+	mysqlErrorPacket            = 0xff
+)
+
+// negotiatePostgres upgrades a PostgreSQL connection by sending an SSLRequest before the startup message
+func negotiatePostgres(conn net.Conn, serverName string) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return fmt.Errorf("error reading postgres ssl response: %v", err)
+	}
+	if response[0] != 'S' {
+		return fmt.Errorf("postgres server refused ssl with response %q", response[0])
+	}
+	return nil
+}
+
+// negotiateMySQL upgrades a MySQL connection by answering the server handshake with an SSLRequest packet,
+// if the server advertises ssl in its capabilities
+func negotiateMySQL(conn net.Conn, serverName string) error {
+	sequence, handshake, err := readMySQLPacket(conn)
+	if err != nil {
+		return fmt.Errorf("error reading mysql handshake: %v", err)
+	}
+	if len(handshake) > 0 && handshake[0] == mysqlErrorPacket {
+		return fmt.Errorf("mysql server returned an error: %s", mysqlError(handshake))
+	}
+	capabilities, err := mysqlCapabilities(handshake)
+	if err != nil {
+		return fmt.Errorf("error parsing mysql handshake: %v", err)
+	}
+	if capabilities&mysqlClientSSL == 0 {
+		return fmt.Errorf("mysql server does not support ssl")
+	}
+
+	request := make([]byte, 32)
+	binary.LittleEndian.PutUint32(request[0:4], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(request[4:8], mysqlMaxPacketSize)
+	request[8] = mysqlCharsetUTF8
+	return writeMySQLPacket(conn, sequence+1, request)
+}
+
+// mysqlCapabilities parses the lower capability flags from a protocol 10 handshake, these follow the server
+// version, connection id and first part of the auth plugin data
+func mysqlCapabilities(handshake []byte) (uint32, error) {
+	if len(handshake) == 0 || handshake[0] != 10 {
+		return 0, fmt.Errorf("unsupported protocol version")
+	}
+	end := bytes.IndexByte(handshake[1:], 0)
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated server version")
+	}
+	offset := 1 + end + 1 + 4 + 8 + 1
+	if len(handshake) < offset+2 {
+		return 0, fmt.Errorf("truncated handshake")
+	}
+	return uint32(binary.LittleEndian.Uint16(handshake[offset : offset+2])), nil
+}
+
+func mysqlError(packet []byte) string {
+	// error packets hold a 2 byte code, then a 6 byte sql state marker and state before the message
+	if len(packet) > 9 {
+		return string(packet[9:])
+	}
+	return "unknown error"
+}
+
+func readMySQLPacket(reader io.Reader) (byte, []byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[3], payload, nil
+}
+
+func writeMySQLPacket(conn net.Conn, sequence byte, payload []byte) error {
+	length := len(payload)
+	packet := append([]byte{byte(length), byte(length >> 8), byte(length >> 16), sequence}, payload...)
+	_, err := conn.Write(packet)
+	return err
+}
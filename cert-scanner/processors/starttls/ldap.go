@@ -0,0 +1,123 @@
+package starttls
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+	berSequence       = 0x30
+	berInteger        = 0x02
+	berEnumerated     = 0x0a
+	ldapExtendedReq   = 0x77
+	ldapExtendedResp  = 0x78
+	ldapRequestName   = 0x80
+	ldapResultSuccess = 0
+)
+
+// maxBERLength caps the elements read from the server, as the length is sent before the contents
+const maxBERLength = 64 * 1024
+
+// negotiateLDAP upgrades an LDAP connection with the StartTLS extended operation of RFC 4511
+func negotiateLDAP(conn net.Conn, serverName string) error {
+	if _, err := conn.Write(ldapStartTLSRequest()); err != nil {
+		return err
+	}
+
+	tag, message, err := readBER(conn)
+	if err != nil {
+		return fmt.Errorf("error reading ldap extended response: %v", err)
+	}
+	if tag != berSequence {
+		return fmt.Errorf("unexpected ldap message tag 0x%x", tag)
+	}
+
+	// skip the message id to the extended response, the first element of which is its result code
+	_, _, message, err = nextBER(message)
+	if err != nil {
+		return fmt.Errorf("error parsing ldap message id: %v", err)
+	}
+	tag, response, _, err := nextBER(message)
+	if err != nil {
+		return fmt.Errorf("error parsing ldap extended response: %v", err)
+	}
+	if tag != ldapExtendedResp {
+		return fmt.Errorf("unexpected ldap response tag 0x%x", tag)
+	}
+	tag, code, _, err := nextBER(response)
+	if err != nil || tag != berEnumerated || len(code) != 1 {
+		return fmt.Errorf("error parsing ldap result code")
+	}
+	if code[0] != ldapResultSuccess {
+		return fmt.Errorf("ldap starttls failed with result code %d", code[0])
+	}
+	return nil
+}
+
+func ldapStartTLSRequest() []byte {
+	name := append([]byte{ldapRequestName, byte(len(ldapStartTLSOID))}, ldapStartTLSOID...)
+	request := append([]byte{ldapExtendedReq, byte(len(name))}, name...)
+	messageID := []byte{berInteger, 1, 1}
+	body := append(messageID, request...)
+	return append([]byte{berSequence, byte(len(body))}, body...)
+}
+
+// readBER reads a single BER element from the reader, returning its tag and contents. Returns an error for
+// elements longer than maxBERLength.
+func readBER(reader io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[1])
+	if length&0x80 != 0 {
+		numBytes := length & 0x7f
+		if numBytes == 0 || numBytes > 4 {
+			return 0, nil, fmt.Errorf("unsupported ber length of %d bytes", numBytes)
+		}
+		lengthBytes := make([]byte, numBytes)
+		if _, err := io.ReadFull(reader, lengthBytes); err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxBERLength {
+		return 0, nil, fmt.Errorf("ber element of %d bytes exceeds the maximum of %d", length, maxBERLength)
+	}
+	contents := make([]byte, length)
+	if _, err := io.ReadFull(reader, contents); err != nil {
+		return 0, nil, err
+	}
+	return header[0], contents, nil
+}
+
+// nextBER parses the first BER element in data, returning its tag, its contents and the data after it
+func nextBER(data []byte) (byte, []byte, []byte, error) {
+	if len(data) < 2 {
+		return 0, nil, nil, fmt.Errorf("truncated ber element")
+	}
+	tag := data[0]
+	length := int(data[1])
+	offset := 2
+	if length&0x80 != 0 {
+		numBytes := length & 0x7f
+		if numBytes == 0 || numBytes > 4 || len(data) < offset+numBytes {
+			return 0, nil, nil, fmt.Errorf("invalid ber length")
+		}
+		length = 0
+		for _, b := range data[offset : offset+numBytes] {
+			length = length<<8 | int(b)
+		}
+		offset += numBytes
+	}
+	if len(data) < offset+length {
+		return 0, nil, nil, fmt.Errorf("truncated ber element")
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
@@ -0,0 +1,93 @@
+package starttls
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	SMTP     = "smtp"
+	IMAP     = "imap"
+	POP3     = "pop3"
+	LDAP     = "ldap"
+	FTP      = "ftp"
+	XMPP     = "xmpp"
+	Postgres = "postgres"
+	MySQL    = "mysql"
+
+	// None disables STARTTLS for a port or target that would otherwise negotiate it
+	None = "none"
+
+	// Label can be set on a target to choose the protocol negotiated before its tls handshake
+	Label = "starttls"
+)
+
+// Negotiator runs the plaintext exchange of a protocol that upgrades a connection to tls, leaving the
+// connection ready for the tls handshake. The server name is announced to protocols that expect one.
+type Negotiator func(conn net.Conn, serverName string) error
+
+var negotiators = map[string]Negotiator{
+	SMTP:     negotiateSMTP,
+	IMAP:     negotiateIMAP,
+	POP3:     negotiatePOP3,
+	LDAP:     negotiateLDAP,
+	FTP:      negotiateFTP,
+	XMPP:     negotiateXMPP,
+	Postgres: negotiatePostgres,
+	MySQL:    negotiateMySQL,
+}
+
+// WellKnownPorts are the ports whose protocol is negotiated when neither the target nor its url choose one
+var WellKnownPorts = map[int]string{
+	21:   FTP,
+	25:   SMTP,
+	110:  POP3,
+	143:  IMAP,
+	389:  LDAP,
+	587:  SMTP,
+	3306: MySQL,
+	5222: XMPP,
+	5432: Postgres,
+}
+
+var schemes = map[string]string{
+	"smtp":       SMTP,
+	"submission": SMTP,
+	"imap":       IMAP,
+	"pop3":       POP3,
+	"ldap":       LDAP,
+	"ftp":        FTP,
+	"xmpp":       XMPP,
+	"postgres":   Postgres,
+	"postgresql": Postgres,
+	"mysql":      MySQL,
+}
+
+// GetNegotiator returns the negotiator for the given protocol, or nil for none
+func GetNegotiator(protocol string) (Negotiator, error) {
+	if protocol == "" || protocol == None {
+		return nil, nil
+	}
+	negotiator, found := negotiators[protocol]
+	if !found {
+		return nil, fmt.Errorf("unsupported starttls protocol %s, expected one of %s", protocol, strings.Join(Protocols(), ", "))
+	}
+	return negotiator, nil
+}
+
+// ForScheme returns the protocol negotiated for urls with the given scheme, or an empty string if there is none
+func ForScheme(scheme string) string {
+	return schemes[strings.ToLower(scheme)]
+}
+
+// Protocols returns the supported protocols
+func Protocols() []string {
+	protocols := make([]string, 0, len(negotiators))
+	for protocol := range negotiators {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
@@ -0,0 +1,274 @@
+package starttls
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/testutils"
+	"github.com/stretchr/testify/suite"
+)
+
+// script plays the server side of a protocol's plaintext exchange, returning true if the connection
+// should be upgraded to tls
+type script func(conn net.Conn, reader *bufio.Reader) bool
+
+type StartTLSTests struct {
+	suite.Suite
+	config *tls.Config
+}
+
+func (t *StartTLSTests) SetupSuite() {
+	ca, err := testutils.CreateTestCA(0)
+	t.NoError(err)
+	_, certPem, key, err := ca.CreateLeafCert("mail.example.com")
+	t.NoError(err)
+	t.config = testutils.CreateTestTLSConfig(tls.VersionTLS12, certPem, key)
+}
+
+func (t *StartTLSTests) TestSMTP() {
+	t.assertUpgrades(SMTP, func(conn net.Conn, reader *bufio.Reader) bool {
+		write(conn, "220-mail.example.com ESMTP\r\n220 ready\r\n")
+		t.Equal("EHLO cert-scanner", read(reader))
+		write(conn, "250-mail.example.com\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+		t.Equal("STARTTLS", read(reader))
+		write(conn, "220 go ahead\r\n")
+		return true
+	})
+
+	t.assertFails(SMTP, func(conn net.Conn, reader *bufio.Reader) bool {
+		write(conn, "220 ready\r\n")
+		read(reader)
+		write(conn, "250 mail.example.com\r\n")
+		read(reader)
+		write(conn, "454 TLS not available\r\n")
+		return false
+	}, `error sending smtp starttls: unexpected response "454 TLS not available"`)
+}
+
+func (t *StartTLSTests) TestIMAP() {
+	t.assertUpgrades(IMAP, func(conn net.Conn, reader *bufio.Reader) bool {
+		write(conn, "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n")
+		t.Equal("a001 STARTTLS", read(reader))
+		write(conn, "* BYE not really\r\na001 OK begin tls\r\n")
+		return true
+	})
+
+	t.assertFails(IMAP, func(conn net.Conn, reader *bufio.Reader) bool {
+		write(conn, "* OK ready\r\n")
+		read(reader)
+		write(conn, "a001 BAD unknown command\r\n")
+		return false
+	}, `error sending imap starttls: unexpected response "a001 BAD unknown command"`)
+}
+
+func (t *StartTLSTests) TestPOP3() {
+	t.assertUpgrades(POP3, func(conn net.Conn, reader *bufio.Reader) bool {
+		write(conn, "+OK POP3 ready\r\n")
+		t.Equal("STLS", read(reader))
+		write(conn, "+OK begin tls\r\n")
+		return true
+	})
+}
+
+func (t *StartTLSTests) TestFTP() {
+	t.assertUpgrades(FTP, func(conn net.Conn, reader *bufio.Reader) bool {
+		write(conn, "220 FTP ready\r\n")
+		t.Equal("AUTH TLS", read(reader))
+		write(conn, "234 AUTH TLS ok\r\n")
+		return true
+	})
+}
+
+func (t *StartTLSTests) TestLDAP() {
+	t.assertUpgrades(LDAP, func(conn net.Conn, reader *bufio.Reader) bool {
+		tag, request, err := readBER(reader)
+		t.NoError(err)
+		t.Equal(byte(berSequence), tag)
+		t.Contains(string(request), ldapStartTLSOID)
+		conn.Write(ldapResponse(0))
+		return true
+	})
+
+	t.assertFails(LDAP, func(conn net.Conn, reader *bufio.Reader) bool {
+		readBER(reader)
+		conn.Write(ldapResponse(2))
+		return false
+	}, "ldap starttls failed with result code 2")
+
+	t.assertFails(LDAP, func(conn net.Conn, reader *bufio.Reader) bool {
+		readBER(reader)
+		conn.Write([]byte{berSequence, 0x84, 0x7f, 0xff, 0xff, 0xff})
+		return false
+	}, "ber element of 2147483647 bytes exceeds the maximum of 65536")
+}
+
+func (t *StartTLSTests) TestXMPP() {
+	t.assertUpgrades(XMPP, func(conn net.Conn, reader *bufio.Reader) bool {
+		stream, err := reader.ReadString('>')
+		t.NoError(err)
+		t.Contains(stream, "<?xml")
+		stream, err = reader.ReadString('>')
+		t.NoError(err)
+		t.Contains(stream, "to='mail.example.com'")
+		write(conn, "<?xml version='1.0'?><stream:stream from='mail.example.com' xmlns='jabber:client' "+
+			"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'><stream:features>"+
+			"<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls></stream:features>")
+		request, err := reader.ReadString('>')
+		t.NoError(err)
+		t.Equal("<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>", request)
+		write(conn, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+		return true
+	})
+
+	t.assertFails(XMPP, func(conn net.Conn, reader *bufio.Reader) bool {
+		reader.ReadString('>')
+		reader.ReadString('>')
+		write(conn, "<stream:stream><stream:features><mechanisms/></stream:features>")
+		return false
+	}, "xmpp server does not offer starttls")
+}
+
+func (t *StartTLSTests) TestPostgres() {
+	t.assertUpgrades(Postgres, func(conn net.Conn, reader *bufio.Reader) bool {
+		request := make([]byte, 8)
+		_, err := io.ReadFull(reader, request)
+		t.NoError(err)
+		t.Equal(uint32(8), binary.BigEndian.Uint32(request[0:4]))
+		t.Equal(uint32(postgresSSLRequestCode), binary.BigEndian.Uint32(request[4:8]))
+		write(conn, "S")
+		return true
+	})
+
+	t.assertFails(Postgres, func(conn net.Conn, reader *bufio.Reader) bool {
+		io.ReadFull(reader, make([]byte, 8))
+		write(conn, "N")
+		return false
+	}, `postgres server refused ssl with response 'N'`)
+}
+
+func (t *StartTLSTests) TestMySQL() {
+	t.assertUpgrades(MySQL, func(conn net.Conn, reader *bufio.Reader) bool {
+		writeMySQLPacket(conn, 0, mysqlHandshake(mysqlClientSSL|mysqlClientProtocol41))
+		sequence, request, err := readMySQLPacket(reader)
+		t.NoError(err)
+		t.Equal(byte(1), sequence)
+		t.Len(request, 32)
+		t.NotZero(binary.LittleEndian.Uint32(request[0:4]) & mysqlClientSSL)
+		return true
+	})
+
+	t.assertFails(MySQL, func(conn net.Conn, reader *bufio.Reader) bool {
+		writeMySQLPacket(conn, 0, mysqlHandshake(mysqlClientProtocol41))
+		return false
+	}, "mysql server does not support ssl")
+}
+
+func (t *StartTLSTests) TestChoosesProtocols() {
+	negotiator, err := GetNegotiator(None)
+	t.NoError(err)
+	t.Nil(negotiator)
+
+	_, err = GetNegotiator("gopher")
+	t.ErrorContains(err, "unsupported starttls protocol gopher, expected one of ftp, imap, ldap, mysql, pop3, postgres, smtp, xmpp")
+
+	t.Equal(Postgres, ForScheme("postgresql"))
+	t.Equal(SMTP, ForScheme("submission"))
+	t.Empty(ForScheme("https"))
+}
+
+// assertUpgrades runs the negotiator for the protocol against a server playing the script, then checks a tls
+// handshake can be completed over the upgraded connection
+func (t *StartTLSTests) assertUpgrades(protocol string, server script) {
+	conn := t.serve(server)
+	defer conn.Close()
+
+	t.NoError(t.negotiate(protocol, conn))
+	client := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if t.NoError(client.Handshake()) {
+		t.Equal("mail.example.com", client.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	}
+}
+
+func (t *StartTLSTests) assertFails(protocol string, server script, expected string) {
+	conn := t.serve(server)
+	defer conn.Close()
+	t.ErrorContains(t.negotiate(protocol, conn), expected)
+}
+
+func (t *StartTLSTests) negotiate(protocol string, conn net.Conn) error {
+	negotiator, err := GetNegotiator(protocol)
+	t.NoError(err)
+	return negotiator(conn, "mail.example.com")
+}
+
+// serve starts a server that plays the script to a single connection, returning the client connection to it
+func (t *StartTLSTests) serve(server script) net.Conn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	t.NoError(err)
+
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(conn)
+		if server(conn, reader) {
+			// the client hello may already be buffered by the reader
+			tls.Server(&bufferedConn{Conn: conn, reader: reader}, t.config).Handshake()
+		} else {
+			// hold the connection open until the client is done with it
+			io.Copy(io.Discard, conn)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	t.NoError(err)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func write(conn net.Conn, data string) {
+	conn.Write([]byte(data))
+}
+
+func read(reader *bufio.Reader) string {
+	line, _ := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func ldapResponse(code byte) []byte {
+	// LDAPMessage { messageID 1, extendedResp { resultCode, matchedDN "", diagnosticMessage "" } }
+	response := []byte{ldapExtendedResp, 7, berEnumerated, 1, code, 0x04, 0, 0x04, 0}
+	body := append([]byte{berInteger, 1, 1}, response...)
+	return append([]byte{berSequence, byte(len(body))}, body...)
+}
+
+func mysqlHandshake(capabilities uint16) []byte {
+	handshake := append([]byte{10}, "8.0.36\x00"...)
+	handshake = append(handshake, 1, 0, 0, 0)
+	handshake = append(handshake, "abcdefgh"...)
+	handshake = append(handshake, 0)
+	return binary.LittleEndian.AppendUint16(handshake, capabilities)
+}
+
+func TestStartTLS(t *testing.T) {
+	suite.Run(t, &StartTLSTests{})
+}
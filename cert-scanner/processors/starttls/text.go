@@ -0,0 +1,127 @@
+package starttls
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ClientName is announced to servers that ask the client to identify itself, e.g. in an SMTP EHLO
+const ClientName = "cert-scanner"
+
+// negotiateSMTP upgrades an SMTP connection as in RFC 3207, announcing the client with EHLO before
+// requesting STARTTLS
+func negotiateSMTP(conn net.Conn, serverName string) error {
+	reader := bufio.NewReader(conn)
+	if err := expectReply(reader, "220"); err != nil {
+		return fmt.Errorf("error reading smtp greeting: %v", err)
+	}
+	if err := command(conn, reader, "EHLO "+ClientName, "250"); err != nil {
+		return fmt.Errorf("error sending smtp ehlo: %v", err)
+	}
+	if err := command(conn, reader, "STARTTLS", "220"); err != nil {
+		return fmt.Errorf("error sending smtp starttls: %v", err)
+	}
+	return nil
+}
+
+// negotiateFTP upgrades an FTP control connection as in RFC 4217
+func negotiateFTP(conn net.Conn, serverName string) error {
+	reader := bufio.NewReader(conn)
+	if err := expectReply(reader, "220"); err != nil {
+		return fmt.Errorf("error reading ftp greeting: %v", err)
+	}
+	if err := command(conn, reader, "AUTH TLS", "234"); err != nil {
+		return fmt.Errorf("error sending ftp auth tls: %v", err)
+	}
+	return nil
+}
+
+// negotiatePOP3 upgrades a POP3 connection as in RFC 2595
+func negotiatePOP3(conn net.Conn, serverName string) error {
+	reader := bufio.NewReader(conn)
+	if err := expectLine(reader, "+OK"); err != nil {
+		return fmt.Errorf("error reading pop3 greeting: %v", err)
+	}
+	if err := writeLine(conn, "STLS"); err != nil {
+		return err
+	}
+	if err := expectLine(reader, "+OK"); err != nil {
+		return fmt.Errorf("error sending pop3 stls: %v", err)
+	}
+	return nil
+}
+
+// negotiateIMAP upgrades an IMAP connection as in RFC 2595, skipping any untagged responses to STARTTLS
+func negotiateIMAP(conn net.Conn, serverName string) error {
+	reader := bufio.NewReader(conn)
+	if err := expectLine(reader, "* OK"); err != nil {
+		return fmt.Errorf("error reading imap greeting: %v", err)
+	}
+	if err := writeLine(conn, "a001 STARTTLS"); err != nil {
+		return err
+	}
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return fmt.Errorf("error sending imap starttls: %v", err)
+		}
+		if strings.HasPrefix(line, "* ") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a001 OK") {
+			return fmt.Errorf("error sending imap starttls: unexpected response %q", line)
+		}
+		return nil
+	}
+}
+
+// command sends a command and expects a reply with the given code
+func command(conn net.Conn, reader *bufio.Reader, command, code string) error {
+	if err := writeLine(conn, command); err != nil {
+		return err
+	}
+	return expectReply(reader, code)
+}
+
+// expectReply reads a reply of one or more lines, as sent by SMTP and FTP servers, and returns an error if
+// its code is not the one expected. Each line of a multiline reply but the last has a dash after the code.
+func expectReply(reader *bufio.Reader, code string) error {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if len(line) < 3 || line[:3] != code {
+			return fmt.Errorf("unexpected response %q", line)
+		}
+		if len(line) == 3 || line[3] != '-' {
+			return nil
+		}
+	}
+}
+
+func expectLine(reader *bufio.Reader, prefix string) error {
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, prefix) {
+		return fmt.Errorf("unexpected response %q", line)
+	}
+	return nil
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeLine(conn net.Conn, line string) error {
+	_, err := conn.Write([]byte(line + "\r\n"))
+	return err
+}
@@ -0,0 +1,59 @@
+package starttls
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+const maxXMPPResponse = 64 * 1024
+
+// negotiateXMPP upgrades an XMPP client stream as in RFC 6120, opening a stream to the server name and
+// requesting STARTTLS once the server has advertised its stream features
+func negotiateXMPP(conn net.Conn, serverName string) error {
+	stream := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' "+
+		"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", serverName)
+	if _, err := conn.Write([]byte(stream)); err != nil {
+		return err
+	}
+	features, err := readUntil(conn, "</stream:features>", "</stream:stream>")
+	if err != nil {
+		return fmt.Errorf("error reading xmpp stream features: %v", err)
+	}
+	if !bytes.Contains(features, []byte("<starttls")) {
+		return fmt.Errorf("xmpp server does not offer starttls")
+	}
+
+	if _, err := conn.Write([]byte("<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")); err != nil {
+		return err
+	}
+	response, err := readUntil(conn, "<proceed", "<failure")
+	if err != nil {
+		return fmt.Errorf("error reading xmpp starttls response: %v", err)
+	}
+	if !bytes.Contains(response, []byte("<proceed")) {
+		return fmt.Errorf("xmpp server refused starttls")
+	}
+	// the rest of the proceed element precedes the tls handshake
+	_, err = readUntil(conn, ">")
+	return err
+}
+
+// readUntil reads from the connection until the data read contains one of the given markers. The
+// connection is read a byte at a time so nothing after the marker is consumed.
+func readUntil(conn net.Conn, markers ...string) ([]byte, error) {
+	read := make([]byte, 0, 1024)
+	buf := make([]byte, 1)
+	for len(read) < maxXMPPResponse {
+		if _, err := conn.Read(buf); err != nil {
+			return read, err
+		}
+		read = append(read, buf[0])
+		for _, marker := range markers {
+			if bytes.HasSuffix(read, []byte(marker)) {
+				return read, nil
+			}
+		}
+	}
+	return read, fmt.Errorf("no response after %d bytes", maxXMPPResponse)
+}
@@ -0,0 +1,29 @@
+package processors
+
+import (
+	"context"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+)
+
+type StaticCertificateRetrieval struct{}
+
+// CreateStaticCertificateRetrieval creates a processor for targets with a [StaticAddress]. These hold
+// certificates that were read directly during discovery, e.g. from kubernetes secrets, so the processor
+// wraps the certificates in a static result for validation rather than connecting to the target.
+func CreateStaticCertificateRetrieval() (Processor, error) {
+	return &StaticCertificateRetrieval{}, nil
+}
+
+func (c *StaticCertificateRetrieval) Process(ctx context.Context, target *Target, results chan<- *TargetScan) {
+	address, static := target.Address.(*StaticAddress)
+	if !static {
+		return
+	}
+
+	slog.Debug("processing static certificates", "target", target.Name, "address", address.String(), "certificates", len(address.Certificates()))
+	targetScan := NewTargetScanResult(target)
+	targetScan.Add(NewStaticScanResult(address.Certificates()))
+	results <- targetScan
+}
@@ -0,0 +1,56 @@
+package processors
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/testutils"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type StaticCertificateTests struct {
+	suite.Suite
+}
+
+func (t *StaticCertificateTests) TestProcessesStaticAddresses() {
+	ca, err := testutils.CreateTestCA(2)
+	t.NoError(err)
+	cert, _, _, err := ca.CreateLeafCert("somehost")
+	t.NoError(err)
+
+	target := &Target{Address: CreateStaticAddress("secret/some-namespace/some-secret", []*x509.Certificate{cert})}
+	results := t.process(target)
+	t.Equal(1, len(results))
+
+	scan := <-results
+	t.False(scan.Failed())
+	t.True(scan.FirstSuccessful.Static)
+	t.Equal([]*x509.Certificate{cert}, scan.FirstSuccessful.State.PeerCertificates)
+}
+
+func (t *StaticCertificateTests) TestIgnoresNetworkAddresses() {
+	results := t.process(&Target{Address: getAddress("127.0.0.1:33333")})
+	t.Equal(0, len(results))
+}
+
+func (t *StaticCertificateTests) TestTLSStateIgnoresStaticAddresses() {
+	processor, err := CreateTLSStateRetrieval()
+	t.NoError(err)
+	results := make(chan *TargetScan, 1)
+	processor.Process(context.Background(), &Target{Address: CreateStaticAddress("secret/some-namespace/some-secret", nil)}, results)
+	t.Equal(0, len(results))
+}
+
+func (t *StaticCertificateTests) process(target *Target) chan *TargetScan {
+	processor, err := CreateStaticCertificateRetrieval()
+	t.NoError(err)
+	results := make(chan *TargetScan, 1)
+	processor.Process(context.Background(), target, results)
+	return results
+}
+
+func TestStaticCertificates(t *testing.T) {
+	suite.Run(t, &StaticCertificateTests{})
+}
@@ -3,54 +3,134 @@ package processors
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
+	"net"
 	"sort"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/spf13/viper"
 	"golang.org/x/exp/slog"
 
+	"github.com/sgargan/cert-scanner-darkly/config"
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	"github.com/sgargan/cert-scanner-darkly/processors/starttls"
 	. "github.com/sgargan/cert-scanner-darkly/types"
-	"github.com/sgargan/cert-scanner-darkly/utils"
 	"golang.org/x/exp/slices"
 )
 
+// DefaultMaxConnections is the number of connections made to an address at once when none is configured
+const DefaultMaxConnections = 2
+
 var orderedCipherSuites []*tls.CipherSuite
 
-type TLSStateRetrieval struct{}
+// cipherSuites indexes the known cipher suites by id
+var cipherSuites map[uint16]*tls.CipherSuite
+
+// tlsVersions are the versions supported by the known cipher suites, lowest first
+var tlsVersions []uint16
+
+// versionCiphers are the ids of the cipher suites supporting each version
+var versionCiphers map[uint16][]uint16
+
+type TLSStateRetrieval struct {
+	starttlsPorts  map[int]string
+	clientCerts    []*ClientCertificate
+	proxies        []proxy.Config
+	dialers        []Dialer
+	maxConnections int
+	limitsLock     sync.Mutex
+	limits         map[string]chan struct{}
+}
 
 func init() {
 	orderedCipherSuites = sortCiphers()
+	cipherSuites = make(map[uint16]*tls.CipherSuite)
+	versionCiphers = make(map[uint16][]uint16)
+	for _, cipher := range orderedCipherSuites {
+		cipherSuites[cipher.ID] = cipher
+		for _, version := range cipher.SupportedVersions {
+			if _, seen := versionCiphers[version]; !seen {
+				tlsVersions = append(tlsVersions, version)
+			}
+			versionCiphers[version] = append(versionCiphers[version], cipher.ID)
+		}
+	}
+	slices.Sort(tlsVersions)
 }
 
 // CreateTLSStateRetrieval creates a scanner to retrieve TLS state information from Targets.
 // This is the main connection logic for the scanner and will use the configured cipher suites and
 // versions to attempt to connect to the discovered targets. The results of each target scan are aggregated
-// to report on after the scan is complete.
+// to report on after the scan is complete. The versions a target supports are found first, then the ciphers it
+// accepts for each by removing the one it chose from those offered until it accepts none, making a handshake per
+// accepted cipher rather than one per cipher and version. At most the configured max_connections are made to an
+// address at once, however many targets dial it.
 func CreateTLSStateRetrieval() (Processor, error) {
-	return &TLSStateRetrieval{}, nil
+	starttlsPorts := make(map[int]string)
+	for port, protocol := range starttls.WellKnownPorts {
+		starttlsPorts[port] = protocol
+	}
+	for key, protocol := range viper.GetStringMapString(config.ProcessorsTlsStartTLSPorts) {
+		port, err := strconv.Atoi(key)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid starttls port %s", key)
+		}
+		if _, err := starttls.GetNegotiator(protocol); err != nil {
+			return nil, fmt.Errorf("error configuring starttls for port %d: %v", port, err)
+		}
+		starttlsPorts[port] = protocol
+	}
+	clientCerts, err := loadClientCertificates()
+	if err != nil {
+		return nil, err
+	}
+	proxies, dialers, err := loadProxies()
+	if err != nil {
+		return nil, err
+	}
+	maxConnections := DefaultMaxConnections
+	if viper.IsSet(config.ProcessorsTlsMaxConnections) {
+		maxConnections = viper.GetInt(config.ProcessorsTlsMaxConnections)
+		if maxConnections <= 0 {
+			return nil, fmt.Errorf("invalid max_connections %d, must be at least 1", maxConnections)
+		}
+	}
+	return &TLSStateRetrieval{starttlsPorts: starttlsPorts, clientCerts: clientCerts, proxies: proxies, dialers: dialers, maxConnections: maxConnections, limits: make(map[string]chan struct{})}, nil
 }
 
 func (c *TLSStateRetrieval) Process(ctx context.Context, target *Target, results chan<- *TargetScan) {
-	// try each cipher from least to most secure until we fail to connect
-	wait := &utils.ContextualWaitGroup{}
+	// static certificates are handled by the StaticCertificateRetrieval processor
+	if _, static := target.Address.(*StaticAddress); static {
+		return
+	}
+
 	targetScan := NewTargetScanResult(target)
 
+	negotiate, err := c.negotiator(target)
+	if err != nil {
+		slog.Error("error choosing starttls protocol for target", "address", target.Address.String(), "err", err.Error())
+		result := NewScanResult()
+		result.SetState(nil, nil, CreateGenericError(StartTLSError, err, result))
+		targetScan.Add(result)
+		results <- targetScan
+		return
+	}
+
+	e := &enumeration{
+		retrieval:  c,
+		target:     target,
+		negotiate:  negotiate,
+		clientCert: c.clientCertificate(target),
+	}
 	hasConnectionError := false
-	for _, x := range orderedCipherSuites {
-		cipher := x
-		wait.Add(len(cipher.SupportedVersions))
-		for _, v := range cipher.SupportedVersions {
-			version := v
-			go func() {
-				defer wait.Done()
-				result := NewScanResult()
-				state, err := c.makeConnectionWithConfig(ctx, result, target, getConfig(target, cipher.ID, version))
-				result.SetState(state, cipher, err)
-				hasConnectionError = hasConnectionError || IsError(err, ConnectionError)
-				targetScan.Add(result)
-			}()
-		}
+	for _, result := range e.enumerate(ctx) {
+		hasConnectionError = hasConnectionError || IsError(result.Error, ConnectionError)
+		targetScan.Add(result)
 	}
-	wait.WaitWithContext(ctx)
+	targetScan.Handshakes = int(e.handshakes.Load())
+	slog.Debug("finished enumerating target", "target", target.Name, "address", target.Address.String(), "results", len(targetScan.Results), "handshakes", targetScan.Handshakes)
 
 	if hasConnectionError {
 		slog.Error("error making connection to target", "address", target.Address.String())
@@ -58,43 +138,104 @@ func (c *TLSStateRetrieval) Process(ctx context.Context, target *Target, results
 	results <- targetScan
 }
 
-func (c *TLSStateRetrieval) makeConnectionWithConfig(ctx context.Context, result *ScanResult, target *Target, config *tls.Config) (*tls.ConnectionState, ScanError) {
-	slog.Debug("connecting to target", "target", target.Name, "address", target.Address.String(), "cipher", tls.CipherSuiteName(config.CipherSuites[0]), "version", tls.VersionName(config.MaxVersion))
+// negotiator returns the STARTTLS negotiator for the target, or nil if the tls handshake starts as soon as
+// it is connected to. The protocol is chosen by the starttls label of the target, then the scheme of its url,
+// then the port it dials. Https urls always start with the handshake.
+func (c *TLSStateRetrieval) negotiator(target *Target) (starttls.Negotiator, error) {
+	if protocol, labelled := target.Metadata.Labels[starttls.Label]; labelled {
+		return starttls.GetNegotiator(protocol)
+	}
+	if address, isUrl := target.Address.(*UrlAddress); isUrl {
+		if protocol := starttls.ForScheme(address.Scheme()); protocol != "" {
+			return starttls.GetNegotiator(protocol)
+		}
+		if address.Scheme() == "https" {
+			return nil, nil
+		}
+	}
+	if _, port, err := net.SplitHostPort(target.Address.DialAddress()); err == nil {
+		if p, err := strconv.Atoi(port); err == nil {
+			return starttls.GetNegotiator(c.starttlsPorts[p])
+		}
+	}
+	return nil, nil
+}
+
+// makeConnectionWithConfig connects to the target and attempts a handshake with the given config. If the
+// target requests a client certificate the request is recorded in the result, and the given client
+// certificate is presented. When there is none to present, a target that fails the handshake after sending
+// its own certificates is not treated as failed, as it only refused the client.
+func (c *TLSStateRetrieval) makeConnectionWithConfig(ctx context.Context, result *ScanResult, target *Target, config *tls.Config, negotiate starttls.Negotiator, clientCert *tls.Certificate) (*tls.ConnectionState, ScanError) {
+	slog.Debug("connecting to target", "target", target.Name, "address", target.Address.String(), "ciphers", len(config.CipherSuites), "version", tls.VersionName(config.MaxVersion))
 
 	// Create a timeout context for both connect and handshake
 	handshakeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	rawConn, err := target.Address.Connect(handshakeCtx)
+	rawConn, err := target.Address.Connect(handshakeCtx, c.dialer(target))
 	if err != nil {
 		return nil, CreateGenericError(ConnectionError, err, result)
 	}
 	defer rawConn.Close()
 
+	// protocols that upgrade to tls need their plaintext exchange before the handshake
+	if negotiate != nil {
+		if deadline, ok := handshakeCtx.Deadline(); ok {
+			rawConn.SetDeadline(deadline)
+		}
+		serverName := target.Address.ServerName()
+		if serverName == "" {
+			serverName, _, _ = net.SplitHostPort(target.Address.DialAddress())
+		}
+		if err := negotiate(rawConn, serverName); err != nil {
+			return nil, CreateGenericError(StartTLSError, err, result)
+		}
+	}
+
+	var verified *tls.ConnectionState
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		verified = &state
+		return nil
+	}
+	config.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		result.CertificateRequest = &CertificateRequest{
+			AcceptableCAs: acceptableCAs(info.AcceptableCAs),
+			Presented:     clientCert != nil,
+		}
+		if clientCert != nil {
+			return clientCert, nil
+		}
+		return &tls.Certificate{}, nil
+	}
+
 	// attempt a handshake with the given config
 	conn := tls.Client(rawConn, config)
 	if err = conn.HandshakeContext(handshakeCtx); err != nil {
+		if result.CertificateRequest != nil && !result.CertificateRequest.Presented && verified != nil {
+			slog.Debug("target requires a client certificate", "target", target.Name, "address", target.Address.String())
+			return verified, nil
+		}
 		return nil, &TLSConnectionError{
-			config: *config,
-			error:  err,
+			version: config.MaxVersion,
+			error:   err,
 		}
 	}
 	state := conn.ConnectionState()
 	return &state, nil
 }
 
-func getConfig(target *Target, cipher, version uint16) *tls.Config {
+func getConfig(target *Target, ciphers []uint16, version uint16) *tls.Config {
 	config := &tls.Config{
-		CipherSuites: []uint16{cipher},
+		CipherSuites: ciphers,
 		MaxVersion:   version,
 		MinVersion:   version,
 	}
 
-	if target.Address.ValidateHostname() {
-		config.ServerName = target.Address.String()
-	} else {
-		config.InsecureSkipVerify = true
-	}
+	// the certificate of an ip target is retrieved whoever issued it, so the server name it validates is
+	// verified by the trust chain validation along with the rest of the chain
+	_, isIP := target.Address.(*NetIPAddress)
+	config.ServerName = target.Address.ServerName()
+	config.InsecureSkipVerify = isIP || !target.Address.ValidateHostname()
 	return config
 }
 
@@ -108,7 +249,7 @@ func sortCiphers() []*tls.CipherSuite {
 }
 
 type TLSConnectionError struct {
-	config tls.Config
+	version uint16
 	error
 }
 
@@ -118,8 +259,7 @@ func (t *TLSConnectionError) Result() *ScanResult {
 
 func (t *TLSConnectionError) Labels() map[string]string {
 	return map[string]string{
-		"version": tls.VersionName(t.config.MaxVersion),
-		"cipher":  tls.CipherSuiteName(t.config.CipherSuites[0]),
+		"version": tls.VersionName(t.version),
 		"type":    HandshakeError,
 	}
 }
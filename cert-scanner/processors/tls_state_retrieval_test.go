@@ -3,15 +3,27 @@ package processors
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/sgargan/cert-scanner-darkly/config"
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	"github.com/sgargan/cert-scanner-darkly/processors/starttls"
 	"github.com/sgargan/cert-scanner-darkly/testutils"
 	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -63,6 +75,322 @@ func (t *CertScannerTests) TestHandshakeError() {
 	t.ValidateError(results[0], "tls-handshake")
 }
 
+func (t *CertScannerTests) TestConfigValidatesServerName() {
+	config := getConfig(&Target{Address: getAddress("127.0.0.1:443")}, []uint16{tls.TLS_AES_128_GCM_SHA256}, tls.VersionTLS13)
+	t.True(config.InsecureSkipVerify)
+	t.Empty(config.ServerName)
+
+	address := CreateNetIPAddressWithServerName(netip.MustParseAddrPort("127.0.0.1:443"), "some-service.some-namespace.svc")
+	config = getConfig(&Target{Address: address}, []uint16{tls.TLS_AES_128_GCM_SHA256}, tls.VersionTLS13)
+	t.True(config.InsecureSkipVerify)
+	t.Equal("some-service.some-namespace.svc", config.ServerName)
+}
+
+func (t *CertScannerTests) TestChoosesStartTLSProtocol() {
+	viper.Set(config.ProcessorsTlsStartTLSPorts, map[string]string{"2525": "smtp", "25": "none"})
+	defer viper.Reset()
+	processor, err := CreateTLSStateRetrieval()
+	t.NoError(err)
+	retrieval := processor.(*TLSStateRetrieval)
+
+	for address, expected := range map[string]string{
+		"10.0.0.1:587":            starttls.SMTP,
+		"10.0.0.1:2525":           starttls.SMTP,
+		"10.0.0.1:25":             "",
+		"10.0.0.1:443":            "",
+		"postgres://db.internal":  starttls.Postgres,
+		"tls://ldap.internal:389": starttls.LDAP,
+		"https://web.internal:21": "",
+	} {
+		var target *Target
+		if strings.Contains(address, "://") {
+			parsed, err := ParseUrlAddress(address)
+			t.NoError(err)
+			target = &Target{Address: parsed}
+		} else {
+			target = &Target{Address: getAddress(address)}
+		}
+		t.assertNegotiator(retrieval, target, expected)
+	}
+
+	labelled := &Target{Address: getAddress("10.0.0.1:443"), Metadata: Metadata{Labels: Labels{starttls.Label: starttls.IMAP}}}
+	t.assertNegotiator(retrieval, labelled, starttls.IMAP)
+}
+
+func (t *CertScannerTests) TestInvalidStartTLSPorts() {
+	defer viper.Reset()
+	viper.Set(config.ProcessorsTlsStartTLSPorts, map[string]string{"smtp": "smtp"})
+	_, err := CreateTLSStateRetrieval()
+	t.ErrorContains(err, "invalid starttls port smtp")
+
+	viper.Set(config.ProcessorsTlsStartTLSPorts, map[string]string{"2525": "gopher"})
+	_, err = CreateTLSStateRetrieval()
+	t.ErrorContains(err, "error configuring starttls for port 2525: unsupported starttls protocol gopher")
+}
+
+func (t *CertScannerTests) TestScanStartTLSTarget() {
+	ca, err := testutils.CreateTestCA(0)
+	t.NoError(err)
+	_, certPem, key, err := ca.CreateLeafCert("db.internal")
+	t.NoError(err)
+	tlsConfig := testutils.CreateTestTLSConfig(tls.VersionTLS12, certPem, key)
+
+	// a postgres stand in that accepts the ssl request of every connection
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	t.NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := io.ReadFull(conn, make([]byte, 8)); err == nil {
+					conn.Write([]byte("S"))
+					tls.Server(conn, tlsConfig).Handshake()
+				}
+			}()
+		}
+	}()
+
+	target := &Target{
+		Address:  getAddress(listener.Addr().String()),
+		Metadata: Metadata{Labels: Labels{starttls.Label: starttls.Postgres}},
+	}
+	results := t.runScan(target)
+	if t.NotNil(results[0].FirstSuccessful) {
+		t.Equal("db.internal", results[0].FirstSuccessful.State.PeerCertificates[0].Subject.CommonName)
+	}
+}
+
+func (t *CertScannerTests) TestRecordsClientCertificateRequest() {
+	ca, address := t.serveClientAuth()
+	defer viper.Reset()
+
+	results := t.runScan(&Target{Address: getAddress(address)})
+	if t.NotNil(results[0].FirstSuccessful) {
+		result := results[0].FirstSuccessful
+		t.Equal("mesh.internal", result.State.PeerCertificates[0].Subject.CommonName)
+		t.Equal(&CertificateRequest{AcceptableCAs: []string{ca.Root().Cert().Subject.String()}}, result.CertificateRequest)
+		t.Equal(ClientAuthRequested, result.Labels()[ClientAuth])
+	}
+
+	cert, key := t.writeClientCert(ca)
+	viper.Set(config.ProcessorsTlsClientCerts, []map[string]interface{}{
+		{"cert": cert, "key": key, "sources": []string{"mesh"}},
+	})
+	results = t.runScan(&Target{Address: getAddress(address), Metadata: Metadata{Source: "mesh"}})
+	if t.NotNil(results[0].FirstSuccessful) {
+		t.True(results[0].FirstSuccessful.CertificateRequest.Presented)
+		t.Equal(ClientAuthPresented, results[0].FirstSuccessful.Labels()[ClientAuth])
+	}
+}
+
+func (t *CertScannerTests) TestMatchesClientCertificates() {
+	ca, err := testutils.CreateTestCA(0)
+	t.NoError(err)
+	cert, key := t.writeClientCert(ca)
+	defer viper.Reset()
+	viper.Set(config.ProcessorsTlsClientCerts, []map[string]interface{}{
+		{"cert": cert, "key": key, "sources": []string{"mesh"}, "labels": map[string]string{"team": "payments"}},
+		{"cert": cert, "key": key, "labels": map[string]string{"team": "web"}},
+	})
+	processor, err := CreateTLSStateRetrieval()
+	t.NoError(err)
+	retrieval := processor.(*TLSStateRetrieval)
+
+	for _, test := range []struct {
+		metadata Metadata
+		expected *tls.Certificate
+	}{
+		{Metadata{Source: "mesh", Labels: Labels{"team": "payments"}}, &retrieval.clientCerts[0].certificate},
+		{Metadata{Source: "mesh", Labels: Labels{"team": "other"}}, nil},
+		{Metadata{Source: "hosts", Labels: Labels{"team": "payments"}}, nil},
+		{Metadata{Source: "hosts", Labels: Labels{"team": "web"}}, &retrieval.clientCerts[1].certificate},
+	} {
+		t.Same(test.expected, retrieval.clientCertificate(&Target{Metadata: test.metadata}), test.metadata.Source)
+	}
+
+	viper.Set(config.ProcessorsTlsClientCerts, []map[string]interface{}{{"cert": cert}})
+	_, err = CreateTLSStateRetrieval()
+	t.ErrorContains(err, "client certificates require both a cert and a key")
+
+	viper.Set(config.ProcessorsTlsClientCerts, []map[string]interface{}{{"cert": cert, "key": cert}})
+	_, err = CreateTLSStateRetrieval()
+	t.ErrorContains(err, "error loading client certificate "+cert)
+}
+
+func (t *CertScannerTests) TestChoosesProxyBySource() {
+	defer viper.Reset()
+	viper.Set(config.ProcessorsTlsProxies, []map[string]interface{}{
+		{"url": "http://proxy.internal:3128", "sources": []string{"dmz", "partners"}},
+		{"url": "socks5://bastion.internal"},
+	})
+	processor, err := CreateTLSStateRetrieval()
+	t.NoError(err)
+	retrieval := processor.(*TLSStateRetrieval)
+
+	t.IsType(&proxy.HTTPConnectDialer{}, retrieval.dialer(&Target{Metadata: Metadata{Source: "partners"}}))
+	t.Same(retrieval.dialers[1], retrieval.dialer(&Target{Metadata: Metadata{Source: "hosts"}}))
+
+	viper.Set(config.ProcessorsTlsProxies, []map[string]interface{}{{"url": "ftp://proxy.internal"}})
+	_, err = CreateTLSStateRetrieval()
+	t.ErrorContains(err, "unsupported scheme ftp for proxy ftp://proxy.internal")
+
+	viper.Reset()
+	processor, err = CreateTLSStateRetrieval()
+	t.NoError(err)
+	t.Same(DefaultDialer, processor.(*TLSStateRetrieval).dialer(&Target{Metadata: Metadata{Source: "partners"}}))
+}
+
+func (t *CertScannerTests) TestEnumeratesAcceptedCiphers() {
+	ca, err := testutils.CreateTestCA(0)
+	t.NoError(err)
+	_, certPem, key, err := ca.CreateLeafCert("legacy.internal")
+	t.NoError(err)
+	tlsConfig := testutils.CreateTestTLSConfig(tls.VersionTLS12, certPem, key)
+	tlsConfig.MaxVersion = tls.VersionTLS12
+	address := t.serve(tlsConfig)
+
+	results := t.runScan(&Target{Address: getAddress(address)})
+	t.Len(results, 1)
+	scan := results[0]
+
+	accepted := make([]uint16, 0)
+	failedVersions := make([]string, 0)
+	for _, result := range scan.Results {
+		if result.Failed {
+			failedVersions = append(failedVersions, result.Labels()["version"])
+			continue
+		}
+		t.Equal(uint16(tls.VersionTLS12), result.State.Version)
+		accepted = append(accepted, result.Cipher.ID)
+	}
+	// the ecdsa cipher is configured but cannot be used with the rsa certificate of the server
+	t.ElementsMatch([]uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	}, accepted)
+	t.Equal([]string{"TLS 1.0", "TLS 1.1", "TLS 1.3"}, failedVersions)
+	t.Equal(uint16(tls.VersionTLS12), scan.FirstSuccessful.State.Version)
+
+	// a handshake for each unsupported version, each accepted cipher and the one finding no more are accepted
+	t.Equal(7, scan.Handshakes)
+}
+
+func (t *CertScannerTests) TestInvalidMaxConnections() {
+	defer viper.Reset()
+	viper.Set(config.ProcessorsTlsMaxConnections, 0)
+	_, err := CreateTLSStateRetrieval()
+	t.ErrorContains(err, "invalid max_connections 0, must be at least 1")
+
+	viper.Set(config.ProcessorsTlsMaxConnections, 4)
+	processor, err := CreateTLSStateRetrieval()
+	t.NoError(err)
+	t.Equal(4, processor.(*TLSStateRetrieval).maxConnections)
+
+	viper.Reset()
+	processor, err = CreateTLSStateRetrieval()
+	t.NoError(err)
+	t.Equal(DefaultMaxConnections, processor.(*TLSStateRetrieval).maxConnections)
+}
+
+func (t *CertScannerTests) TestLimitsConnectionsToEachAddress() {
+	defer viper.Reset()
+	viper.Set(config.ProcessorsTlsMaxConnections, 2)
+	ca, err := testutils.CreateTestCA(0)
+	t.NoError(err)
+	_, certPem, key, err := ca.CreateLeafCert("shop.example.com")
+	t.NoError(err)
+	address := netip.MustParseAddrPort(t.serve(testutils.CreateTestTLSConfig(tls.VersionTLS12, certPem, key)))
+
+	processor, err := CreateTLSStateRetrieval()
+	t.NoError(err)
+	retrieval := processor.(*TLSStateRetrieval)
+	dialer := &countingDialer{}
+	retrieval.proxies = []proxy.Config{{URL: "http://proxy.internal"}}
+	retrieval.dialers = []Dialer{dialer}
+
+	// the probes of a host dial the same address, so share its limit
+	targets := []*Target{
+		{Address: CreateNetIPAddress(address)},
+		{Address: CreateNetIPAddressWithServerName(address, "shop.example.com")},
+		{Address: CreateNetIPAddressWithServerName(address, "www.example.com")},
+	}
+	results := make(chan *TargetScan, len(targets))
+	for _, target := range targets {
+		go retrieval.Process(context.Background(), target, results)
+	}
+	for range targets {
+		<-results
+	}
+	t.Equal(2, dialer.peak)
+}
+
+// serveClientAuth starts a server that requires a client certificate issued by the returned ca
+func (t *CertScannerTests) serveClientAuth() (*testutils.TestCA, string) {
+	ca, err := testutils.CreateTestCA(0)
+	t.NoError(err)
+	_, certPem, key, err := ca.CreateLeafCert("mesh.internal")
+	t.NoError(err)
+	tlsConfig := testutils.CreateTestTLSConfig(tls.VersionTLS12, certPem, key)
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	tlsConfig.ClientCAs = ca.Bundle()
+	return ca, t.serve(tlsConfig)
+}
+
+// serve starts a server that completes a handshake on each connection with the given config
+func (t *CertScannerTests) serve(tlsConfig *tls.Config) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	t.NoError(err)
+	t.T().Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func (t *CertScannerTests) writeClientCert(ca *testutils.TestCA) (string, string) {
+	serialNumber, err := testutils.CreateSerialNumber()
+	t.NoError(err)
+	template := testutils.CreateLeafTemplate("cert-scanner", serialNumber)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	_, certPem, key, err := ca.CreateLeafFromTemplate(template)
+	t.NoError(err)
+
+	dir := t.T().TempDir()
+	cert := filepath.Join(dir, "client.crt")
+	t.NoError(os.WriteFile(cert, certPem, 0600))
+	keyFile := filepath.Join(dir, "client.key")
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	t.NoError(os.WriteFile(keyFile, keyPem, 0600))
+	return cert, keyFile
+}
+
+func (t *CertScannerTests) assertNegotiator(retrieval *TLSStateRetrieval, target *Target, expected string) {
+	negotiator, err := retrieval.negotiator(target)
+	t.NoError(err)
+	if expected == "" {
+		t.Nil(negotiator, target.Address.DialAddress())
+		return
+	}
+	wanted, err := starttls.GetNegotiator(expected)
+	t.NoError(err)
+	t.Equal(reflect.ValueOf(wanted).Pointer(), reflect.ValueOf(negotiator).Pointer(), target.Address.DialAddress())
+}
+
 func GetTestTargets() []*Target {
 	ips, _ := net.LookupIP("google.com")
 	targets := make([]*Target, 0)
@@ -103,6 +431,42 @@ func (t *CertScannerTests) runScan(target *Target) []*TargetScan {
 	}
 }
 
+// countingDialer dials directly, counting the most connections it had open at once
+type countingDialer struct {
+	sync.Mutex
+	open int
+	peak int
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := DefaultDialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.open++
+	if d.open > d.peak {
+		d.peak = d.open
+	}
+	return &countedConn{Conn: conn, dialer: d}, nil
+}
+
+type countedConn struct {
+	net.Conn
+	dialer *countingDialer
+	closed sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.closed.Do(func() {
+		c.dialer.Lock()
+		defer c.dialer.Unlock()
+		c.dialer.open--
+	})
+	return c.Conn.Close()
+}
+
 func getAddress(addr string) *NetIPAddress {
 	return CreateNetIPAddress(netip.MustParseAddrPort(addr))
 }
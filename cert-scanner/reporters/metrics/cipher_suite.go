@@ -9,7 +9,7 @@ import (
 
 var (
 	CipherSuiteLabelKeys = []string{
-		"address", "source", "source_type", "failed", "type", "detected_cipher",
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type", "detected_cipher",
 	}
 
 	InvalidCipherSuiteCounter = promauto.NewCounterVec(prometheus.CounterOpts{
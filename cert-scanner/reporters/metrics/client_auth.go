@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+)
+
+var (
+	ClientAuthLabelKeys = []string{
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "client_auth", "acceptable_cas",
+	}
+
+	ClientAuthCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cert_scanner",
+		Name:      "client_auth_total",
+		Help:      "counts the targets that requested a client certificate, and whether one was presented",
+	}, ClientAuthLabelKeys)
+)
+
+// ClientAuthReporter counts targets that requested a client certificate. These are not violations, but a
+// target that requires a client certificate when none was configured may be missing from other metrics.
+type ClientAuthReporter struct {
+	counter CounterVec
+}
+
+func (r *ClientAuthReporter) Report(ctx context.Context, scan *TargetScan) {
+	for _, result := range scan.Results {
+		if result.CertificateRequest != nil {
+			r.counter.WithLabelValues(FilterLabelsValues(result.Labels(), ClientAuthLabelKeys...)...).Inc()
+			return
+		}
+	}
+}
+
+func CreateClientAuthReporter() (Reporter, error) {
+	return &ClientAuthReporter{
+		counter: ClientAuthCounter,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/reporters/metrics/mocks"
+	. "github.com/sgargan/cert-scanner-darkly/testutils"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientAuthReporterTests struct {
+	suite.Suite
+	sut        *ClientAuthReporter
+	counter    *mocks.Counter
+	counterVec *mocks.CounterVec
+}
+
+func (t *ClientAuthReporterTests) SetupTest() {
+	t.counter = &mocks.Counter{}
+	t.counterVec = &mocks.CounterVec{}
+	t.sut = &ClientAuthReporter{counter: t.counterVec}
+}
+
+func (t *ClientAuthReporterTests) TestCountsRequestedClientCertificates() {
+	t.counterVec.On("WithLabelValues", "172.1.2.34:8080", "n/a", "n/a", "some-cluster", "kubernetes", "n/a", "n/a", "requested", "CN=Mesh CA;CN=Other CA").Return(t.counter)
+	t.counter.On("Inc").Return()
+
+	testScan := CreateTestTargetScan().WithTarget(TestTarget()).Build()
+	testScan.Results[0].CertificateRequest = &CertificateRequest{AcceptableCAs: []string{"CN=Mesh CA", "CN=Other CA"}}
+	t.sut.Report(context.Background(), testScan)
+
+	t.counterVec.AssertExpectations(t.T())
+	t.counter.AssertExpectations(t.T())
+}
+
+func (t *ClientAuthReporterTests) TestIgnoresTargetsWithoutClientAuth() {
+	t.sut.Report(context.Background(), CreateTestTargetScan().WithTarget(TestTarget()).Build())
+	t.counterVec.AssertNotCalled(t.T(), "WithLabelValues")
+}
+
+func TestClientAuthReporter(t *testing.T) {
+	suite.Run(t, &ClientAuthReporterTests{})
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"log/slog"
+	"slices"
 
 	"github.com/prometheus/client_golang/prometheus"
 	. "github.com/sgargan/cert-scanner-darkly/types"
@@ -11,7 +12,7 @@ type MetricsScanComparator struct {
 	metrics []*prometheus.MetricVec
 }
 
-// CreateMetricsComparator compares two scans in order to clear metrics for addresses that
+// CreateMetricsComparator compares two scans in order to clear metrics for the addresses of each source that
 // are no longer present in the current set.
 func CreateMetricsComparator() *MetricsScanComparator {
 	return &MetricsScanComparator{
@@ -20,21 +21,56 @@ func CreateMetricsComparator() *MetricsScanComparator {
 			NotYetValidValidationsCounter.MetricVec,
 			TLSVersionValidationsCounter.MetricVec,
 			TrustChainValidationsCounter.MetricVec,
+			RenewalValidationsCounter.MetricVec,
+			RequireTLSValidationsCounter.MetricVec,
+			InvalidCipherSuiteCounter.MetricVec,
+			InconsistentCertificatesCounter.MetricVec,
+			SNIMismatchCounter.MetricVec,
+			ClientAuthCounter.MetricVec,
 			DurationsValidationsHistogram.MetricVec,
 		},
 	}
 }
 
+// Compare removes every series labelled with the source, address, resolved ip and sni of a target in the previous
+// scan that is not in the current scan. Incremental targets are skipped, as their discoveries only discover the targets that changed
+// and report those removed instead. So are the targets of sources the current scan failed to fully discover, as
+// they may only be missing because of the failure.
 func (m *MetricsScanComparator) Compare(previous, current CompletedScan) {
 	currentSet := GetAddressSet(current)
+	failed := current.FailedSources()
 
+	removed := make([]*Target, 0)
 	for _, previous := range previous.Results() {
-		address := previous.Target.Address.String()
-		if !currentSet.ContainsAddress(address) {
-			slog.Debug("previous target address not in current scan results, removing from metrics", "address", address, "target", previous.Target.Name, "source", previous.Target.Source, "sourceType", previous.Target.SourceType)
-			for _, metric := range m.metrics {
-				metric.DeleteLabelValues(address)
-			}
+		if previous.Target.Incremental || slices.Contains(failed, previous.Target.Source) {
+			continue
 		}
+		if !currentSet.ContainsTarget(previous.Target) {
+			slog.Debug("previous target address not in current scan results, removing from metrics", "address", previous.Target.Address.String(), "target", previous.Target.Name, "source", previous.Target.Source, "sourceType", previous.Target.SourceType)
+			removed = append(removed, previous.Target)
+		}
+	}
+	m.Remove(removed)
+}
+
+// Remove removes every series labelled with the source, address, resolved ip and sni of one of the given targets,
+// so series for the same address from other sources, other ips its host resolves to or other probed names are kept
+func (m *MetricsScanComparator) Remove(targets []*Target) {
+	for _, target := range targets {
+		labels := seriesLabels(target)
+		for _, metric := range m.metrics {
+			metric.DeletePartialMatch(labels)
+		}
+	}
+}
+
+// seriesLabels are the labels that identify the series of a target, with the value they are reported with
+func seriesLabels(target *Target) prometheus.Labels {
+	keys := []string{"source", "address", "resolved_ip", "sni"}
+	values := FilterLabelsValues(target.Labels(), keys...)
+	labels := make(prometheus.Labels, len(keys))
+	for x, key := range keys {
+		labels[key] = values[x]
 	}
+	return labels
 }
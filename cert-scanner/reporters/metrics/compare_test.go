@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/require"
+)
+
+type completedScan []*TargetScan
+
+func (c completedScan) Results() []*TargetScan {
+	return c
+}
+
+func (c completedScan) FailedSources() []string {
+	return nil
+}
+
+func TestComparatorClearsRemovedAddresses(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "some_counter"}, []string{"address", "resolved_ip", "sni", "source", "type"})
+	comparator := &MetricsScanComparator{metrics: []*prometheus.MetricVec{counter.MetricVec}}
+
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "n/a", "some-source", "expiry").Inc()
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "n/a", "some-source", "tls-version").Inc()
+	counter.WithLabelValues("10.0.0.2:443", "n/a", "n/a", "some-source", "expiry").Inc()
+
+	previous := completedScan{testTargetScan("10.0.0.1:443"), testTargetScan("10.0.0.2:443")}
+	current := completedScan{testTargetScan("10.0.0.2:443")}
+	comparator.Compare(previous, current)
+
+	require.Equal(t, 1, testutil.CollectAndCount(counter))
+	require.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("10.0.0.2:443", "n/a", "n/a", "some-source", "expiry")))
+}
+
+func TestComparatorSkipsIncrementalTargets(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "some_counter"}, []string{"address", "resolved_ip", "sni", "source", "type"})
+	comparator := &MetricsScanComparator{metrics: []*prometheus.MetricVec{counter.MetricVec}}
+
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "n/a", "some-source", "expiry").Inc()
+	counter.WithLabelValues("10.0.0.2:443", "n/a", "n/a", "some-source", "expiry").Inc()
+
+	// incremental targets missing from the current scan are unchanged rather than removed
+	unchanged := testTargetScan("10.0.0.1:443")
+	unchanged.Target.Incremental = true
+	comparator.Compare(completedScan{unchanged, testTargetScan("10.0.0.2:443")}, completedScan{})
+	require.Equal(t, 1, testutil.CollectAndCount(counter))
+
+	comparator.Remove([]*Target{unchanged.Target})
+	require.Equal(t, 0, testutil.CollectAndCount(counter))
+}
+
+func TestComparatorKeepsTargetsOfFailedSources(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "some_counter"}, []string{"address", "resolved_ip", "sni", "source", "type"})
+	comparator := &MetricsScanComparator{metrics: []*prometheus.MetricVec{counter.MetricVec}}
+
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "n/a", "some-source", "expiry").Inc()
+	counter.WithLabelValues("10.0.0.2:443", "n/a", "n/a", "some-source", "expiry").Inc()
+
+	// the targets of the source are missing as one of its endpoints failed, not as they were removed
+	previous := completedScan{testTargetScan("10.0.0.1:443"), testTargetScan("10.0.0.2:443")}
+	comparator.Compare(previous, partialScan{completedScan{testTargetScan("10.0.0.2:443")}, []string{"some-source"}})
+	require.Equal(t, 2, testutil.CollectAndCount(counter))
+}
+
+func TestComparatorKeepsAddressesOfOtherSources(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "some_counter"}, []string{"address", "resolved_ip", "sni", "source", "type"})
+	comparator := &MetricsScanComparator{metrics: []*prometheus.MetricVec{counter.MetricVec}}
+
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "n/a", "some-source", "expiry").Inc()
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "n/a", "another-source", "expiry").Inc()
+
+	// the pod cidrs of the clusters overlap, so the address moved between clusters
+	moved := testTargetScan("10.0.0.1:443")
+	moved.Target.Source = "another-source"
+	comparator.Compare(completedScan{testTargetScan("10.0.0.1:443"), moved}, completedScan{moved})
+
+	require.Equal(t, 1, testutil.CollectAndCount(counter))
+	require.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("10.0.0.1:443", "n/a", "n/a", "another-source", "expiry")))
+}
+
+func TestComparatorKeepsOtherResolvedIps(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "some_counter"}, []string{"address", "resolved_ip", "sni", "source", "type"})
+	comparator := &MetricsScanComparator{metrics: []*prometheus.MetricVec{counter.MetricVec}}
+
+	counter.WithLabelValues("example.com", "10.0.0.1", "n/a", "some-source", "expiry").Inc()
+	counter.WithLabelValues("example.com", "10.0.0.2", "n/a", "some-source", "expiry").Inc()
+
+	// the host no longer resolves to one of its ips
+	previous := completedScan{testResolvedScan("10.0.0.1"), testResolvedScan("10.0.0.2")}
+	comparator.Compare(previous, completedScan{testResolvedScan("10.0.0.2")})
+
+	require.Equal(t, 1, testutil.CollectAndCount(counter))
+	require.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("example.com", "10.0.0.2", "n/a", "some-source", "expiry")))
+}
+
+func TestComparatorKeepsOtherProbedServerNames(t *testing.T) {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "some_counter"}, []string{"address", "resolved_ip", "sni", "source", "type"})
+	comparator := &MetricsScanComparator{metrics: []*prometheus.MetricVec{counter.MetricVec}}
+
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "none", "some-source", "expiry").Inc()
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "example.com", "some-source", "expiry").Inc()
+	counter.WithLabelValues("10.0.0.1:443", "n/a", "example.org", "some-source", "expiry").Inc()
+
+	// one of the names is no longer probed
+	previous := completedScan{testProbeScan("none"), testProbeScan("example.com"), testProbeScan("example.org")}
+	comparator.Compare(previous, completedScan{testProbeScan("none"), testProbeScan("example.com")})
+
+	require.Equal(t, 2, testutil.CollectAndCount(counter))
+	require.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues("10.0.0.1:443", "n/a", "example.com", "some-source", "expiry")))
+}
+
+type partialScan struct {
+	completedScan
+	failed []string
+}
+
+func (p partialScan) FailedSources() []string {
+	return p.failed
+}
+
+func testTargetScan(address string) *TargetScan {
+	return NewTargetScanResult(&Target{
+		Address:  CreateNetIPAddress(netip.MustParseAddrPort(address)),
+		Metadata: Metadata{Source: "some-source"},
+	})
+}
+
+func testResolvedScan(ip string) *TargetScan {
+	address, _ := ParseUrlAddress("https://example.com")
+	return NewTargetScanResult(&Target{
+		Address:  address.WithResolvedIP(netip.MustParseAddr(ip)),
+		Metadata: Metadata{Source: "some-source", Labels: Labels{"resolved_ip": ip}},
+	})
+}
+
+func testProbeScan(sni string) *TargetScan {
+	scan := testTargetScan("10.0.0.1:443")
+	scan.Target.Metadata.Labels = Labels{"sni": sni}
+	return scan
+}
@@ -10,7 +10,7 @@ var (
 	DurationBuckets = []float64{5, 10, 50, 75, 100, 150, 300, 500, 750, 1000}
 
 	DurationsLabelKeys = []string{
-		"address", "source", "source_type", "failed", "type",
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type",
 	}
 
 	DurationsValidationsHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
@@ -9,7 +9,7 @@ import (
 
 var (
 	ExpiryLabelKeys = []string{
-		"address", "source", "source_type", "failed", "type",
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type",
 		"warning_duration", "not_after", "not_after_date",
 	}
 
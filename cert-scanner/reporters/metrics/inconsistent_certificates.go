@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+)
+
+var (
+	InconsistentCertificatesLabelKeys = []string{
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type",
+		"fingerprint", "resolved_ips", "distinct_certificates",
+	}
+
+	InconsistentCertificatesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cert_scanner",
+		Name:      "inconsistent_certificates_total",
+		Help:      "counts the resolved ips of url targets that serve a different certificate to the other ips",
+	}, InconsistentCertificatesLabelKeys)
+)
+
+func CreateInconsistentCertificatesReporter() (Reporter, error) {
+	return &CounterReporter{
+		counter:           InconsistentCertificatesCounter,
+		ignoreResultTypes: viper.GetStringSlice("reporters.inconsistent_certificates.ignore"),
+		requiredLabels:    InconsistentCertificatesLabelKeys,
+		validationType:    "inconsistent_certificates",
+	}, nil
+}
@@ -7,6 +7,7 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/prometheus/client_golang/prometheus"
 	. "github.com/sgargan/cert-scanner-darkly/types"
@@ -30,7 +31,7 @@ func (m *CounterReporter) Report(ctx context.Context, scan *TargetScan) {
 		labels := violation.Labels()
 		if isValidationType(m.validationType, labels) {
 			allLabels := mergeLabels(labels, scan.Target.Labels())
-			labelKeys := append(m.requiredLabels, GetAddtionalLabelsForSource(labels)...)
+			labelKeys := appendLabelKeys(m.requiredLabels, GetAddtionalLabelsForSource(allLabels)...)
 			filteredLabels := FilterLabelsValues(allLabels, labelKeys...)
 			m.counter.WithLabelValues(filteredLabels...).Inc()
 		}
@@ -53,7 +54,7 @@ func (m *HistogramReporter) Report(ctx context.Context, scan *TargetScan) {
 		labels := violation.Labels()
 		if isValidationType(m.validationType, labels) {
 			allLabels := mergeLabels(labels, scan.Target.Labels())
-			labelKeys := append(m.requiredLabels, GetAddtionalLabelsForSource(labels)...)
+			labelKeys := appendLabelKeys(m.requiredLabels, GetAddtionalLabelsForSource(allLabels)...)
 			filteredLabels := FilterLabelsValues(allLabels, labelKeys...)
 			duration := float64(violation.Result().Duration.Milliseconds())
 			m.histogram.WithLabelValues(filteredLabels...).Observe(duration)
@@ -99,8 +100,10 @@ func ShouldReportMetric(result *ScanResult, required string, ignoredResultTypes
 
 var additionalLabelsCache = make(map[string][]string, 0)
 
+// GetAddtionalLabelsForSource returns the label keys configured to be reported for targets of the source type
+// in the given labels
 func GetAddtionalLabelsForSource(labels map[string]string) []string {
-	source := labels["sourceType"]
+	source := labels[ResultSourceTypeLabel]
 	if cachedAddtionalLabels, ok := additionalLabelsCache[source]; !ok {
 		additionalLabels := viper.GetStringSlice(fmt.Sprintf("discovery.%s.additionalLabels", source))
 		additionalLabelsCache[source] = additionalLabels
@@ -110,6 +113,18 @@ func GetAddtionalLabelsForSource(labels map[string]string) []string {
 	}
 }
 
+// appendLabelKeys appends the given keys to a copy of the required keys, skipping those already required, such as
+// the workload labels of kubernetes targets
+func appendLabelKeys(required []string, keys ...string) []string {
+	labelKeys := slices.Clone(required)
+	for _, key := range keys {
+		if !slices.Contains(labelKeys, key) {
+			labelKeys = append(labelKeys, key)
+		}
+	}
+	return labelKeys
+}
+
 func mergeLabels(labelSets ...map[string]string) map[string]string {
 	merged := make(map[string]string, 0)
 	for _, labels := range labelSets {
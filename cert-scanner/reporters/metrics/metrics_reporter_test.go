@@ -6,10 +6,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sgargan/cert-scanner-darkly/reporters/metrics/mocks"
 
 	. "github.com/sgargan/cert-scanner-darkly/testutils"
 	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -59,6 +62,44 @@ func (t *MetricsReporterTests) TestHistogramReporterIncrementsCounter() {
 	histogram.AssertExpectations(t.T())
 }
 
+func (t *MetricsReporterTests) TestReportsWorkloadsOfKubernetesTargets() {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "some_counter"}, ExpiryLabelKeys)
+	reporter := CounterReporter{
+		counter:        counter,
+		requiredLabels: ExpiryLabelKeys,
+		validationType: "some-error",
+	}
+
+	scan := t.createTestScan()
+	scan.Target.Metadata.Labels["workload_kind"] = "Deployment"
+	scan.Target.Metadata.Labels["workload_name"] = "some-app"
+	reporter.Report(context.Background(), scan)
+
+	t.Equal(1, testutil.CollectAndCount(counter.MustCurryWith(prometheus.Labels{"workload_kind": "Deployment", "workload_name": "some-app"})))
+}
+
+func (t *MetricsReporterTests) TestReportsAdditionalLabelsOfSourceType() {
+	// the labels of each source type are cached once read
+	delete(additionalLabelsCache, "kubernetes")
+	defer delete(additionalLabelsCache, "kubernetes")
+	viper.Set("discovery.kubernetes.additionalLabels", []string{"foo", "source"})
+	defer viper.Reset()
+
+	counter := &mocks.Counter{}
+	counterVec := &mocks.CounterVec{}
+	counterVec.On("WithLabelValues", "172.1.2.34:8080", "some-cluster", "bar").Return(counter)
+	counter.On("Inc").Return()
+
+	reporter := CounterReporter{
+		counter:        counterVec,
+		requiredLabels: []string{"address", "source"},
+		validationType: "some-error",
+	}
+	reporter.Report(context.Background(), t.createTestScan())
+
+	counterVec.AssertExpectations(t.T())
+}
+
 func (t *MetricsReporterTests) createTestScan() *TargetScan {
 	testScan := CreateTestTargetScan().WithTarget(TestTarget())
 	violation := func(result *ScanResult) ScanError {
@@ -9,7 +9,7 @@ import (
 
 var (
 	NotYetValidLabelKeys = []string{
-		"address", "source", "source_type", "failed", "type",
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type",
 		"until_valid", "not_before", "not_before_date",
 	}
 
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+)
+
+var (
+	// the pods mounting the secret of a certificate are logged but not used as a label, as every rollout of
+	// the pods would create a new series
+	RenewalLabelKeys = []string{
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type", "reason",
+		"certificate", "secret", "certificate_not_after", "certificate_renewal_time", "certificate_ready",
+		"not_after", "not_after_date",
+	}
+
+	RenewalValidationsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cert_scanner",
+		Name:      "certificate_renewal_validations_total",
+		Help:      "counts the results of cert-manager certificate renewal validations",
+	}, RenewalLabelKeys)
+)
+
+func CreateRenewalReporter() (Reporter, error) {
+	return &CounterReporter{
+		counter:           RenewalValidationsCounter,
+		ignoreResultTypes: viper.GetStringSlice("validations.renewal.ignore"),
+		validationType:    "renewal",
+		requiredLabels:    RenewalLabelKeys,
+	}, nil
+}
@@ -9,7 +9,7 @@ import (
 
 var (
 	RequireTLSLabelKeys = []string{
-		"address", "source", "source_type", "failed", "type", "target_pod", "target_namespace",
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type", "target_pod", "target_namespace",
 	}
 
 	RequireTLSValidationsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
@@ -35,15 +35,28 @@ var (
 		"source_type",
 		"success",
 	})
+
+	HandshakesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cert_scanner",
+		Name:      "handshakes_total",
+		Help:      "counts of the tls handshakes made to scan targets",
+	}, []string{
+		"source",
+		"source_type",
+	})
 )
 
 // ScanStatsReporter tracks metrics about each scan,
 type ScanStatsReporter struct {
 	tlsVersionCounter     CounterVec
 	scanDurationHistogram HistogramVec
+	handshakesCounter     CounterVec
 }
 
 func (r *ScanStatsReporter) Report(ctx context.Context, scan *TargetScan) {
+	if scan.Handshakes > 0 {
+		r.handshakesCounter.WithLabelValues(scan.Target.Source, scan.Target.SourceType).Add(float64(scan.Handshakes))
+	}
 	for _, scanResult := range scan.Results {
 		source := scan.Target.Source
 		sourceType := scan.Target.SourceType
@@ -56,7 +69,7 @@ func (r *ScanStatsReporter) Report(ctx context.Context, scan *TargetScan) {
 			success = "true"
 		}
 
-		if scanResult.State != nil {
+		if scanResult.State != nil && !scanResult.Static {
 			version = utils.ToVersion(int(scanResult.State.Version))
 			cypher = scanResult.Cipher.Name
 		}
@@ -74,5 +87,6 @@ func CreateScanStatsReporter() (Reporter, error) {
 	return &ScanStatsReporter{
 		TLSVersionCounter,
 		ScanDurationHistogram,
+		HandshakesCounter,
 	}, nil
 }
@@ -17,6 +17,7 @@ type ScanStatsReporterTests struct {
 	histogramVec *mocks.HistogramVec
 	counter      *mocks.Counter
 	counterVec   *mocks.CounterVec
+	handshakes   *mocks.CounterVec
 	suite.Suite
 }
 
@@ -26,10 +27,12 @@ func (t *ScanStatsReporterTests) SetupTest() {
 
 	t.counter = &mocks.Counter{}
 	t.counterVec = &mocks.CounterVec{}
+	t.handshakes = &mocks.CounterVec{}
 
 	t.sut = &ScanStatsReporter{
 		tlsVersionCounter:     t.counterVec,
 		scanDurationHistogram: t.histogramVec,
+		handshakesCounter:     t.handshakes,
 	}
 }
 
@@ -63,6 +66,25 @@ func (t *ScanStatsReporterTests) TestShouldSkipFailedScansIfOnlySuccessfulIsEnab
 	t.assertions()
 }
 
+func (t *ScanStatsReporterTests) TestShouldCountHandshakes() {
+	t.counterVec.On("WithLabelValues", "some-cluster", "kubernetes", "somepod-acdf-bdfe", "true", "1.2", "TLS_AES_128_GCM_SHA256").Return(t.counter)
+	t.counter.On("Inc").Return()
+	t.histogramVec.On("WithLabelValues", "some-cluster", "kubernetes", "true").Return(t.histogram)
+	t.histogram.On("Observe", 0.0).Return()
+
+	handshakes := &mocks.Counter{}
+	t.handshakes.On("WithLabelValues", "some-cluster", "kubernetes").Return(handshakes)
+	handshakes.On("Add", 7.0).Return()
+
+	testScan := CreateTestTargetScan().WithTarget(TestTarget()).Build()
+	testScan.Handshakes = 7
+	t.sut.Report(context.Background(), testScan)
+
+	t.assertions()
+	t.handshakes.AssertExpectations(t.T())
+	handshakes.AssertExpectations(t.T())
+}
+
 func (t *ScanStatsReporterTests) assertions() {
 	t.counterVec.AssertExpectations(t.T())
 	t.counter.AssertExpectations(t.T())
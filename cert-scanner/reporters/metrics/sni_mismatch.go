@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+)
+
+var (
+	SNIMismatchLabelKeys = []string{
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type", "reason", "fingerprint",
+	}
+
+	SNIMismatchCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cert_scanner",
+		Name:      "sni_mismatch_total",
+		Help:      "counts the probes of ip targets whose default certificate differs unexpectedly from those served for their server names",
+	}, SNIMismatchLabelKeys)
+)
+
+func CreateSNIMismatchReporter() (Reporter, error) {
+	return &CounterReporter{
+		counter:           SNIMismatchCounter,
+		ignoreResultTypes: viper.GetStringSlice("reporters.sni_mismatch.ignore"),
+		requiredLabels:    SNIMismatchLabelKeys,
+		validationType:    "sni_mismatch",
+	}, nil
+}
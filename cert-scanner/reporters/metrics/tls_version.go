@@ -9,7 +9,7 @@ import (
 
 var (
 	TLSVersionLabelKeys = []string{
-		"address", "source", "source_type", "failed", "type",
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type",
 		"detected_version", "min_version",
 	}
 
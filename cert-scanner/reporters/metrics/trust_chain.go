@@ -9,7 +9,7 @@ import (
 
 var (
 	TrustChainLabelKeys = []string{
-		"address", "source", "source_type", "failed", "type",
+		"address", "resolved_ip", "sni", "source", "source_type", "workload_kind", "workload_name", "failed", "type",
 		"subject_cn", "issuer_cn", "authority_key_id",
 	}
 
@@ -9,14 +9,18 @@ import (
 )
 
 var factories = map[string]Factory[Reporter]{
-	"logging":       loggingReporter,
-	"expiry":        metrics.CreateExpiryReporter,
-	"not_yet_valid": metrics.CreateNotYetValidReporter,
-	"tls_version":   metrics.CreateTLSVersionReporter,
-	"trust_chain":   metrics.CreateTrustChainReporter,
-	"scan_stats":    metrics.CreateScanStatsReporter,
-	"require_tls":    metrics.CreateRequireTLSReporter,
-	"cipher_suite":   metrics.CreateCipherSuiteReporter,
+	"logging":                   loggingReporter,
+	"expiry":                    metrics.CreateExpiryReporter,
+	"not_yet_valid":             metrics.CreateNotYetValidReporter,
+	"tls_version":               metrics.CreateTLSVersionReporter,
+	"trust_chain":               metrics.CreateTrustChainReporter,
+	"scan_stats":                metrics.CreateScanStatsReporter,
+	"require_tls":               metrics.CreateRequireTLSReporter,
+	"cipher_suite":              metrics.CreateCipherSuiteReporter,
+	"renewal":                   metrics.CreateRenewalReporter,
+	"inconsistent_certificates": metrics.CreateInconsistentCertificatesReporter,
+	"sni_mismatch":              metrics.CreateSNIMismatchReporter,
+	"client_auth":               metrics.CreateClientAuthReporter,
 }
 
 func CreateReporters() (Reporters, error) {
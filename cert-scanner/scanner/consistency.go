@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strconv"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	InconsistentCertificates = "inconsistent_certificates"
+	Fingerprint              = "fingerprint"
+	ResolvedIPs              = "resolved_ips"
+	DistinctCertificates     = "distinct_certificates"
+)
+
+// InconsistentCertificatesError is raised for each ip of a url target when the ips it resolves to serve
+// different certificates
+type InconsistentCertificatesError struct {
+	result       *ScanResult
+	fingerprint  string
+	ips          int
+	certificates int
+}
+
+func (e *InconsistentCertificatesError) Error() string {
+	return fmt.Sprintf("%d resolved ips serve %d different certificates", e.ips, e.certificates)
+}
+
+func (e *InconsistentCertificatesError) Labels() map[string]string {
+	labels := e.result.Labels()
+	labels["type"] = InconsistentCertificates
+	labels[Fingerprint] = e.fingerprint
+	labels[ResolvedIPs] = strconv.Itoa(e.ips)
+	labels[DistinctCertificates] = strconv.Itoa(e.certificates)
+	return labels
+}
+
+func (e *InconsistentCertificatesError) Result() *ScanResult {
+	return e.result
+}
+
+// compareResolved compares the certificates served by each ip a url target resolved to, as a load balancer
+// with a backend serving a stale or wrong certificate only fails for some of its clients. If the ips serve
+// different leaf certificates, an inconsistent_certificates violation labelled with the fingerprint of the
+// certificate is added to the scan of each ip. Ips that could not be scanned are not compared.
+func (s *Scan) compareResolved() {
+	groups := make(map[*Target][]*TargetScan)
+	for _, targetScan := range s.TargetScans {
+		if from, resolved := s.resolvedFrom[targetScan.Target]; resolved {
+			groups[from] = append(groups[from], targetScan)
+		}
+	}
+
+	for from, targetScans := range groups {
+		fingerprints := make(map[*TargetScan]string)
+		distinct := make(map[string]bool)
+		for _, targetScan := range targetScans {
+			cert := leafCertificate(targetScan)
+			if cert == nil {
+				continue
+			}
+			fingerprint := CertificateFingerprint(cert)
+			fingerprints[targetScan] = fingerprint
+			distinct[fingerprint] = true
+		}
+		if len(distinct) < 2 {
+			continue
+		}
+
+		slog.Warn("resolved ips serve inconsistent certificates", "target", from.Name, "address", from.Address.String(), "ips", len(fingerprints), "certificates", len(distinct))
+		for targetScan, fingerprint := range fingerprints {
+			targetScan.AddViolation(&InconsistentCertificatesError{
+				result:       targetScan.FirstSuccessful,
+				fingerprint:  fingerprint,
+				ips:          len(fingerprints),
+				certificates: len(distinct),
+			})
+		}
+	}
+}
+
+func leafCertificate(targetScan *TargetScan) *x509.Certificate {
+	result := targetScan.FirstSuccessful
+	if result == nil || result.State == nil || len(result.State.PeerCertificates) == 0 {
+		return nil
+	}
+	return result.State.PeerCertificates[0]
+}
@@ -0,0 +1,110 @@
+package scanner
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	MergeDuplicates = "merge"
+	KeepDuplicates  = "keep"
+)
+
+// mergeTargets merges targets found by more than one discovery, so each is only scanned and reported on
+// once. Targets are duplicates if they dial the same address in the same network through the same proxy,
+// presenting the same server name. The source, name and address of the merged target are those of the first
+// duplicate ordered by source and source type, with the labels of each duplicate added in that order, so the
+// first value of a label is kept. Every source and source type of the duplicates is logged rather than added as a
+// label, as the series of the target would change whenever another source found it. Every name they are probed
+// with is kept. The merged target is incremental if any of the duplicates are.
+func mergeTargets(targets []*Target, proxies []proxy.Config) []*Target {
+	keys := make([]string, 0)
+	duplicates := make(map[string][]*Target)
+	for _, target := range targets {
+		key := targetKey(target, proxies)
+		if _, seen := duplicates[key]; !seen {
+			keys = append(keys, key)
+		}
+		duplicates[key] = append(duplicates[key], target)
+	}
+
+	merged := make([]*Target, 0, len(keys))
+	for _, key := range keys {
+		if len(duplicates[key]) == 1 {
+			merged = append(merged, duplicates[key][0])
+			continue
+		}
+		merged = append(merged, mergeDuplicates(duplicates[key]))
+	}
+	if len(merged) < len(targets) {
+		slog.Info("merged duplicate targets", "discovered", len(targets), "merged", len(merged))
+	}
+	return merged
+}
+
+func mergeDuplicates(duplicates []*Target) *Target {
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		if duplicates[i].Source != duplicates[j].Source {
+			return duplicates[i].Source < duplicates[j].Source
+		}
+		return duplicates[i].SourceType < duplicates[j].SourceType
+	})
+
+	first := duplicates[0]
+	labels := Labels{}
+	sources := make([]string, 0, len(duplicates))
+	sourceTypes := make([]string, 0, len(duplicates))
+	var serverNames []string
+	incremental := false
+	for _, duplicate := range duplicates {
+		incremental = incremental || duplicate.Incremental
+		for k, v := range duplicate.Metadata.Labels {
+			if _, present := labels[k]; !present {
+				labels[k] = v
+			}
+		}
+		sources = appendUnique(sources, duplicate.Source)
+		sourceTypes = appendUnique(sourceTypes, duplicate.SourceType)
+		for _, serverName := range duplicate.ServerNames {
+			serverNames = appendUnique(serverNames, serverName)
+		}
+	}
+	sort.Strings(sourceTypes)
+	slog.Debug("merged duplicate targets", "address", first.Address.DialAddress(), "serverName", first.Address.ServerName(), "sources", strings.Join(sources, ","), "source_types", strings.Join(sourceTypes, ","))
+
+	return &Target{
+		Address: first.Address,
+		Metadata: Metadata{
+			Name:        first.Name,
+			Source:      first.Source,
+			SourceType:  first.SourceType,
+			Labels:      labels,
+			ServerNames: serverNames,
+			Network:     first.Network,
+			Incremental: incremental,
+		},
+	}
+}
+
+// targetKey identifies a target by the network it is in, the proxy it is dialled through, the address it dials
+// and the server name it presents
+func targetKey(target *Target, proxies []proxy.Config) string {
+	via := ""
+	if i := proxy.Select(proxies, target.Source); i >= 0 {
+		via = proxies[i].URL
+	}
+	return strings.Join([]string{target.Network, via, target.Address.DialAddress(), target.Address.ServerName()}, "|")
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
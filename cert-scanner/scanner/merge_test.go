@@ -0,0 +1,144 @@
+package scanner
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type MergeTests struct {
+	suite.Suite
+}
+
+func (t *MergeTests) TearDownTest() {
+	viper.Reset()
+}
+
+func (t *MergeTests) TestMergesTargetsWithSameAddressAndServerName() {
+	pod := createTarget("web-0", "kubernetes", "prod-cluster", Labels{"namespace": "shop", "port": "8443"},
+		CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.1:8443")))
+	host := createTarget("web", "file", "hosts", Labels{"team": "payments", "port": "443"},
+		CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.1:8443")))
+	other := createTarget("db", "file", "hosts", Labels{},
+		CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.2:5432")))
+
+	merged := mergeTargets([]*Target{pod, other, host}, nil)
+	t.Len(merged, 2)
+	t.Equal(&Target{
+		Address: pod.Address,
+		Metadata: Metadata{
+			Name:       "web",
+			Source:     "hosts",
+			SourceType: "file",
+			Labels: Labels{
+				"namespace": "shop",
+				"team":      "payments",
+				"port":      "443",
+			},
+		},
+	}, merged[0])
+	t.Same(other, merged[1])
+}
+
+func (t *MergeTests) TestKeepsTargetsWithDifferentServerNames() {
+	dial := netip.MustParseAddrPort("10.0.0.1:443")
+	targets := []*Target{
+		createTarget("a", "file", "hosts", nil, CreateNetIPAddressWithServerName(dial, "a.example.com")),
+		createTarget("b", "file", "hosts", nil, CreateNetIPAddressWithServerName(dial, "b.example.com")),
+		createTarget("c", "file", "hosts", nil, CreateNetIPAddress(dial)),
+	}
+	t.Equal(targets, mergeTargets(targets, nil))
+}
+
+func (t *MergeTests) TestKeepsTargetsInDifferentNetworks() {
+	dial := netip.MustParseAddrPort("10.0.0.1:443")
+	prod := createTarget("web-0", "kubernetes", "prod-cluster", nil, CreateNetIPAddress(dial))
+	prod.Network = "prod-cluster"
+	staging := createTarget("web-0", "kubernetes", "staging-cluster", nil, CreateNetIPAddress(dial))
+	staging.Network = "staging-cluster"
+	host := createTarget("web", "file", "hosts", nil, CreateNetIPAddress(dial))
+
+	targets := []*Target{prod, staging, host}
+	t.Equal(targets, mergeTargets(targets, nil))
+}
+
+func (t *MergeTests) TestKeepsTargetsDialledThroughDifferentProxies() {
+	proxies := []proxy.Config{
+		{URL: "http://proxy.internal:3128", Sources: []string{"partners"}},
+		{URL: "socks5://bastion.internal", Sources: []string{"dmz"}},
+	}
+	dial := netip.MustParseAddrPort("10.0.0.1:443")
+	partners := createTarget("api", "file", "partners", nil, CreateNetIPAddress(dial))
+	dmz := createTarget("api", "file", "dmz", nil, CreateNetIPAddress(dial))
+	direct := createTarget("api", "file", "hosts", nil, CreateNetIPAddress(dial))
+
+	targets := []*Target{partners, dmz, direct}
+	t.Equal(targets, mergeTargets(targets, proxies))
+
+	merged := mergeTargets(targets, []proxy.Config{{URL: "socks5://bastion.internal"}})
+	t.Len(merged, 1)
+	t.Equal("dmz", merged[0].Source)
+}
+
+func (t *MergeTests) TestMergesServerNamesToProbe() {
+	dial := netip.MustParseAddrPort("10.0.0.1:443")
+	pod := createTarget("web-0", "kubernetes", "prod-cluster", nil, CreateNetIPAddress(dial))
+	pod.ServerNames = []string{"www.example.com"}
+	host := createTarget("web", "file", "hosts", nil, CreateNetIPAddress(dial))
+	host.ServerNames = []string{"api.example.com", "www.example.com"}
+
+	merged := mergeTargets([]*Target{pod, host}, nil)
+	t.Len(merged, 1)
+	t.Equal([]string{"api.example.com", "www.example.com"}, merged[0].ServerNames)
+}
+
+func (t *MergeTests) TestMergesUrlsOnDialAddress() {
+	ingress := createTarget("shop.example.com", "kubernetes", "prod-cluster", nil,
+		CreateUrlAddressWithDialAddress(&url.URL{Scheme: "https", Host: "shop.example.com:443"}, "10.0.0.1"))
+	dns := createTarget("shop.example.com", "dns", "corp-dns", nil,
+		CreateUrlAddress(&url.URL{Scheme: "tls", Host: "shop.example.com:443"}))
+	host := createTarget("shop.example.com", "file", "hosts", nil,
+		CreateUrlAddress(&url.URL{Scheme: "https", Host: "shop.example.com"}))
+
+	merged := mergeTargets([]*Target{ingress, dns, host}, nil)
+	t.Len(merged, 2)
+	t.Same(ingress, merged[0])
+	t.Equal("corp-dns", merged[1].Source)
+	t.Equal("dns", merged[1].SourceType)
+}
+
+func (t *MergeTests) TestScanMergesDiscoveredTargets() {
+	discoveries := Discoveries{&MockDiscovery{id: 1}, &MockDiscovery{id: 1}}
+	reporter := &MockReporter{}
+
+	t.NoError(CreateScan(discoveries, Processors{&MockProcessor{}}, nil, Reporters{reporter}).Scan(context.Background()))
+	t.Len(reporter.results, 10)
+
+	viper.Set(config.Duplicates, KeepDuplicates)
+	reporter = &MockReporter{}
+	t.NoError(CreateScan(discoveries, Processors{&MockProcessor{}}, nil, Reporters{reporter}).Scan(context.Background()))
+	t.Len(reporter.results, 20)
+}
+
+func createTarget(name, sourceType, source string, labels Labels, address Address) *Target {
+	return &Target{
+		Address: address,
+		Metadata: Metadata{
+			Name:       name,
+			Source:     source,
+			SourceType: sourceType,
+			Labels:     labels,
+		},
+	}
+}
+
+func TestMergeTests(t *testing.T) {
+	suite.Run(t, &MergeTests{})
+}
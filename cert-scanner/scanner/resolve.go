@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/sgargan/cert-scanner-darkly/utils"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	ResolvedIP = "resolved_ip"
+
+	resolveTimeout = 5 * time.Second
+)
+
+// Resolver looks up the ips of a host, it is implemented by [net.Resolver]
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// resolve fans each url target out into a target for each A and AAAA record of the host it dials, as each ip
+// behind a load balancer can serve a different certificate. Each of these targets presents the hostname of
+// the url as SNI, and is labelled with the ip it connects to. Targets that dial an ip, or whose host cannot
+// be resolved, are scanned as they are. So are targets dialled through a proxy, as the proxy resolves their
+// host from its own network. The url target each fanned out target was created from is tracked, so the
+// certificates of each of its ips can be compared.
+func (s *Scan) resolve(ctx context.Context, targets []*Target) []*Target {
+	lock := sync.Mutex{}
+	resolved := make(map[*Target][]*Target)
+	group := utils.BatchProcess[*Target](ctx, targets, s.parallel, func(ctx context.Context, target *Target) error {
+		if fanned := s.resolveTarget(ctx, target); fanned != nil {
+			lock.Lock()
+			defer lock.Unlock()
+			resolved[target] = fanned
+		}
+		return nil
+	})
+	group.Wait()
+
+	expanded := make([]*Target, 0, len(targets))
+	for _, target := range targets {
+		fanned, found := resolved[target]
+		if !found {
+			expanded = append(expanded, target)
+			continue
+		}
+		for _, t := range fanned {
+			s.resolvedFrom[t] = target
+		}
+		expanded = append(expanded, fanned...)
+	}
+	return expanded
+}
+
+func (s *Scan) resolveTarget(ctx context.Context, target *Target) []*Target {
+	address, isUrl := target.Address.(*UrlAddress)
+	if !isUrl || address.ResolvedIP().IsValid() || proxy.Select(s.proxies, target.Source) >= 0 {
+		return nil
+	}
+	host := address.DialHost()
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	ips, err := s.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		slog.Warn("error resolving target, scanning host as is", "target", target.Name, "host", host, "err", err.Error())
+		return nil
+	}
+
+	unique := make(map[netip.Addr]bool)
+	for _, ip := range ips {
+		unique[ip.Unmap()] = true
+	}
+	sorted := make([]netip.Addr, 0, len(unique))
+	for ip := range unique {
+		sorted = append(sorted, ip)
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Less(sorted[j]) })
+
+	fanned := make([]*Target, 0, len(sorted))
+	for _, ip := range sorted {
+		labels := Labels{}
+		for k, v := range target.Metadata.Labels {
+			labels[k] = v
+		}
+		labels[ResolvedIP] = ip.String()
+		fanned = append(fanned, &Target{
+			Address: address.WithResolvedIP(ip),
+			Metadata: Metadata{
+				Name:       target.Name,
+				Source:     target.Source,
+				SourceType: target.SourceType,
+				Labels:     labels,
+				Network:    target.Network,
+			},
+		})
+	}
+	slog.Debug("resolved target", "target", target.Name, "host", host, "ips", len(fanned))
+	return fanned
+}
@@ -0,0 +1,254 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/sgargan/cert-scanner-darkly/config"
+	"github.com/sgargan/cert-scanner-darkly/processors"
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
+	. "github.com/sgargan/cert-scanner-darkly/testutils"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolveTests struct {
+	suite.Suite
+	sut *Scan
+}
+
+func (t *ResolveTests) SetupTest() {
+	t.sut = CreateScan(nil, nil, nil, nil)
+	t.sut.resolver = &mockResolver{ips: map[string][]string{
+		"shop.example.com": {"10.0.0.2", "10.0.0.1", "::ffff:10.0.0.1", "2001:db8::1"},
+		"lb.example.com":   {"10.1.0.1"},
+	}}
+}
+
+func (t *ResolveTests) TestFansOutUrlTargetsToEachIP() {
+	target := createTarget("shop", "file", "hosts", Labels{"team": "payments"}, t.parseUrl("https://shop.example.com:8443"))
+	resolved := t.sut.resolve(context.Background(), []*Target{target})
+
+	t.Len(resolved, 3)
+	for x, ip := range []string{"10.0.0.1", "10.0.0.2", "2001:db8::1"} {
+		t.Equal(net.JoinHostPort(ip, "8443"), resolved[x].Address.DialAddress())
+		t.Equal("shop.example.com", resolved[x].Address.ServerName())
+		t.Equal("shop.example.com", resolved[x].Address.String())
+		t.Equal(Labels{"team": "payments", ResolvedIP: ip}, resolved[x].Metadata.Labels)
+		t.Same(target, t.sut.resolvedFrom[resolved[x]])
+	}
+}
+
+func (t *ResolveTests) TestResolvesDialAddress() {
+	address := CreateUrlAddressWithDialAddress(&url.URL{Scheme: "https", Host: "shop.example.com"}, "lb.example.com")
+	resolved := t.sut.resolve(context.Background(), []*Target{createTarget("shop", "kubernetes", "prod", nil, address)})
+
+	t.Len(resolved, 1)
+	t.Equal("10.1.0.1:443", resolved[0].Address.DialAddress())
+	t.Equal("shop.example.com", resolved[0].Address.ServerName())
+}
+
+func (t *ResolveTests) TestScansTargetsThatCannotBeResolvedAsTheyAre() {
+	targets := []*Target{
+		createTarget("pod", "kubernetes", "prod", nil, CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.3:443"))),
+		createTarget("ip", "file", "hosts", nil, t.parseUrl("https://10.0.0.4:8443")),
+		createTarget("missing", "file", "hosts", nil, t.parseUrl("https://missing.example.com")),
+	}
+	t.Equal(targets, t.sut.resolve(context.Background(), targets))
+	t.Empty(t.sut.resolvedFrom)
+}
+
+func (t *ResolveTests) TestDoesNotResolveProxiedTargets() {
+	t.sut.proxies = []proxy.Config{{URL: "http://proxy.internal:3128", Sources: []string{"partners"}}}
+	proxied := createTarget("shop", "file", "partners", nil, t.parseUrl("https://shop.example.com"))
+	direct := createTarget("shop", "file", "hosts", nil, t.parseUrl("https://shop.example.com"))
+
+	resolved := t.sut.resolve(context.Background(), []*Target{proxied, direct})
+	t.Len(resolved, 4)
+	t.Same(proxied, resolved[0])
+}
+
+func (t *ResolveTests) TestDialsProxiedUrlTargetsThroughProxy() {
+	defer viper.Reset()
+	ca, err := CreateTestCA(0)
+	t.NoError(err)
+	_, certPem, key, err := ca.CreateLeafCert("shop.example.com")
+	t.NoError(err)
+	server, err := tls.Listen("tcp", "127.0.0.1:0", CreateTestTLSConfig(tls.VersionTLS12, certPem, key))
+	t.NoError(err)
+	defer server.Close()
+	go func() {
+		for {
+			conn, err := server.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	// the proxy tunnels every request to the server, recording the address it was asked to connect to
+	requested := make(chan string, 100)
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	t.NoError(err)
+	defer proxyListener.Close()
+	go func() {
+		for {
+			conn, err := proxyListener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				request, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || request.Method != http.MethodConnect {
+					return
+				}
+				requested <- request.Host
+				upstream, err := net.Dial("tcp", server.Addr().String())
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	viper.Set(config.ProcessorsTlsProxies, []map[string]interface{}{
+		{"url": "http://" + proxyListener.Addr().String(), "sources": []string{"partners"}},
+	})
+	processor, err := processors.CreateTLSStateRetrieval()
+	t.NoError(err)
+	target := createTarget("shop", "file", "partners", nil, t.parseUrl("https://shop.example.com"))
+	scan := CreateScan(Discoveries{&targetsDiscovery{targets: []*Target{target}}}, Processors{processor}, nil, nil)
+	scan.resolver = t.sut.resolver
+	t.NoError(scan.Scan(context.Background()))
+
+	results := scan.Results()
+	t.Len(results, 1)
+	t.NotContains(results[0].Target.Metadata.Labels, ResolvedIP)
+	for _, result := range results[0].Results {
+		t.False(IsError(result.Error, ConnectionError))
+	}
+	close(requested)
+	t.NotEmpty(requested)
+	for host := range requested {
+		t.Equal("shop.example.com:443", host)
+	}
+}
+
+func (t *ResolveTests) TestConnectsToPortOfUrl() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	t.NoError(err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	address := t.parseUrl(fmt.Sprintf("https://127.0.0.1:%d", port))
+	conn, err := address.Connect(context.Background(), DefaultDialer)
+	t.NoError(err)
+	conn.Close()
+
+	resolved := t.parseUrl(fmt.Sprintf("https://shop.example.com:%d", port)).WithResolvedIP(netip.MustParseAddr("127.0.0.1"))
+	conn, err = resolved.Connect(context.Background(), DefaultDialer)
+	t.NoError(err)
+	conn.Close()
+}
+
+func (t *ResolveTests) TestFlagsInconsistentCertificates() {
+	ca, err := CreateTestCA(0)
+	t.NoError(err)
+	current, _, _, err := ca.CreateLeafCert("shop.example.com")
+	t.NoError(err)
+	stale, _, _, err := ca.CreateLeafCert("shop.example.com")
+	t.NoError(err)
+
+	shop := createTarget("shop", "file", "hosts", nil, t.parseUrl("https://shop.example.com"))
+	lb := createTarget("lb", "file", "hosts", nil, t.parseUrl("https://lb.example.com"))
+	resolved := t.sut.resolve(context.Background(), []*Target{shop, lb})
+	t.sut.TargetScans = []*TargetScan{
+		t.targetScan(resolved[0], current),
+		t.targetScan(resolved[1], stale),
+		t.targetScan(resolved[2], current),
+		CreateTestTargetScan().WithTarget(resolved[3]).WithError(CreateGenericError(ConnectionError, fmt.Errorf("refused"), nil)).Build(),
+	}
+	t.sut.compareResolved()
+
+	for x, cert := range []*x509.Certificate{current, stale, current} {
+		violations := t.sut.TargetScans[x].Violations
+		t.Len(violations, 1)
+		labels := violations[0].Labels()
+		t.Equal(InconsistentCertificates, labels["type"])
+		t.Equal(fingerprint(cert), labels[Fingerprint])
+		t.Equal("3", labels[ResolvedIPs])
+		t.Equal("2", labels[DistinctCertificates])
+		t.Equal(resolved[x].Metadata.Labels[ResolvedIP], labels[ResolvedIP])
+	}
+	t.Empty(t.sut.TargetScans[3].Violations)
+}
+
+func (t *ResolveTests) TestConsistentCertificatesPass() {
+	ca, err := CreateTestCA(0)
+	t.NoError(err)
+	cert, _, _, err := ca.CreateLeafCert("shop.example.com")
+	t.NoError(err)
+
+	resolved := t.sut.resolve(context.Background(), []*Target{createTarget("shop", "file", "hosts", nil, t.parseUrl("https://shop.example.com"))})
+	for _, target := range resolved {
+		t.sut.TargetScans = append(t.sut.TargetScans, t.targetScan(target, cert))
+	}
+	t.sut.compareResolved()
+	for _, targetScan := range t.sut.TargetScans {
+		t.Empty(targetScan.Violations)
+	}
+}
+
+func (t *ResolveTests) parseUrl(u string) *UrlAddress {
+	address, err := ParseUrlAddress(u)
+	t.NoError(err)
+	return address
+}
+
+func (t *ResolveTests) targetScan(target *Target, cert *x509.Certificate) *TargetScan {
+	return CreateTestTargetScan().WithTarget(target).WithCertificates(cert).Build()
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
+type mockResolver struct {
+	ips map[string][]string
+}
+
+func (m *mockResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	ips, found := m.ips[host]
+	if !found {
+		return nil, fmt.Errorf("lookup %s: no such host", host)
+	}
+	addrs := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, netip.MustParseAddr(ip))
+	}
+	return addrs, nil
+}
+
+func TestResolveTests(t *testing.T) {
+	suite.Run(t, &ResolveTests{})
+}
@@ -9,16 +9,32 @@ import (
 	"github.com/sgargan/cert-scanner-darkly/processors"
 	"github.com/sgargan/cert-scanner-darkly/reporters"
 	"github.com/sgargan/cert-scanner-darkly/validations"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
 )
 
 func PerformScan(ctx context.Context) (*Scan, error) {
+	discoveries, err := CreateDiscoveries()
+	if err != nil {
+		return nil, err
+	}
+	return PerformScanWithDiscoveries(ctx, discoveries)
+}
+
+// CreateDiscoveries creates the configured discovery mechanisms. In repeated mode these are created once
+// and reused for each scan, allowing discoveries such as informers to keep state between scans.
+func CreateDiscoveries() (Discoveries, error) {
 	slog.Info("creating service discovery mechanisms")
 	discoveries, err := discovery.CreateDiscoveries()
 	if err != nil {
 		slog.Error("error configuring discovery mechanisms", "err", err.Error())
 		return nil, err
 	}
+	return discoveries, nil
+}
 
+// PerformScanWithDiscoveries runs a single scan of the targets found by the given discoveries
+func PerformScanWithDiscoveries(ctx context.Context, discoveries Discoveries) (*Scan, error) {
 	slog.Info("creating processors")
 	processors, err := processors.CreateProcessors()
 	if err != nil {
@@ -47,3 +63,20 @@ func PerformScan(ctx context.Context) (*Scan, error) {
 	}
 	return scan, nil
 }
+
+// ScanTargets runs a single scan of the given targets, such as those a [WatchingDiscovery] found between scans
+func ScanTargets(ctx context.Context, targets []*Target) (*Scan, error) {
+	return PerformScanWithDiscoveries(ctx, Discoveries{&targetsDiscovery{targets: targets}})
+}
+
+// targetsDiscovery discovers a fixed set of targets
+type targetsDiscovery struct {
+	targets []*Target
+}
+
+func (d *targetsDiscovery) Discover(ctx context.Context, targets chan *Target) error {
+	for _, target := range d.targets {
+		targets <- target
+	}
+	return nil
+}
@@ -2,12 +2,15 @@ package scanner
 
 import (
 	"context"
+	"net"
 	"path"
 	"reflect"
 	"runtime"
 	"sync"
 	"sync/atomic"
 
+	"github.com/sgargan/cert-scanner-darkly/config"
+	"github.com/sgargan/cert-scanner-darkly/processors/proxy"
 	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/sgargan/cert-scanner-darkly/utils"
 	"github.com/spf13/viper"
@@ -18,33 +21,56 @@ import (
 // validating and reporting on each.
 type Scan struct {
 	sync.Mutex
-	parallel    int
-	TargetScans []*TargetScan
-	processors  Processors
-	discoveries Discoveries
-	validations Validations
-	reporters   Reporters
+	parallel     int
+	duplicates   string
+	resolver     Resolver
+	proxies      []proxy.Config
+	resolvedFrom map[*Target]*Target
+	probedFrom   map[*Target]*Target
+	failed       []string
+	TargetScans  []*TargetScan
+	processors   Processors
+	discoveries  Discoveries
+	validations  Validations
+	reporters    Reporters
 }
 
 func CreateScan(discoveries Discoveries, processors Processors, validations Validations, reporters Reporters) *Scan {
 	return &Scan{
-		parallel:    getBatchSize(),
-		TargetScans: make([]*TargetScan, 0),
-		discoveries: discoveries,
-		processors:  processors,
-		validations: validations,
-		reporters:   reporters,
+		parallel:     getBatchSize(),
+		duplicates:   viper.GetString(config.Duplicates),
+		resolver:     net.DefaultResolver,
+		resolvedFrom: make(map[*Target]*Target),
+		probedFrom:   make(map[*Target]*Target),
+		TargetScans:  make([]*TargetScan, 0),
+		discoveries:  discoveries,
+		processors:   processors,
+		validations:  validations,
+		reporters:    reporters,
 	}
 }
 
 func (s *Scan) Scan(ctx context.Context) error {
+	proxies, err := proxy.LoadConfigs()
+	if err != nil {
+		return err
+	}
+	s.proxies = proxies
+
 	targets, err := s.discover(ctx)
 	if err != nil {
 		return err
 	}
+	if s.duplicates != KeepDuplicates {
+		targets = mergeTargets(targets, s.proxies)
+	}
+	targets = s.resolve(ctx, targets)
+	targets = s.probeServerNames(targets)
 
 	s.process(ctx, targets)
 	s.validate(ctx)
+	s.compareResolved()
+	s.compareServerNames()
 	s.report(ctx)
 	return nil
 }
@@ -59,6 +85,11 @@ func (s *Scan) Results() []*TargetScan {
 	return s.TargetScans
 }
 
+// FailedSources are the sources the scan could only discover some of the targets of
+func (s *Scan) FailedSources() []string {
+	return s.failed
+}
+
 // process each of the targets and extract the certificate/connection state for post processing. Targets will be processed in parallel
 // number of concurrent retrievals can be controlled via the "batch.processors" configuration value.
 func (s *Scan) process(ctx context.Context, targets []*Target) error {
@@ -117,7 +148,16 @@ func (s *Scan) discover(ctx context.Context) ([]*Target, error) {
 
 	group := utils.BatchProcess[Discovery](ctx, s.discoveries, s.parallel, func(ctx context.Context, discovery Discovery) error {
 		slog.Info("Discovering targets", "discovery", getPkgName(discovery))
-		return discovery.Discover(ctx, targets)
+		err := discovery.Discover(ctx, targets)
+		// sources that were partly discovered are scanned with the targets that could be
+		if failed := FailedSources(err); failed != nil {
+			slog.Error("error discovering targets, scanning those discovered", "discovery", getPkgName(discovery), "sources", failed, "err", err.Error())
+			s.Lock()
+			defer s.Unlock()
+			s.failed = append(s.failed, failed...)
+			return nil
+		}
+		return err
 	})
 	err := group.Wait()
 	close(targets)
@@ -160,7 +200,7 @@ func (s *Scan) report(ctx context.Context) error {
 }
 
 func getBatchSize() int {
-	if batchSize := viper.GetInt("batch.processors"); batchSize == 0 {
+	if batchSize := viper.GetInt(config.BatchProcessors); batchSize == 0 {
 		return runtime.NumCPU() + 1
 	} else {
 		return batchSize
@@ -2,12 +2,14 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/netip"
 	"runtime"
 	"sync"
 	"testing"
 
+	"github.com/sgargan/cert-scanner-darkly/config"
 	. "github.com/sgargan/cert-scanner-darkly/testutils"
 	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/spf13/viper"
@@ -30,7 +32,7 @@ func (t *ScannerTests) SetupTest() {
 	t.validations = make(Validations, 0)
 	t.reporters = make(Reporters, 0)
 	for x := 0; x < 10; x++ {
-		t.discoveries = append(t.discoveries, &MockDiscovery{})
+		t.discoveries = append(t.discoveries, &MockDiscovery{id: x})
 		t.processors = append(t.processors, &MockProcessor{})
 		t.validations = append(t.validations, &MockValidation{results: make([]*TargetScan, 0)})
 		t.reporters = append(t.reporters, &MockReporter{results: make([]*TargetScan, 0)})
@@ -100,14 +102,30 @@ func (t *ScannerTests) TestErrorDuringDiscovery() {
 	t.ErrorContains(err, "something barfed during discovery")
 }
 
+func (t *ScannerTests) TestScansTargetsOfPartlyDiscoveredSources() {
+	t.discoveries = Discoveries{
+		&MockDiscovery{id: 1, err: errors.Join(CreateSourceError(fmt.Errorf("some endpoint failed"), "some-source"), CreateSourceError(fmt.Errorf("another endpoint failed"), "another-source"))},
+		&MockDiscovery{id: 2},
+	}
+	reporter := &MockReporter{}
+	scan := CreateScan(t.discoveries, Processors{&MockProcessor{}}, nil, Reporters{reporter})
+	t.NoError(scan.Scan(context.Background()))
+	t.Len(reporter.results, 20)
+	t.Equal([]string{"some-source", "another-source"}, scan.FailedSources())
+
+	t.discoveries = append(t.discoveries, &MockDiscovery{id: 3, err: errors.Join(CreateSourceError(fmt.Errorf("some endpoint failed"), "some-source"), fmt.Errorf("something barfed during discovery"))})
+	t.ErrorContains(CreateScan(t.discoveries, nil, nil, nil).Scan(context.Background()), "something barfed during discovery")
+}
+
 func (t *ScannerTests) TestBatchSizeRetrieval() {
 	t.Equal(getBatchSize(), runtime.NumCPU()+1)
-	viper.Set("batch.processors", 16)
+	viper.Set(config.BatchProcessors, 16)
 	t.Equal(getBatchSize(), 16)
 }
 
 type MockDiscovery struct {
 	sync.Mutex
+	id     int
 	err    error
 	called bool
 }
@@ -118,7 +136,7 @@ func (m *MockDiscovery) Discover(ctx context.Context, targets chan *Target) erro
 	m.called = true
 	for x := 0; x < 10; x++ {
 		targets <- &Target{
-			Address: CreateNetIPAddress(netip.MustParseAddrPort(fmt.Sprintf("123.123.231.231:%d", x))),
+			Address: CreateNetIPAddress(netip.MustParseAddrPort(fmt.Sprintf("123.123.231.%d:%d", m.id, x))),
 		}
 	}
 	return m.err
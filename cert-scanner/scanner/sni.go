@@ -0,0 +1,166 @@
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	SNI         = "sni"
+	NoSNI       = "none"
+	SNIMismatch = "sni_mismatch"
+
+	// DefaultUnmatched is the reason given when the certificate served without SNI is not served for any of
+	// the names probed, e.g. a self signed fallback certificate
+	DefaultUnmatched = "default_unmatched"
+	// SNIIgnored is the reason given when a name is served the default certificate and it does not cover
+	// the name, as the server has no certificate for it
+	SNIIgnored = "sni_ignored"
+)
+
+// SNIMismatchError is raised when the certificate an ip serves without SNI differs from those it serves for
+// the names it was probed with in an unexpected way
+type SNIMismatchError struct {
+	result      *ScanResult
+	reason      string
+	serverName  string
+	fingerprint string
+}
+
+func (e *SNIMismatchError) Error() string {
+	if e.reason == SNIIgnored {
+		return fmt.Sprintf("default certificate served for %s does not cover the name", e.serverName)
+	}
+	return "default certificate is not served for any of the probed names"
+}
+
+func (e *SNIMismatchError) Labels() map[string]string {
+	labels := e.result.Labels()
+	labels["type"] = SNIMismatch
+	labels["reason"] = e.reason
+	labels[Fingerprint] = e.fingerprint
+	return labels
+}
+
+func (e *SNIMismatchError) Result() *ScanResult {
+	return e.result
+}
+
+// probeServerNames fans each ip target with server names out into a target that presents no SNI and a target
+// presenting each of the names, so the certificate served for each is scanned and reported separately. Each
+// is labelled with the name it presents, or none. A server name the target already validates is still
+// validated, the others are only presented. The target each probe was created from is tracked, so the
+// certificates served for each name can be compared with the default.
+func (s *Scan) probeServerNames(targets []*Target) []*Target {
+	expanded := make([]*Target, 0, len(targets))
+	for _, target := range targets {
+		address, isIP := target.Address.(*NetIPAddress)
+		if !isIP || len(target.ServerNames) == 0 {
+			expanded = append(expanded, target)
+			continue
+		}
+
+		names := make([]string, 0, len(target.ServerNames)+1)
+		if address.ServerName() != "" {
+			names = append(names, address.ServerName())
+		}
+		for _, name := range target.ServerNames {
+			names = appendUnique(names, name)
+		}
+
+		probes := []*Target{probeTarget(target, CreateNetIPAddress(address.AddrPort()), NoSNI)}
+		for _, name := range names {
+			if name == address.ServerName() {
+				probes = append(probes, probeTarget(target, address, name))
+			} else {
+				probes = append(probes, probeTarget(target, CreateNetIPAddressWithSNI(address.AddrPort(), name), name))
+			}
+		}
+		for _, probe := range probes {
+			s.probedFrom[probe] = target
+		}
+		slog.Debug("probing target with server names", "target", target.Name, "address", address.String(), "names", len(names))
+		expanded = append(expanded, probes...)
+	}
+	return expanded
+}
+
+func probeTarget(target *Target, address Address, serverName string) *Target {
+	labels := Labels{}
+	for k, v := range target.Metadata.Labels {
+		labels[k] = v
+	}
+	labels[SNI] = serverName
+	return &Target{
+		Address: address,
+		Metadata: Metadata{
+			Name:       target.Name,
+			Source:     target.Source,
+			SourceType: target.SourceType,
+			Labels:     labels,
+			Network:    target.Network,
+		},
+	}
+}
+
+// compareServerNames compares the certificate each probed ip serves without SNI with those it serves for each
+// name. Servers typically serve the certificate of one of their names by default, so an sni_mismatch
+// violation is added to the default probe if its certificate is not served for any of the names, and to
+// the probe of each name that is served the default certificate when it does not cover the name. Probes
+// that could not be scanned are not compared.
+func (s *Scan) compareServerNames() {
+	groups := make(map[*Target][]*TargetScan)
+	for _, targetScan := range s.TargetScans {
+		if from, probed := s.probedFrom[targetScan.Target]; probed {
+			groups[from] = append(groups[from], targetScan)
+		}
+	}
+
+	for from, targetScans := range groups {
+		var defaultScan *TargetScan
+		named := make([]*TargetScan, 0, len(targetScans))
+		for _, targetScan := range targetScans {
+			if leafCertificate(targetScan) == nil {
+				continue
+			}
+			if targetScan.Target.Metadata.Labels[SNI] == NoSNI {
+				defaultScan = targetScan
+			} else {
+				named = append(named, targetScan)
+			}
+		}
+		if defaultScan == nil || len(named) == 0 {
+			continue
+		}
+
+		defaultCert := leafCertificate(defaultScan)
+		matched := false
+		for _, targetScan := range named {
+			if !bytes.Equal(leafCertificate(targetScan).Raw, defaultCert.Raw) {
+				continue
+			}
+			matched = true
+			serverName := targetScan.Target.Metadata.Labels[SNI]
+			if defaultCert.VerifyHostname(serverName) != nil {
+				slog.Warn("server name is served the default certificate", "target", from.Name, "address", from.Address.String(), "sni", serverName)
+				targetScan.AddViolation(&SNIMismatchError{
+					result:      targetScan.FirstSuccessful,
+					reason:      SNIIgnored,
+					serverName:  serverName,
+					fingerprint: CertificateFingerprint(defaultCert),
+				})
+			}
+		}
+		if !matched {
+			slog.Warn("default certificate is not served for any server name", "target", from.Name, "address", from.Address.String(), "names", len(named))
+			defaultScan.AddViolation(&SNIMismatchError{
+				result:      defaultScan.FirstSuccessful,
+				reason:      DefaultUnmatched,
+				fingerprint: CertificateFingerprint(defaultCert),
+			})
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/netip"
+	"testing"
+
+	. "github.com/sgargan/cert-scanner-darkly/testutils"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type SNITests struct {
+	suite.Suite
+	sut *Scan
+	ca  *TestCA
+}
+
+func (t *SNITests) SetupTest() {
+	t.sut = CreateScan(nil, nil, nil, nil)
+	ca, err := CreateTestCA(0)
+	t.NoError(err)
+	t.ca = ca
+}
+
+func (t *SNITests) TestProbesEachServerNameAndNoSNI() {
+	dial := netip.MustParseAddrPort("10.0.0.1:443")
+	target := createTarget("web", "file", "hosts", Labels{"team": "web"}, CreateNetIPAddressWithServerName(dial, "www.example.com"))
+	target.ServerNames = []string{"api.example.com", "www.example.com"}
+	plain := createTarget("db", "file", "hosts", nil, CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.2:5432")))
+
+	probes := t.sut.probeServerNames([]*Target{target, plain})
+	t.Len(probes, 4)
+	t.Equal(CreateNetIPAddress(dial), probes[0].Address)
+	t.Equal(Labels{"team": "web", SNI: NoSNI}, probes[0].Metadata.Labels)
+	t.Same(target.Address, probes[1].Address)
+	t.True(probes[1].Address.ValidateHostname())
+	t.Equal("www.example.com", probes[1].Metadata.Labels[SNI])
+	t.Equal(CreateNetIPAddressWithSNI(dial, "api.example.com"), probes[2].Address)
+	t.False(probes[2].Address.ValidateHostname())
+	t.Equal("api.example.com", probes[2].Metadata.Labels[SNI])
+	t.Same(plain, probes[3])
+
+	for _, probe := range probes[:3] {
+		t.Same(target, t.sut.probedFrom[probe])
+		t.Empty(probe.ServerNames)
+	}
+	t.NotContains(t.sut.probedFrom, plain)
+}
+
+func (t *SNITests) TestDefaultCertificateOfOneNamePasses() {
+	www := t.createCert("www.example.com")
+	api := t.createCert("api.example.com")
+
+	t.compare(map[string]*x509.Certificate{NoSNI: www, "www.example.com": www, "api.example.com": api})
+	for _, targetScan := range t.sut.TargetScans {
+		t.Empty(targetScan.Violations)
+	}
+}
+
+func (t *SNITests) TestFlagsUnmatchedDefaultCertificate() {
+	fallback := t.createCert("fallback.local")
+	www := t.createCert("www.example.com")
+
+	t.compare(map[string]*x509.Certificate{NoSNI: fallback, "www.example.com": www})
+	violations := t.violations()
+	t.Len(violations[NoSNI], 1)
+	labels := violations[NoSNI][0].Labels()
+	t.Equal(SNIMismatch, labels["type"])
+	t.Equal(DefaultUnmatched, labels["reason"])
+	t.Equal(fingerprint(fallback), labels[Fingerprint])
+	t.Equal(NoSNI, labels[SNI])
+	t.Empty(violations["www.example.com"])
+}
+
+func (t *SNITests) TestFlagsNamesServedDefaultCertificate() {
+	www := t.createCert("www.example.com")
+
+	t.compare(map[string]*x509.Certificate{NoSNI: www, "www.example.com": www, "api.example.com": www})
+	violations := t.violations()
+	t.Empty(violations[NoSNI])
+	t.Empty(violations["www.example.com"])
+	t.Len(violations["api.example.com"], 1)
+	labels := violations["api.example.com"][0].Labels()
+	t.Equal(SNIIgnored, labels["reason"])
+	t.Equal("api.example.com", labels[SNI])
+	t.Equal("default certificate served for api.example.com does not cover the name", violations["api.example.com"][0].Error())
+}
+
+func (t *SNITests) TestSkipsProbesThatFailed() {
+	dial := netip.MustParseAddrPort("10.0.0.1:443")
+	target := createTarget("web", "file", "hosts", nil, CreateNetIPAddress(dial))
+	target.ServerNames = []string{"www.example.com"}
+	probes := t.sut.probeServerNames([]*Target{target})
+	t.sut.TargetScans = []*TargetScan{
+		CreateTestTargetScan().WithTarget(probes[0]).WithError(CreateGenericError(ConnectionError, fmt.Errorf("reset"), nil)).Build(),
+		CreateTestTargetScan().WithTarget(probes[1]).WithCertificates(t.createCert("www.example.com")).Build(),
+	}
+	t.sut.compareServerNames()
+	t.Empty(t.sut.TargetScans[1].Violations)
+}
+
+// compare probes a target with each of the named certificates, serving each as the result of its probe
+func (t *SNITests) compare(certs map[string]*x509.Certificate) {
+	target := createTarget("web", "file", "hosts", nil, CreateNetIPAddress(netip.MustParseAddrPort("10.0.0.1:443")))
+	for name := range certs {
+		if name != NoSNI {
+			target.ServerNames = append(target.ServerNames, name)
+		}
+	}
+	for _, probe := range t.sut.probeServerNames([]*Target{target}) {
+		cert := certs[probe.Metadata.Labels[SNI]]
+		t.sut.TargetScans = append(t.sut.TargetScans, CreateTestTargetScan().WithTarget(probe).WithCertificates(cert).Build())
+	}
+	t.sut.compareServerNames()
+}
+
+func (t *SNITests) violations() map[string][]ScanError {
+	violations := make(map[string][]ScanError)
+	for _, targetScan := range t.sut.TargetScans {
+		violations[targetScan.Target.Metadata.Labels[SNI]] = targetScan.Violations
+	}
+	return violations
+}
+
+func (t *SNITests) createCert(name string) *x509.Certificate {
+	serialNumber, err := CreateSerialNumber()
+	t.NoError(err)
+	template := CreateLeafTemplate(name, serialNumber)
+	template.DNSNames = []string{name}
+	cert, _, _, err := t.ca.CreateLeafFromTemplate(template)
+	t.NoError(err)
+	return cert
+}
+
+func TestSNITests(t *testing.T) {
+	suite.Run(t, &SNITests{})
+}
@@ -33,6 +33,11 @@ func (t *TestCA) Root() *CA {
 	return nil
 }
 
+// Cert is the certificate of this link in the chain
+func (c *CA) Cert() *x509.Certificate {
+	return c.cert
+}
+
 func (t *TestCA) Bundle() *x509.CertPool {
 	pool := x509.NewCertPool()
 	for _, ca := range t.chain {
@@ -2,27 +2,50 @@ package types
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"net/netip"
 	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
 type Labels = map[string]string
 
+// Dialer connects to the address of a target. It is implemented by [net.Dialer], and by dialers that
+// connect through a proxy.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DefaultDialer connects to addresses directly
+var DefaultDialer Dialer = &net.Dialer{
+	Timeout: 1 * time.Second,
+}
+
 // Address is the location that will be connected to for scanning.
 // it is typically an net.IP or a url
 type Address interface {
-	Connect(context context.Context) (net.Conn, error)
+	// Connect to the address with the given dialer
+	Connect(context context.Context, dialer Dialer) (net.Conn, error)
 	String() string
 	ValidateHostname() bool
+	ServerName() string
+
+	// DialAddress is the host:port connected to, or the location of certificates that are not
+	// connected to
+	DialAddress() string
 }
 
 type NetIPAddress struct {
-	ip netip.AddrPort
+	ip         netip.AddrPort
+	serverName string
+	sniOnly    bool
 }
 
 func CreateNetIPAddress(ip netip.AddrPort) *NetIPAddress {
@@ -31,24 +54,73 @@ func CreateNetIPAddress(ip netip.AddrPort) *NetIPAddress {
 	}
 }
 
-func (n *NetIPAddress) Connect(ctx context.Context) (net.Conn, error) {
-	dialer := &net.Dialer{
-		Timeout: 1 * time.Second,
+// CreateNetIPAddressWithServerName creates an address that connects to the given ip, presenting the given
+// server name in the tls handshake and validating it against the certificate.
+func CreateNetIPAddressWithServerName(ip netip.AddrPort, serverName string) *NetIPAddress {
+	return &NetIPAddress{
+		ip:         ip,
+		serverName: serverName,
 	}
+}
+
+// CreateNetIPAddressWithSNI creates an address that connects to the given ip, presenting the given server
+// name in the tls handshake without validating it. It is used to probe which certificate the ip serves for
+// each of several names.
+func CreateNetIPAddressWithSNI(ip netip.AddrPort, serverName string) *NetIPAddress {
+	return &NetIPAddress{
+		ip:         ip,
+		serverName: serverName,
+		sniOnly:    true,
+	}
+}
+
+func (n *NetIPAddress) Connect(ctx context.Context, dialer Dialer) (net.Conn, error) {
 	return dialer.DialContext(ctx, "tcp", n.ip.String())
 }
 
-// NetIp does not validate the hostname as part of the tls handshake
+// NetIp only validates the hostname against its certificate if it has a server name that is not only
+// presented as SNI
 func (n *NetIPAddress) ValidateHostname() bool {
-	return false
+	return n.serverName != "" && !n.sniOnly
+}
+
+func (n *NetIPAddress) ServerName() string {
+	return n.serverName
 }
 
 func (n *NetIPAddress) String() string {
 	return n.ip.String()
 }
 
+func (n *NetIPAddress) DialAddress() string {
+	return n.ip.String()
+}
+
+// AddrPort is the ip and port the address connects to
+func (n *NetIPAddress) AddrPort() netip.AddrPort {
+	return n.ip
+}
+
+// defaultPorts are the ports dialled for urls of each scheme that do not give one
+var defaultPorts = map[string]string{
+	"tls":        "443",
+	"https":      "443",
+	"smtp":       "25",
+	"submission": "587",
+	"imap":       "143",
+	"pop3":       "110",
+	"ldap":       "389",
+	"ftp":        "21",
+	"xmpp":       "5222",
+	"postgres":   "5432",
+	"postgresql": "5432",
+	"mysql":      "3306",
+}
+
 type UrlAddress struct {
-	url *url.URL
+	url         *url.URL
+	dialAddress string
+	resolvedIP  netip.Addr
 }
 
 func ParseUrlAddress(u string) (*UrlAddress, error) {
@@ -66,15 +138,27 @@ func CreateUrlAddress(url *url.URL) *UrlAddress {
 	}
 }
 
-func (n *UrlAddress) Connect(ctx context.Context) (net.Conn, error) {
-	dialer := &net.Dialer{
-		Timeout: 1 * time.Second,
+// CreateUrlAddressWithDialAddress creates an address that connects to the given dial address, typically
+// a load balancer, while still presenting and validating the hostname from the url.
+func CreateUrlAddressWithDialAddress(url *url.URL, dialAddress string) *UrlAddress {
+	return &UrlAddress{
+		url:         url,
+		dialAddress: dialAddress,
 	}
-	port := n.url.Port()
-	if port == "" && (n.url.Scheme == "tls" || n.url.Scheme == "https") {
-		port = "443"
+}
+
+// WithResolvedIP creates a copy of the address that connects to the given ip, one of those its host resolves
+// to, while still presenting and validating the hostname from the url.
+func (n *UrlAddress) WithResolvedIP(ip netip.Addr) *UrlAddress {
+	return &UrlAddress{
+		url:         n.url,
+		dialAddress: n.dialAddress,
+		resolvedIP:  ip,
 	}
-	return dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%s", n.url.Host, port))
+}
+
+func (n *UrlAddress) Connect(ctx context.Context, dialer Dialer) (net.Conn, error) {
+	return dialer.DialContext(ctx, "tcp", n.DialAddress())
 }
 
 // URLs shoud validate the hostname as part of the tls handshake
@@ -82,10 +166,87 @@ func (n *UrlAddress) ValidateHostname() bool {
 	return true
 }
 
+func (n *UrlAddress) ServerName() string {
+	return n.url.Hostname()
+}
+
 func (n *UrlAddress) String() string {
 	return n.url.Hostname()
 }
 
+// DialAddress is the resolved ip, the dial address if one was given or the host of the url, with the port of
+// the url. Urls without a port are dialled on the default port of their scheme.
+func (n *UrlAddress) DialAddress() string {
+	port := n.url.Port()
+	if port == "" {
+		port = defaultPorts[n.url.Scheme]
+	}
+	if n.resolvedIP.IsValid() {
+		return net.JoinHostPort(n.resolvedIP.String(), port)
+	}
+	return net.JoinHostPort(n.DialHost(), port)
+}
+
+// DialHost is the host that is resolved to connect to the address, the dial address if one was given or
+// the host of the url
+func (n *UrlAddress) DialHost() string {
+	if n.dialAddress != "" {
+		return n.dialAddress
+	}
+	return n.url.Hostname()
+}
+
+// Scheme is the scheme of the url, which determines how the address is connected to
+func (n *UrlAddress) Scheme() string {
+	return n.url.Scheme
+}
+
+// ResolvedIP is the ip the address connects to, if it was created for one of the ips its host resolves to
+func (n *UrlAddress) ResolvedIP() netip.Addr {
+	return n.resolvedIP
+}
+
+// StaticAddress locates certificates that are read directly, e.g. from a kubernetes secret,
+// rather than retrieved by connecting to a service.
+type StaticAddress struct {
+	location     string
+	certificates []*x509.Certificate
+}
+
+func CreateStaticAddress(location string, certificates []*x509.Certificate) *StaticAddress {
+	return &StaticAddress{
+		location:     location,
+		certificates: certificates,
+	}
+}
+
+// Static addresses cannot be connected to, their certificates are available via Certificates
+func (s *StaticAddress) Connect(ctx context.Context, dialer Dialer) (net.Conn, error) {
+	return nil, fmt.Errorf("static address %s cannot be connected to", s.location)
+}
+
+// Static certificates have no hostname to validate against
+func (s *StaticAddress) ValidateHostname() bool {
+	return false
+}
+
+func (s *StaticAddress) ServerName() string {
+	return ""
+}
+
+func (s *StaticAddress) String() string {
+	return s.location
+}
+
+func (s *StaticAddress) DialAddress() string {
+	return s.location
+}
+
+// Certificates returns the certificate chain held at this address
+func (s *StaticAddress) Certificates() []*x509.Certificate {
+	return s.certificates
+}
+
 // Target represents a discovered service running on a given address and port
 // that may be TLS enabled
 type Target struct {
@@ -93,24 +254,37 @@ type Target struct {
 	Address Address
 }
 
+// ReservedLabels are set by the scanner to identify a target and how it was probed, so cannot be given by the
+// owners of a target
+var ReservedLabels = []string{"source", "source_type", "address", "resolved_ip", "sni"}
+
+// Labels returns the labels of the target with its source, source type and address, which its metadata labels
+// cannot replace.
 func (t *Target) Labels() Labels {
-	copy := Labels{
-		"source":      t.Source,
-		"source_type": t.SourceType,
-		"address":     t.Address.String(),
-	}
+	copy := Labels{}
 	for k, v := range t.Metadata.Labels {
 		copy[k] = v
 	}
+	copy["source"] = t.Source
+	copy["source_type"] = t.SourceType
+	copy["address"] = t.Address.String()
 	return copy
 }
 
-// Metadata describes the common information about a Target
+// Metadata describes the common information about a Target. ServerNames are the names to probe an ip
+// target with, each presented as SNI in a separate scan of the target.
 type Metadata struct {
-	Name       string
-	Source     string
-	SourceType string
-	Labels     Labels
+	Name        string
+	Source      string
+	SourceType  string
+	Labels      Labels
+	ServerNames []string
+	// Network is set on targets whose address is only unique within a network, such as the pod and service
+	// ips of a cluster, so targets of different networks with the same address are not taken as duplicates
+	Network string
+	// Incremental is set on the targets of discoveries that only discover the targets changed since they last
+	// discovered. These targets are still present when missing from a later scan, until they are reported removed.
+	Incremental bool
 }
 
 // TargetScan captures the state gathered from scanning a single target. This will consist
@@ -123,6 +297,8 @@ type TargetScan struct {
 	Duration        time.Duration
 	FirstSuccessful *ScanResult
 	Violations      []ScanError
+	// Handshakes is the number of tls handshakes attempted to scan the target
+	Handshakes int
 }
 
 func NewTargetScanResult(target *Target) *TargetScan {
@@ -162,15 +338,25 @@ func (t *TargetScan) Add(r *ScanResult) {
 }
 
 // ScanResult is the state detected from a single scan of a target with a specific TLS
-// cipher and version.
+// cipher and version. Static results hold certificates that were read without connecting to
+// the target, so only the PeerCertificates of their State are populated.
 type ScanResult struct {
-	State    *tls.ConnectionState
-	Cipher   *tls.CipherSuite
-	scanTime time.Time
-	Duration time.Duration
-	Failed   bool
-	Error    ScanError
-	target   *Target
+	State              *tls.ConnectionState
+	Cipher             *tls.CipherSuite
+	CertificateRequest *CertificateRequest
+	scanTime           time.Time
+	Duration           time.Duration
+	Failed             bool
+	Static             bool
+	Error              ScanError
+	target             *Target
+}
+
+// CertificateRequest records that the target requested a client certificate in the handshake, with the
+// distinguished names of the CAs it accepts client certificates from and whether one was presented
+type CertificateRequest struct {
+	AcceptableCAs []string
+	Presented     bool
 }
 
 func NewScanResult() *ScanResult {
@@ -179,6 +365,15 @@ func NewScanResult() *ScanResult {
 	}
 }
 
+// NewStaticScanResult creates a result for a certificate chain that was not retrieved from a
+// live connection, no tls version or cipher will have been negotiated for it.
+func NewStaticScanResult(certificates []*x509.Certificate) *ScanResult {
+	result := NewScanResult()
+	result.Static = true
+	result.SetState(&tls.ConnectionState{PeerCertificates: certificates}, nil, nil)
+	return result
+}
+
 func (s *ScanResult) SetState(state *tls.ConnectionState, cipher *tls.CipherSuite, err ScanError) {
 	s.Duration = time.Since(s.scanTime)
 	s.State = state
@@ -202,6 +397,14 @@ func (s *ScanResult) Labels() map[string]string {
 		}
 	}
 
+	if s.CertificateRequest != nil {
+		copy[ClientAuth] = ClientAuthRequested
+		if s.CertificateRequest.Presented {
+			copy[ClientAuth] = ClientAuthPresented
+		}
+		copy[AcceptableCAs] = strings.Join(s.CertificateRequest.AcceptableCAs, ";")
+	}
+
 	if s.State != nil && len(s.State.PeerCertificates) > 0 {
 		copy["id"] = fmt.Sprintf("%x", s.State.PeerCertificates[0].SerialNumber)
 		copy["common_name"] = s.State.PeerCertificates[0].Subject.CommonName
@@ -222,6 +425,70 @@ type Discovery interface {
 
 type Discoveries = []Discovery
 
+// SourceError is raised by a discovery that could not discover every target of its sources, such as when one
+// of several endpoints or files of a source fails. The discovery still emits the targets it could discover,
+// and the scan goes ahead with them while keeping the results of the sources from earlier scans.
+type SourceError struct {
+	Sources []string
+	Err     error
+}
+
+func CreateSourceError(err error, sources ...string) *SourceError {
+	return &SourceError{Sources: sources, Err: err}
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// FailedSources returns the distinct sources of the [SourceError]s in the given error, including those joined into it.
+// Returns nil if any of the errors is not a SourceError, as the discovery failed outright.
+func FailedSources(err error) []string {
+	if err == nil {
+		return nil
+	}
+	if sourceErr, ok := err.(*SourceError); ok {
+		return sourceErr.Sources
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	sources := make([]string, 0)
+	for _, err := range joined.Unwrap() {
+		failed := FailedSources(err)
+		if failed == nil {
+			return nil
+		}
+		for _, source := range failed {
+			if !slices.Contains(sources, source) {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
+
+// TargetChanges are the [Target]s added to and removed from the sources of a discovery since it last
+// discovered them
+type TargetChanges struct {
+	Added   []*Target
+	Removed []*Target
+}
+
+// WatchingDiscovery is a [Discovery] that can watch its sources for changes between scans
+type WatchingDiscovery interface {
+	Discovery
+
+	// Watch the sources of the discovery, emitting changes to its targets to the given channel until
+	// the context is done. Raises an error if the sources cannot be watched
+	Watch(ctx context.Context, changes chan *TargetChanges) error
+}
+
 // Processor will be implemented by modules interested in examining discovered [Target]s.
 type Processor interface {
 
@@ -264,30 +531,65 @@ type ScanError interface {
 // CompletedScan
 type CompletedScan interface {
 	Results() []*TargetScan
+
+	// FailedSources are the sources that could only be partly discovered, see [SourceError]
+	FailedSources() []string
 }
 
-// AddressSet represents all the results of a scan, indexed by Target address
+// AddressSet represents all the results of a scan, indexed by the source, address, resolved ip and probed server
+// name of their Target. The same address can be discovered from several sources, such as clusters with overlapping
+// pod cidrs.
 type AddressSet map[string]*TargetScan
 
 func GetAddressSet(scan CompletedScan) AddressSet {
 	set := make(AddressSet, 0)
 	for _, result := range scan.Results() {
-		address := result.Target.Address.String()
-		set[address] = result
+		set[addressKey(result.Target)] = result
 	}
 	return set
 }
 
-func (a AddressSet) ContainsAddress(address string) bool {
-	_, present := a[address]
+// ContainsTarget returns true if the set holds a result for a target from the same source with the same address,
+// resolved to the same ip and probed with the same server name
+func (a AddressSet) ContainsTarget(target *Target) bool {
+	_, present := a[addressKey(target)]
 	return present
 }
 
+func addressKey(target *Target) string {
+	return target.Source + "|" + target.Address.String() + "|" + target.Metadata.Labels["resolved_ip"] + "|" + target.Metadata.Labels["sni"]
+}
+
 const (
 	ConnectionError = "connection-error"
 	HandshakeError  = "tls-handshake"
+	StartTLSError   = "starttls"
 )
 
+// Labels describing the client certificate requested by a target, client_auth is requested when no client
+// certificate was configured to present and presented when one was
+const (
+	ClientAuth          = "client_auth"
+	ClientAuthRequested = "requested"
+	ClientAuthPresented = "presented"
+	AcceptableCAs       = "acceptable_cas"
+)
+
+// Labels describing the cert-manager Certificate that issued the certificates served by a target
+const (
+	CertManagerCertificate = "certificate"
+	CertManagerNotAfter    = "certificate_not_after"
+	CertManagerRenewalTime = "certificate_renewal_time"
+	CertManagerReady       = "certificate_ready"
+	// CertManagerSecretFingerprint is the fingerprint of the cert stored in the secret of the Certificate
+	CertManagerSecretFingerprint = "secret_fingerprint"
+)
+
+// CertificateFingerprint is the hex encoded sha256 hash of the certificate
+func CertificateFingerprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
 type GenericScanError struct {
 	result    *ScanResult
 	errorType string
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveFiles expands the given paths into the files they hold. Directories hold every file directly within
+// them except hidden files, and glob patterns hold every file they match. Other paths are returned as they
+// are, so missing files are reported when they are read.
+func ResolveFiles(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	var errs error
+	for _, path := range paths {
+		if IsGlob(path) {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error matching files %s: %v", path, err))
+				continue
+			}
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && !info.IsDir() {
+					files = append(files, match)
+				}
+			}
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error reading directory %s: %v", path, err))
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, errs
+}
+
+// IsGlob returns true if the path is a glob pattern
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
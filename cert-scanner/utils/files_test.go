@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FilesTests struct {
+	suite.Suite
+}
+
+func (t *FilesTests) TestResolvesDirectoriesAndGlobs() {
+	dir := t.T().TempDir()
+	t.writeFile(filepath.Join(dir, "one.txt"), "https://golang.org\n")
+	t.writeFile(filepath.Join(dir, "two.txt"), "https://github.com\n")
+	t.writeFile(filepath.Join(dir, ".two.txt.swp"), "not a host file")
+	t.writeFile(filepath.Join(dir, "three.list"), "https://google.com\n")
+	t.NoError(os.Mkdir(filepath.Join(dir, "nested"), os.ModePerm))
+
+	files, err := ResolveFiles([]string{dir})
+	t.NoError(err)
+	t.ElementsMatch([]string{filepath.Join(dir, "one.txt"), filepath.Join(dir, "three.list"), filepath.Join(dir, "two.txt")}, files)
+
+	files, err = ResolveFiles([]string{filepath.Join(dir, "*.txt"), filepath.Join(dir, "missing.yaml")})
+	t.NoError(err)
+	t.Equal([]string{filepath.Join(dir, "one.txt"), filepath.Join(dir, "two.txt"), filepath.Join(dir, "missing.yaml")}, files)
+
+	_, err = ResolveFiles([]string{filepath.Join(dir, "[.txt")})
+	t.ErrorContains(err, "error matching files")
+}
+
+func (t *FilesTests) writeFile(name, content string) {
+	t.NoError(os.WriteFile(name, []byte(content), os.ModePerm))
+}
+
+func TestFiles(t *testing.T) {
+	suite.Run(t, &FilesTests{})
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Filter matches names against lists of include and exclude patterns
+type Filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// CreateFilter creates a filter from the given regular expressions. Returns an error if any cannot be parsed.
+func CreateFilter(include, exclude []string) (*Filter, error) {
+	parsedInclude, err := parsePatterns(include)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing include patterns: %v", err)
+	}
+	parsedExclude, err := parsePatterns(exclude)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing exclude patterns: %v", err)
+	}
+	return &Filter{include: parsedInclude, exclude: parsedExclude}, nil
+}
+
+// Matches returns true if the name matches any of the include patterns, or there are none, and does not
+// match any of the exclude patterns
+func (f *Filter) Matches(name string) bool {
+	included := len(f.include) == 0
+	for _, pattern := range f.include {
+		if pattern.MatchString(name) {
+			included = true
+			break
+		}
+	}
+	for _, pattern := range f.exclude {
+		if pattern.MatchString(name) {
+			return false
+		}
+	}
+	return included
+}
+
+func parsePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	parsed := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
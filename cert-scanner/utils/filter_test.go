@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterTests struct {
+	suite.Suite
+}
+
+func (t *FilterTests) TestMatchesIncludedNames() {
+	filter, err := CreateFilter(nil, nil)
+	t.NoError(err)
+	t.True(filter.Matches("www.example.com"))
+
+	filter, err = CreateFilter([]string{`\.example\.com$`}, []string{`^internal\.`})
+	t.NoError(err)
+	t.True(filter.Matches("www.example.com"))
+	t.False(filter.Matches("www.example.org"))
+	t.False(filter.Matches("internal.example.com"))
+}
+
+func (t *FilterTests) TestInvalidPatternsRaiseError() {
+	_, err := CreateFilter([]string{"["}, nil)
+	t.ErrorContains(err, "error parsing include patterns")
+
+	_, err = CreateFilter(nil, []string{"["})
+	t.ErrorContains(err, "error parsing exclude patterns")
+}
+
+func TestFilter(t *testing.T) {
+	suite.Run(t, &FilterTests{})
+}
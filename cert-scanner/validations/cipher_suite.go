@@ -61,6 +61,9 @@ func CreateCipherSuiteValidation(allowedCiphersList []string) (*CipherSuiteValid
 func (v *CipherSuiteValidation) Validate(scan *TargetScan) ScanError {
 	slog.Debug("validating target is using allowed ciphers", "target", scan.Target.Name)
 	for _, result := range scan.Results {
+		if result.Static || result.Cipher == nil {
+			continue
+		}
 		if _, allowed := v.allowedCiphers[result.Cipher.Name]; !allowed {
 			return &CipherSuiteValidationError{result: result}
 		}
@@ -0,0 +1,101 @@
+package validations
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	NotRenewed  = "not_renewed"
+	NotReloaded = "not_reloaded"
+)
+
+type RenewalValidation struct {
+	grace time.Duration
+}
+
+type RenewalValidationError struct {
+	reason      string
+	renewalTime time.Time
+	notAfter    time.Time
+	result      *ScanResult
+}
+
+func (e *RenewalValidationError) Result() *ScanResult {
+	return e.result
+}
+
+func (e *RenewalValidationError) Error() string {
+	if e.reason == NotRenewed {
+		return fmt.Sprintf("certificate was due for renewal on %s but has not been renewed", e.renewalTime.Format(time.RFC822))
+	}
+	return fmt.Sprintf("certificate was renewed but the served cert expiring on %s has not been reloaded", e.notAfter.Format(time.RFC822))
+}
+
+func (e *RenewalValidationError) Labels() map[string]string {
+	labels := e.result.Labels()
+	labels["type"] = "renewal"
+	labels["reason"] = e.reason
+	labels["not_after"] = fmt.Sprintf("%d", e.notAfter.UnixMilli())
+	labels["not_after_date"] = e.notAfter.Format(time.RFC3339)
+	return labels
+}
+
+// CreateRenewalValidation creates a validation of the renewal of cert-manager Certificates. A Certificate is only
+// considered not renewed once the grace period has passed after its renewal time, giving cert-manager time to
+// issue the renewed cert.
+func CreateRenewalValidation(grace time.Duration) *RenewalValidation {
+	return &RenewalValidation{grace: grace}
+}
+
+// Validate compares the cert retrieved for a target with the cert stored in the secret of the cert-manager Certificate
+// that issued it. A violation is raised when the target holds a cert other than the one in the secret, as the renewed
+// cert has not been reloaded, or when it holds the cert in the secret once the grace period after the renewal time of
+// the Certificate has passed, as it has not been renewed. Targets that were not discovered from a Certificate are not
+// validated.
+func (v *RenewalValidation) Validate(scan *TargetScan) ScanError {
+	renewal, present := scan.Target.Labels()[CertManagerRenewalTime]
+	if !present || scan.Failed() {
+		return nil
+	}
+	slog.Debug("validating cert of target has been renewed", "target", scan.Target.Name, "renewal_time", renewal)
+
+	result := scan.FirstSuccessful
+	if result == nil || len(result.State.PeerCertificates) == 0 {
+		return nil
+	}
+	served := result.State.PeerCertificates[0]
+
+	secret := scan.Target.Labels()[CertManagerSecretFingerprint]
+	if secret != "" && CertificateFingerprint(served) != secret {
+		return &RenewalValidationError{reason: NotReloaded, notAfter: served.NotAfter, result: result}
+	}
+
+	if renewal != "" {
+		renewalTime, err := time.Parse(time.RFC3339, renewal)
+		if err != nil {
+			slog.Error("error parsing certificate renewal time", "target", scan.Target.Name, "renewal_time", renewal, "error", err.Error())
+			return nil
+		}
+		if time.Now().After(renewalTime.Add(v.grace)) {
+			return &RenewalValidationError{reason: NotRenewed, renewalTime: renewalTime, notAfter: served.NotAfter, result: result}
+		}
+	}
+
+	// without the cert in the secret, the served cert is compared with the expiry cert-manager reports for it
+	if issued := scan.Target.Labels()[CertManagerNotAfter]; secret == "" && issued != "" {
+		notAfter, err := time.Parse(time.RFC3339, issued)
+		if err != nil {
+			slog.Error("error parsing certificate not after", "target", scan.Target.Name, "not_after", issued, "error", err.Error())
+			return nil
+		}
+		// cert-manager reports the expiry of the issued cert to the second
+		if served.NotAfter.Truncate(time.Second).Before(notAfter) {
+			return &RenewalValidationError{reason: NotReloaded, notAfter: served.NotAfter, result: result}
+		}
+	}
+	return nil
+}
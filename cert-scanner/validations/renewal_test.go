@@ -0,0 +1,92 @@
+package validations
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	. "github.com/sgargan/cert-scanner-darkly/testutils"
+	. "github.com/sgargan/cert-scanner-darkly/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type RenewalValidationTests struct {
+	suite.Suite
+}
+
+func (t *RenewalValidationTests) TestIgnoresTargetsWithoutCertificates() {
+	cert := CreateTestCert().WithAfter(time.Now().Add(-day))
+	scan := CreateTestTargetScan().WithTarget(TestTarget()).WithCertificates(&cert.Certificate).Build()
+	t.NoError(CreateRenewalValidation(time.Hour).Validate(scan))
+}
+
+func (t *RenewalValidationTests) TestRenewedCertIsValid() {
+	notAfter := time.Now().Add(60 * day).Truncate(time.Second)
+	cert := CreateTestCert().WithAfter(notAfter)
+	scan := t.createScan(&cert.Certificate, time.Now().Add(30*day), notAfter)
+	t.NoError(CreateRenewalValidation(time.Hour).Validate(scan))
+}
+
+func (t *RenewalValidationTests) TestNotRenewed() {
+	notAfter := time.Now().Add(10 * day).Truncate(time.Second)
+	cert := CreateTestCert().WithAfter(notAfter)
+	scan := t.createScan(&cert.Certificate, time.Now().Add(-day), notAfter)
+
+	err := CreateRenewalValidation(time.Hour).Validate(scan)
+	t.ErrorContains(err, "but has not been renewed")
+	t.Equal("not_renewed", err.Labels()["reason"])
+	t.Equal("renewal", err.Labels()["type"])
+	t.Equal("some-cert", err.Labels()["certificate"])
+}
+
+func (t *RenewalValidationTests) TestNotRenewedWithinGracePeriod() {
+	notAfter := time.Now().Add(10 * day).Truncate(time.Second)
+	cert := CreateTestCert().WithAfter(notAfter)
+	scan := t.createScan(&cert.Certificate, time.Now().Add(-30*time.Minute), notAfter)
+	t.NoError(CreateRenewalValidation(time.Hour).Validate(scan))
+	t.Error(CreateRenewalValidation(0).Validate(scan))
+}
+
+func (t *RenewalValidationTests) TestRenewedButNotReloaded() {
+	cert := CreateTestCert().WithAfter(time.Now().Add(10 * day))
+	scan := t.createScan(&cert.Certificate, time.Now().Add(30*day), time.Now().Add(60*day))
+
+	err := CreateRenewalValidation(time.Hour).Validate(scan)
+	t.ErrorContains(err, "has not been reloaded")
+	t.Equal("not_reloaded", err.Labels()["reason"])
+}
+
+func (t *RenewalValidationTests) TestServesCertOfSecret() {
+	notAfter := time.Now().Add(10 * day).Truncate(time.Second)
+	cert := CreateTestCert().WithAfter(notAfter)
+	scan := t.createScan(&cert.Certificate, time.Now().Add(-day), notAfter)
+	scan.Target.Metadata.Labels[CertManagerSecretFingerprint] = CertificateFingerprint(&cert.Certificate)
+	t.Equal("not_renewed", CreateRenewalValidation(time.Hour).Validate(scan).Labels()["reason"])
+
+	scan = t.createScan(&cert.Certificate, time.Now().Add(day), notAfter)
+	scan.Target.Metadata.Labels[CertManagerSecretFingerprint] = CertificateFingerprint(&cert.Certificate)
+	t.NoError(CreateRenewalValidation(time.Hour).Validate(scan))
+}
+
+func (t *RenewalValidationTests) TestServesCertOtherThanSecret() {
+	// the served cert expires after the one cert-manager reports, but is not the one in the secret
+	cert := CreateTestCert().WithAfter(time.Now().Add(60 * day))
+	scan := t.createScan(&cert.Certificate, time.Now().Add(-day), time.Now().Add(10*day))
+	scan.Target.Metadata.Labels[CertManagerSecretFingerprint] = "some-other-cert"
+
+	err := CreateRenewalValidation(time.Hour).Validate(scan)
+	t.ErrorContains(err, "has not been reloaded")
+	t.Equal("not_reloaded", err.Labels()["reason"])
+}
+
+func (t *RenewalValidationTests) createScan(cert *x509.Certificate, renewalTime, notAfter time.Time) *TargetScan {
+	target := TestTarget()
+	target.Metadata.Labels[CertManagerCertificate] = "some-cert"
+	target.Metadata.Labels[CertManagerRenewalTime] = renewalTime.Format(time.RFC3339)
+	target.Metadata.Labels[CertManagerNotAfter] = notAfter.Format(time.RFC3339)
+	return CreateTestTargetScan().WithTarget(target).WithCertificates(cert).Build()
+}
+
+func TestRenewalValidations(t *testing.T) {
+	suite.Run(t, &RenewalValidationTests{})
+}
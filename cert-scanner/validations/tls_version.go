@@ -42,7 +42,8 @@ func CreateTLSVersionValidation(minVersion string) (*TLSVersionValidation, error
 	return &TLSVersionValidation{minVersion: version}, nil
 }
 
-// Validate will check that the tls version is not less than the minimum configured version
+// Validate will check that the tls version is not less than the minimum configured version. Static
+// results have no negotiated version so are not validated.
 func (v *TLSVersionValidation) Validate(scan *TargetScan) ScanError {
 	slog.Debug("validating tls version of target", "target", scan.Target.Name)
 	if scan.Failed() {
@@ -50,6 +51,10 @@ func (v *TLSVersionValidation) Validate(scan *TargetScan) ScanError {
 	}
 
 	result := scan.FirstSuccessful
+	if result.Static {
+		return nil
+	}
+
 	if int(result.State.Version) < v.minVersion {
 		return &TLSVersionValidationError{
 			detectedVersion: utils.ToVersion(int(result.State.Version)),
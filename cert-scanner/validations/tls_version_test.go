@@ -7,6 +7,7 @@ import (
 
 	"github.com/sgargan/cert-scanner-darkly/testutils"
 	. "github.com/sgargan/cert-scanner-darkly/testutils"
+	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -34,6 +35,12 @@ func (t *TLSVersionValidationTests) TestInValidVersion() {
 	t.ErrorContains(TLSVersionValidation.Validate(result), "connection supports an invalid tls version 1.1, min version is 1.2")
 }
 
+func (t *TLSVersionValidationTests) TestStaticResultsAreNotValidated() {
+	TLSVersionValidation, _ := CreateTLSVersionValidation("1.2")
+	result := CreateTestTargetScan().WithScanResult(NewStaticScanResult(nil)).WithTLSVersion(tls.VersionTLS11).Build()
+	t.NoError(TLSVersionValidation.Validate(result))
+}
+
 func (t *TLSVersionValidationTests) TestTLSVersionValidationCreation() {
 	for _, version := range []string{"1.0", "1.1", "1.2", "1.3"} {
 		_, err := CreateTLSVersionValidation(version)
@@ -131,12 +131,11 @@ func (v *TrustChainValidation) Validate(scan *TargetScan) ScanError {
 		}
 	}
 
-	// if the target has a url then verify the hostname
+	// if the target has a url or server name then verify the hostname
 	// otherwise skip the name validation.
 	expectedName := ""
-	switch scan.Target.Address.(type) {
-	case *UrlAddress:
-		expectedName = scan.Target.Address.String()
+	if scan.Target.Address != nil && scan.Target.Address.ValidateHostname() {
+		expectedName = scan.Target.Address.ServerName()
 	}
 
 	cert := result.State.PeerCertificates[0]
@@ -4,11 +4,13 @@ import (
 	"bufio"
 	"crypto/x509"
 	"fmt"
+	"net/netip"
 	"os"
 	"testing"
 
 	"github.com/sgargan/cert-scanner-darkly/testutils"
 	. "github.com/sgargan/cert-scanner-darkly/testutils"
+	. "github.com/sgargan/cert-scanner-darkly/types"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -61,6 +63,15 @@ func (t *TrustChainValidationTests) TestHasInvalidTrustChain() {
 	t.ErrorContains(t.sut.Validate(result), "certificate signed by unknown authority")
 }
 
+func (t *TrustChainValidationTests) TestValidatesServerNameOfIpTargets() {
+	cert := t.createTestCertFromCA(t.ca)
+	target := &Target{Address: CreateNetIPAddressWithServerName(netip.MustParseAddrPort("10.0.0.1:443"), "localhost")}
+	t.NoError(t.sut.Validate(CreateTestTargetScan().WithTarget(target).WithCertificates(cert).Build()))
+
+	target = &Target{Address: CreateNetIPAddressWithServerName(netip.MustParseAddrPort("10.0.0.1:443"), "some-service.some-namespace.svc")}
+	t.ErrorContains(t.sut.Validate(CreateTestTargetScan().WithTarget(target).WithCertificates(cert).Build()), "not some-service.some-namespace.svc")
+}
+
 func (t *TrustChainValidationTests) createTestCertFromCA(ca *TestCA) *x509.Certificate {
 	cert, _, _, err := ca.CreateLeafCert("somehost")
 	t.NoError(err)
@@ -12,6 +12,7 @@ import (
 
 const (
 	DefaultWarningDuration = time.Duration(14 * 24 * time.Hour)
+	DefaultRenewalGrace    = time.Hour
 )
 
 var factories = map[string]Factory[Validation]{
@@ -21,6 +22,7 @@ var factories = map[string]Factory[Validation]{
 	"trust_chain":   trustChainValidation,
 	"require_tls":   requireTLSValidation,
 	"cipher_suite":  cipherSuiteValidation,
+	"renewal":       renewalValidation,
 }
 
 func CreateValidations() (Validations, error) {
@@ -61,3 +63,16 @@ func cipherSuiteValidation() (Validation, error) {
 	allowedCiphers := viper.GetStringSlice(config.ValidationsCipherSuite)
 	return CreateCipherSuiteValidation(allowedCiphers)
 }
+
+func renewalValidation() (Validation, error) {
+	grace := DefaultRenewalGrace
+	duration := viper.GetString(config.ValidationsRenewalGracePeriod)
+	if duration != "" {
+		if parsed, err := time.ParseDuration(duration); err != nil {
+			return nil, fmt.Errorf("error parsing renewal grace period from %s", duration)
+		} else {
+			grace = parsed
+		}
+	}
+	return CreateRenewalValidation(grace), nil
+}